@@ -52,8 +52,19 @@ type Config struct {
 
 	Passphrase string
 
+	Mail MailConfig
+
+	PasswordHasher PasswordHasherConfig
+
+	// OpenID providers of staff login
+	OIDC []OIDCConfig
+
 	// Cache Expire
 	CacheExpireStaff time.Duration
+	// compiled permissions of a role in redis and in-process, default 60s
+	CacheExpireRole time.Duration
+	// in-process permissions of a role are trusted this long before its version is re-checked in redis, default 5s
+	CacheCheckRole time.Duration
 }
 
 type SwaggerContact struct {
@@ -92,6 +103,7 @@ type Cron struct {
 	Enables []string
 }
 type AuthConfig struct {
+	ApplicationName           string // iss claim
 	JWTSecret                 string
 	AccessTokenDuration       time.Duration
 	RefreshTokenDuration      time.Duration
@@ -100,12 +112,77 @@ type AuthConfig struct {
 	ResendOTPMaxAttempts      int
 	VerifyOTPMaxAttempts      int
 	ReturnOTP                 bool
+	OTPDuration               time.Duration
+	OTPLockDuration           time.Duration
 	DemoUser                  struct {
 		Email string
 		Tel   string
 		Pin   string
 	}
 	LenTempPwd int
+	// password policy
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordDenylist      []string
+	PasswordHistory       int
+	PasswordMaxAge        time.Duration
+	// RS256 / EdDSA pem files, the first private key sign new tokens and the rest only verify by kid
+	SigningKeys []struct {
+		KID  string
+		File string
+	}
+	// lifetime of the access token issued by impersonation, default 15m
+	ImpersonationTokenDuration time.Duration
+
+	// progressive lockout, the email is locked for Duration when its failed logins reach Strikes,
+	// empty lock 5m at AccountLockoutMaxAttempts and 1h at twice of it
+	LockoutSteps []struct {
+		Strikes  int
+		Duration time.Duration
+	}
+	// failed logins of the email are forgotten after the window, default 24h
+	LockoutStrikeWindow time.Duration
+	// failed logins of one ip during IPLockoutWindow, 0 disable
+	IPLockoutMaxAttempts int
+	IPLockoutWindow      time.Duration
+}
+
+// MailConfig outbound mail, transport smtp / file / log
+type MailConfig struct {
+	Transport    string
+	From         string
+	AppName      string
+	BaseUrl      string // frontend serving the links in the email, required outside development, default to BaseUrl
+	Dir          string // file transport
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// OIDCConfig authorization code + PKCE provider, the endpoints are read from the discovery url
+type OIDCConfig struct {
+	Name           string // path of /staffs/oidc/:provider
+	DiscoveryURL   string // e.g. https://accounts.google.com/.well-known/openid-configuration
+	ClientID       string
+	ClientSecret   string
+	RedirectURL    string
+	Scopes         []string // openid is always requested, default email profile
+	AllowedDomains []string // email domains which can login, empty allow every domain
+	AutoProvision  bool     // create staff of unknown email with DefaultRoleID
+	DefaultRoleID  string
+}
+
+// PasswordHasherConfig hasher of new passwords, argon2id or bcrypt
+type PasswordHasherConfig struct {
+	Algorithm         string
+	Argon2Memory      uint32 // KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
 }
 
 var (
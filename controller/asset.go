@@ -72,3 +72,8 @@ func (h AssetHandler) Delete(ctx echo.Context) error {
 	}
 	return ctx.NoContent(http.StatusOK)
 }
+
+// GET /assets/:id/history
+func (h AssetHandler) History(ctx echo.Context) error {
+	return history(ctx, h.Services.IAsset.History)
+}
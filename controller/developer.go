@@ -72,3 +72,8 @@ func (h DeveloperHandler) Delete(ctx echo.Context) error {
 	}
 	return ctx.NoContent(http.StatusOK)
 }
+
+// GET /developers/:id/history
+func (h DeveloperHandler) History(ctx echo.Context) error {
+	return history(ctx, h.Services.IDeveloper.History)
+}
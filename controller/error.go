@@ -106,6 +106,10 @@ func ErrorHandler(isDebug bool) func(err error, c echo.Context) {
 			errRes.Error = xerr.Error()
 		}
 
+		if retryAfter, ok := xerr.ExtraInfo[xerror.RetryAfterKey]; ok {
+			c.Response().Header().Set(echo.HeaderRetryAfter, fmt.Sprint(retryAfter))
+		}
+
 		c.JSON(statusCode, errRes)
 	}
 }
@@ -128,4 +132,5 @@ var xErrorCodes = map[string]int{
 	xerror.ErrCodeConflict:            http.StatusConflict,
 	xerror.ErrCodeInternalServerError: http.StatusInternalServerError,
 	xerror.ErrCodeNotImplemented:      http.StatusNotImplemented,
+	xerror.ErrCodeTooManyRequests:     http.StatusTooManyRequests,
 }
@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"fmt"
+	"go_base/domain"
+	"go_base/xerror"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// history field by field timeline of the row /<resource>/:id/history
+func history(ctx echo.Context, find func(ctx echo.Context, id uuid.UUID) (*domain.Pagination[domain.LogHistory], error)) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidInput(fmt.Errorf("invalid id: %s", id))
+	}
+	result, err := find(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
@@ -16,6 +16,10 @@ func Attach(
 ) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// service account is authenticated by api key, permissions are checked by its scopes
+			if domain.ServiceAccountFromContext(c) != nil {
+				return next(c)
+			}
 			id := domain.UserID(c)
 			if id == "" {
 				return xerror.EForbidden().SetDebugInfo("AttachUser id", fmt.Sprintf("%v", id))
@@ -28,6 +32,9 @@ func Attach(
 				}
 				// ต้องทำให้ส่ง id แล้ว get role แทน
 				c.Set(string(domain.UserKey), user)
+				if err := attachActor(c, getStaff); err != nil {
+					return err
+				}
 				return next(c)
 			}
 			if staff, err := getStaff(c, id); err == nil {
@@ -38,3 +45,20 @@ func Attach(
 		}
 	}
 }
+
+// attachActor staff of the act claim, the impersonation stop when the staff is not active anymore
+func attachActor(c echo.Context, getStaff func(ctx echo.Context, id string) (*domain.Staff, error)) error {
+	actorID, _ := c.Get(string(domain.ActorIDKey)).(string)
+	if actorID == "" {
+		return nil
+	}
+	actor, err := getStaff(c, actorID)
+	if err != nil {
+		return xerror.EForbidden().SetDebugInfo("Attach actor err", fmt.Sprintf("%v", err))
+	}
+	if !actor.IsVerified || actor.Status != domain.StaffActive {
+		return xerror.EForbidden().SetDebugInfo("Attach actor status", fmt.Sprintf("%v", actor.Status))
+	}
+	c.Set(string(domain.ActorKey), actor)
+	return nil
+}
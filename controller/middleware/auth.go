@@ -7,15 +7,39 @@ import (
 	"strings"
 
 	"go_base/domain"
+	"go_base/logger"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 )
 
 // Check token in header and verify it. If token is valid, set user id to context.
 // For auth middleware, and/or verify middleware.
-func Auth(secretAdmin string, secretUser string, cacheFunc func(context.Context, string) (string, error)) echo.MiddlewareFunc {
+// audiences restrict the route group to staff and/or user tokens, empty accept both,
+// a valid token of another audience is rejected with invalid_token_audience.
+// X-API-Key of a service account is accepted only when AudienceService is listed,
+// the account is set to the context instead of the user id.
+// List AudienceService only on read routes restricted by permission, the scopes of the account are checked there.
+// touchFunc update the last seen of the session of the token, its error is only logged.
+func Auth(adminKeys *domain.KeySet, userKeys *domain.KeySet, cacheFunc func(context.Context, string) (string, error),
+	apiKeyFunc func(echo.Context, string) (*domain.ServiceAccount, error),
+	touchFunc func(ctx echo.Context, userID, sessionID uuid.UUID) error, audiences ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if apiKey := c.Request().Header.Get(domain.APIKeyHeader); apiKey != "" {
+				if !lo.Contains(audiences, domain.AudienceService) {
+					return xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrInvalidTokenAudience).
+						SetStatusCode(xerror.ErrCodeUnauthorized).SetDebugInfo("audience", domain.AudienceService)
+				}
+				account, err := apiKeyFunc(c, apiKey)
+				if err != nil {
+					return err
+				}
+				c.Set(string(domain.ServiceAccountKey), account)
+				return next(c)
+			}
+
 			header := c.Request().Header.Get(domain.AuthHeaderKeyUser)
 			if header == "" {
 				header = c.Request().Header.Get(domain.AuthHeaderKeyStaff)
@@ -25,18 +49,22 @@ func Auth(secretAdmin string, secretUser string, cacheFunc func(context.Context,
 			header = strings.TrimPrefix(header, domain.BearerKey)
 			at = strings.TrimSpace(header)
 			var claims domain.AuthClaims
-			var isUser bool
-			if _, err := domain.ParseToken(at, &claims, secretAdmin); err != nil {
-				_, userErr := domain.ParseToken(at, &claims, secretUser)
-				if userErr != nil {
+			audience := domain.AudienceStaff
+			if _, err := adminKeys.Parse(at, &claims); err != nil {
+				claims = domain.AuthClaims{}
+				if _, userErr := userKeys.Parse(at, &claims); userErr != nil {
 					return xerror.E(xerror.ErrUnauthorized).SetStatusCode(xerror.ErrCodeUnauthorized)
 				}
-				isUser = true
+				audience = domain.AudienceUser
+			}
+			if len(audiences) > 0 && !lo.Contains(audiences, audience) {
+				return xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrInvalidTokenAudience).
+					SetStatusCode(xerror.ErrCodeUnauthorized).SetDebugInfo("audience", audience)
 			}
 			if claims.TokenType != domain.TokenTypeAccess {
 				return xerror.E(xerror.ErrUnauthorized).SetStatusCode(xerror.ErrCodeUnauthorized)
 			}
-			token, err := cacheFunc(c.Request().Context(), fmt.Sprintf(domain.WhitelistAccessTokenCacheKey, claims.UserID))
+			token, err := cacheFunc(c.Request().Context(), fmt.Sprintf(domain.WhitelistAccessTokenCacheKey, claims.UserID, claims.SessionID))
 			if err != nil {
 				if xerror.IsNotFoundError(err) {
 					return xerror.E(xerror.ErrUnauthorized).SetStatusCode(xerror.ErrCodeUnauthorized)
@@ -51,7 +79,19 @@ func Auth(secretAdmin string, secretUser string, cacheFunc func(context.Context,
 				return xerror.E(xerror.ErrUnauthorized).SetStatusCode(xerror.ErrCodeUnauthorized)
 			}
 			c.Set(string(domain.UserIDKey), claims.UserID)
-			c.Set(string(domain.IsUserKey), isUser)
+			c.Set(string(domain.SessionIDKey), claims.SessionID)
+			c.Set(string(domain.IsUserKey), audience == domain.AudienceUser)
+			// impersonation, only user tokens can carry the staff actor
+			if claims.Actor != nil && audience == domain.AudienceUser {
+				c.Set(string(domain.ActorIDKey), claims.Actor.Subject)
+			}
+			if touchFunc != nil {
+				_, userID := domain.GetUUID(claims.UserID)
+				_, sessionID := domain.GetUUID(claims.SessionID)
+				if err := touchFunc(c, userID, sessionID); err != nil {
+					logger.Ctx(c.Request().Context()).Warnw("update session last seen failed", "session_id", claims.SessionID, "error", err)
+				}
+			}
 			return next(c)
 		}
 	}
@@ -0,0 +1,290 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go_base/domain"
+	"go_base/domain/permission"
+	"go_base/xerror"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+)
+
+const testIssuer = "base-services"
+
+// signed like auth.issueToken
+func testAccessToken(t *testing.T, keys *domain.KeySet, issuer, audience, userID string) string {
+	t.Helper()
+	now := time.Now()
+	claims := domain.AuthClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+		TokenType: domain.TokenTypeAccess,
+		UserID:    userID,
+		SessionID: "session",
+	}
+	if audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+	token, err := keys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func testKeySet(t *testing.T, secret, audience string, keys ...*domain.SigningKey) *domain.KeySet {
+	t.Helper()
+	ks, err := domain.NewKeySet(secret, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks.WithAudience(testIssuer, audience)
+}
+
+func TestAuth_Audience(t *testing.T) {
+	_, adminPriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, userPriv, _ := ed25519.GenerateKey(rand.Reader)
+	adminKey, _ := domain.NewSigningKey("admin", adminPriv)
+	userKey, _ := domain.NewSigningKey("user", userPriv)
+
+	keySets := []struct {
+		name      string
+		adminKeys *domain.KeySet
+		userKeys  *domain.KeySet
+	}{
+		// shipped config, the same jwtsecret for staff and user
+		{name: "shared secret", adminKeys: testKeySet(t, "secret", domain.AudienceStaff), userKeys: testKeySet(t, "secret", domain.AudienceUser)},
+		{name: "signing keys", adminKeys: testKeySet(t, "", domain.AudienceStaff, adminKey), userKeys: testKeySet(t, "", domain.AudienceUser, userKey)},
+	}
+	for _, ks := range keySets {
+		staffToken := testAccessToken(t, ks.adminKeys, testIssuer, domain.AudienceStaff, "staff-id")
+		userToken := testAccessToken(t, ks.userKeys, testIssuer, domain.AudienceUser, "user-id")
+		tests := []struct {
+			name      string
+			token     string
+			audiences []string
+			wantCode  string
+			wantUser  bool
+		}{
+			{name: "staff token on staff group", token: staffToken, audiences: []string{domain.AudienceStaff}},
+			{name: "user token on staff group", token: userToken, audiences: []string{domain.AudienceStaff}, wantCode: xerror.ErrInvalidTokenAudience},
+			{name: "user token on user group", token: userToken, audiences: []string{domain.AudienceUser}, wantUser: true},
+			{name: "staff token on user group", token: staffToken, audiences: []string{domain.AudienceUser}, wantCode: xerror.ErrInvalidTokenAudience},
+			{name: "staff token on shared group", token: staffToken},
+			{name: "user token on shared group", token: userToken, wantUser: true},
+			{name: "token without aud", token: testAccessToken(t, ks.adminKeys, testIssuer, "", "staff-id"), wantCode: xerror.ErrCodeUnauthorized},
+			{name: "token of another issuer", token: testAccessToken(t, ks.adminKeys, "other", domain.AudienceStaff, "staff-id"), wantCode: xerror.ErrCodeUnauthorized},
+			{name: "token of unknown audience", token: testAccessToken(t, ks.adminKeys, testIssuer, "partner", "staff-id"), wantCode: xerror.ErrCodeUnauthorized},
+		}
+		for _, tt := range tests {
+			t.Run(ks.name+"/"+tt.name, func(t *testing.T) {
+				// every token is whitelisted, only the claims decide
+				cacheFunc := func(ctx context.Context, key string) (string, error) { return tt.token, nil }
+				var isUser, called bool
+				next := func(c echo.Context) error {
+					called = true
+					isUser, _ = c.Get(string(domain.IsUserKey)).(bool)
+					return nil
+				}
+
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set(domain.AuthHeaderKeyUser, domain.BearerKey+tt.token)
+				c := echo.New().NewContext(req, httptest.NewRecorder())
+				err := Auth(ks.adminKeys, ks.userKeys, cacheFunc, nil, nil, tt.audiences...)(next)(c)
+
+				if tt.wantCode != "" {
+					var xerr *xerror.Xerror
+					if !errors.As(err, &xerr) || xerr.ErrCode != tt.wantCode || xerr.StatusCode != xerror.ErrCodeUnauthorized {
+						t.Fatalf("Auth() error = %v, want %v", err, tt.wantCode)
+					}
+					if called {
+						t.Error("Auth() called next handler")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Auth() error = %v", err)
+				}
+				if isUser != tt.wantUser {
+					t.Errorf("Auth() is_user = %v, want %v", isUser, tt.wantUser)
+				}
+			})
+		}
+	}
+}
+
+func TestAuth_Whitelist(t *testing.T) {
+	adminKeys := testKeySet(t, "secret", domain.AudienceStaff)
+	userKeys := testKeySet(t, "secret", domain.AudienceUser)
+	token := testAccessToken(t, adminKeys, testIssuer, domain.AudienceStaff, "staff-id")
+	tests := []struct {
+		name      string
+		cacheFunc func(ctx context.Context, key string) (string, error)
+		wantErr   bool
+	}{
+		{name: "whitelisted", cacheFunc: func(ctx context.Context, key string) (string, error) {
+			if key != fmt.Sprintf(domain.WhitelistAccessTokenCacheKey, "staff-id", "session") {
+				return "", xerror.ENotFound()
+			}
+			return token, nil
+		}},
+		{name: "logged out", cacheFunc: func(ctx context.Context, key string) (string, error) { return "", xerror.ENotFound() }, wantErr: true},
+		{name: "replaced by new login", cacheFunc: func(ctx context.Context, key string) (string, error) { return "other", nil }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(domain.AuthHeaderKeyStaff, domain.BearerKey+token)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			// the session of an accepted token is touched, a failed touch does not reject the request
+			touched := 0
+			touchFunc := func(c echo.Context, userID, sessionID uuid.UUID) error {
+				touched++
+				return errors.New("touch failed")
+			}
+			err := Auth(adminKeys, userKeys, tt.cacheFunc, nil, touchFunc, domain.AudienceStaff)(func(c echo.Context) error { return nil })(c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Auth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if wantTouched := lo.Ternary(tt.wantErr, 0, 1); touched != wantTouched {
+				t.Errorf("Auth() touched = %v, want %v", touched, wantTouched)
+			}
+		})
+	}
+}
+
+func TestAuth_APIKey(t *testing.T) {
+	adminKeys := testKeySet(t, "secret", domain.AudienceStaff)
+	userKeys := testKeySet(t, "secret", domain.AudienceUser)
+	account := &domain.ServiceAccount{Name: "reporting", Scopes: []string{"admin.developer.view.true"}}
+	apiKeyFunc := func(c echo.Context, apiKey string) (*domain.ServiceAccount, error) {
+		if apiKey != "valid.key" {
+			return nil, xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrInvalidAPIKey).SetStatusCode(xerror.ErrCodeUnauthorized)
+		}
+		return account, nil
+	}
+	cacheFunc := func(ctx context.Context, key string) (string, error) { return "", xerror.ENotFound() }
+	tests := []struct {
+		name      string
+		apiKey    string
+		audiences []string
+		wantCode  string
+	}{
+		{name: "service route", apiKey: "valid.key", audiences: []string{domain.AudienceStaff, domain.AudienceService}},
+		{name: "invalid key", apiKey: "other.key", audiences: []string{domain.AudienceStaff, domain.AudienceService}, wantCode: xerror.ErrInvalidAPIKey},
+		{name: "staff only route", apiKey: "valid.key", audiences: []string{domain.AudienceStaff}, wantCode: xerror.ErrInvalidTokenAudience},
+		{name: "shared route", apiKey: "valid.key", wantCode: xerror.ErrInvalidTokenAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *domain.ServiceAccount
+			next := func(c echo.Context) error {
+				got = domain.ServiceAccountFromContext(c)
+				return nil
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(domain.APIKeyHeader, tt.apiKey)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			err := Auth(adminKeys, userKeys, cacheFunc, apiKeyFunc, nil, tt.audiences...)(next)(c)
+
+			if tt.wantCode != "" {
+				var xerr *xerror.Xerror
+				if !errors.As(err, &xerr) || xerr.ErrCode != tt.wantCode {
+					t.Fatalf("Auth() error = %v, want %v", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Auth() error = %v", err)
+			}
+			if got != account {
+				t.Errorf("Auth() service account = %v, want %v", got, account)
+			}
+		})
+	}
+}
+
+func TestRestrictPermissions_ServiceAccount(t *testing.T) {
+	account := &domain.ServiceAccount{Name: "reporting", Scopes: []string{"admin.developer.view.true"}}
+	hasPermission := func(ctx echo.Context, roleID *uuid.UUID, requiredPermissions ...string) bool {
+		t.Error("role permission checked for service account")
+		return false
+	}
+	tests := []struct {
+		name       string
+		permission string
+		wantErr    bool
+	}{
+		{name: "in scope", permission: "admin.developer.view.true"},
+		{name: "out of scope", permission: "admin.developer.delete.true", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			c.Set(string(domain.ServiceAccountKey), account)
+			err := RestrictPermissions(hasPermission)(tt.permission)(func(c echo.Context) error { return nil })(c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RestrictPermissions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRestrictPermissions_Scope(t *testing.T) {
+	roleID := uuid.New()
+	tests := []struct {
+		name      string
+		granted   []string
+		account   *domain.ServiceAccount
+		wantGrant *domain.ScopeGrant
+		wantErr   bool
+	}{
+		{name: "scope all", granted: []string{permission.USER_VIEW_ALL, permission.USER_VIEW_ORG}},
+		{name: "scope organization", granted: []string{permission.USER_VIEW_ORG}, wantGrant: &domain.ScopeGrant{Resource: "user", Scope: domain.PermissionScopeOrg}},
+		{name: "scope organization before own", granted: []string{permission.USER_VIEW_ORG, permission.USER_VIEW_OWN}, wantGrant: &domain.ScopeGrant{Resource: "user", Scope: domain.PermissionScopeOrg}},
+		{name: "scope own", granted: []string{permission.USER_VIEW_OWN}, wantGrant: &domain.ScopeGrant{Resource: "user", Scope: domain.PermissionScopeOwn}},
+		{name: "no permission", wantErr: true},
+		{name: "service account can't have scope organization", account: &domain.ServiceAccount{Scopes: []string{permission.USER_VIEW_ORG}}, wantErr: true},
+		{name: "service account can't have scope own", account: &domain.ServiceAccount{Scopes: []string{permission.USER_VIEW_OWN}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasPermission := func(ctx echo.Context, roleID *uuid.UUID, requiredPermissions ...string) bool {
+				return lo.Some(tt.granted, requiredPermissions)
+			}
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			if tt.account != nil {
+				c.Set(string(domain.ServiceAccountKey), tt.account)
+			} else {
+				c.Set(domain.StaffCtx, &domain.Staff{RoleID: &roleID})
+			}
+			var grant *domain.ScopeGrant
+			err := RestrictPermissions(hasPermission)(permission.USER_VIEW_ALL, permission.USER_VIEW_ORG, permission.USER_VIEW_OWN)(func(c echo.Context) error {
+				grant = domain.ScopeFromContext(c)
+				return nil
+			})(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RestrictPermissions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(grant, tt.wantGrant) {
+				t.Errorf("RestrictPermissions() grant = %v, want %v", grant, tt.wantGrant)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"go_base/domain"
+	"go_base/xerror"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BlockImpersonation reject sensitive actions of the user (password, email, sessions) when the token is issued by impersonation
+func BlockImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if domain.IsImpersonating(c) {
+				return xerror.EForbidden().SetErrorCode(xerror.ErrImpersonationForbidden)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"go_base/domain"
+	"go_base/xerror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// signed like auth.IssueImpersonationToken
+func testImpersonationToken(t *testing.T, keys *domain.KeySet, audience, userID, actorID string) string {
+	t.Helper()
+	now := time.Now()
+	token, err := keys.Sign(domain.AuthClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+		TokenType: domain.TokenTypeAccess,
+		UserID:    userID,
+		SessionID: "session",
+		Actor:     &domain.ActorClaim{Subject: actorID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuth_Impersonation(t *testing.T) {
+	adminKeys := testKeySet(t, "secret", domain.AudienceStaff)
+	userKeys := testKeySet(t, "secret", domain.AudienceUser)
+	tests := []struct {
+		name        string
+		token       string
+		header      string
+		audiences   []string
+		wantActorID string
+	}{
+		{name: "user token with act", token: testImpersonationToken(t, userKeys, domain.AudienceUser, "user-id", "staff-id"), header: domain.AuthHeaderKeyUser, audiences: []string{domain.AudienceUser}, wantActorID: "staff-id"},
+		{name: "user token without act", token: testAccessToken(t, userKeys, testIssuer, domain.AudienceUser, "user-id"), header: domain.AuthHeaderKeyUser, audiences: []string{domain.AudienceUser}},
+		{name: "staff token with act is not impersonation", token: testImpersonationToken(t, adminKeys, domain.AudienceStaff, "staff-id", "other-id"), header: domain.AuthHeaderKeyStaff, audiences: []string{domain.AudienceStaff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheFunc := func(ctx context.Context, key string) (string, error) { return tt.token, nil }
+			var actorID string
+			var impersonating bool
+			next := func(c echo.Context) error {
+				actorID, _ = c.Get(string(domain.ActorIDKey)).(string)
+				impersonating = domain.IsImpersonating(c)
+				return nil
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(tt.header, domain.BearerKey+tt.token)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			if err := Auth(adminKeys, userKeys, cacheFunc, nil, nil, tt.audiences...)(next)(c); err != nil {
+				t.Fatalf("Auth() error = %v", err)
+			}
+			if actorID != tt.wantActorID || impersonating != (tt.wantActorID != "") {
+				t.Errorf("Auth() actor = %q impersonating %v, want %q", actorID, impersonating, tt.wantActorID)
+			}
+		})
+	}
+}
+
+func TestAttach_Actor(t *testing.T) {
+	userID, staffID := uuid.New(), uuid.New()
+	getUser := func(ctx echo.Context, id string) (*domain.User, error) {
+		return &domain.User{BaseModel: domain.BaseModel{ID: userID}, IsVerified: true}, nil
+	}
+	tests := []struct {
+		name    string
+		actor   *domain.Staff
+		wantErr bool
+	}{
+		{name: "active staff", actor: &domain.Staff{BaseModel: domain.BaseModel{ID: staffID}, IsVerified: true, Status: domain.StaffActive}},
+		{name: "inactive staff", actor: &domain.Staff{BaseModel: domain.BaseModel{ID: staffID}, IsVerified: true, Status: domain.StaffInactive}, wantErr: true},
+		{name: "deleted staff", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getStaff := func(ctx echo.Context, id string) (*domain.Staff, error) {
+				if tt.actor == nil || id != staffID.String() {
+					return nil, xerror.ENotFound()
+				}
+				return tt.actor, nil
+			}
+			for name, mw := range map[string]echo.MiddlewareFunc{"Attach": Attach(getUser, getStaff), "Verify": Verify(getUser, getStaff)} {
+				var actor *domain.Staff
+				c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+				c.Set(string(domain.UserIDKey), userID.String())
+				c.Set(string(domain.IsUserKey), true)
+				c.Set(string(domain.ActorIDKey), staffID.String())
+				err := mw(func(c echo.Context) error {
+					actor = domain.ActorFromContext(c)
+					return nil
+				})(c)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("%s() error = %v, wantErr %v", name, err, tt.wantErr)
+				}
+				if !tt.wantErr && actor != tt.actor {
+					t.Errorf("%s() actor = %v, want %v", name, actor, tt.actor)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockImpersonation(t *testing.T) {
+	tests := []struct {
+		name    string
+		actorID string
+		wantErr bool
+	}{
+		{name: "user"},
+		{name: "impersonating staff", actorID: "staff-id", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPut, "/", nil), httptest.NewRecorder())
+			if tt.actorID != "" {
+				c.Set(string(domain.ActorIDKey), tt.actorID)
+			}
+			err := BlockImpersonation()(func(c echo.Context) error { return nil })(c)
+			var xerr *xerror.Xerror
+			if tt.wantErr && (!errors.As(err, &xerr) || xerr.ErrCode != xerror.ErrImpersonationForbidden) {
+				t.Errorf("BlockImpersonation() error = %v, want %v", err, xerror.ErrImpersonationForbidden)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("BlockImpersonation() error = %v", err)
+			}
+		})
+	}
+}
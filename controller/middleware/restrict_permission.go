@@ -2,10 +2,13 @@ package middleware
 
 import (
 	"go_base/domain"
+	"go_base/domain/permission"
 	"go_base/xerror"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 )
 
 func RestrictPermissions(
@@ -15,6 +18,13 @@ func RestrictPermissions(
 		return func(next echo.HandlerFunc) echo.HandlerFunc {
 			return func(c echo.Context) error {
 				// ctx := c.Request().Context()
+				if account := domain.ServiceAccountFromContext(c); account != nil {
+					// the organization and own scopes need a staff
+					if len(permissionNames) == 0 || account.HasScope(lo.Reject(permissionNames, func(name string, _ int) bool { return scopeGrant(name) != nil })...) {
+						return next(c)
+					}
+					return xerror.E(xerror.ErrForbidden).SetStatusCode(xerror.ErrCodeForbidden).SetDebugInfo("msg", "service account scope")
+				}
 				currentStaff := domain.StaffFromContext(c)
 				if currentStaff == nil {
 					return xerror.E(xerror.ErrForbidden).SetStatusCode(xerror.ErrCodeForbidden).SetDebugInfo("msg", "currentStaff is nil")
@@ -25,8 +35,14 @@ func RestrictPermissions(
 				if currentStaff.RoleID == nil {
 					return xerror.E(xerror.ErrForbidden).SetStatusCode(xerror.ErrCodeForbidden).SetDebugInfo("msg", "currentStaff.Role is nil")
 				}
-				if hasPermissionFn(c, currentStaff.RoleID, permissionNames...) {
-					return next(c)
+				// the first permission of the role allow the request, the permission with scope all should be listed first
+				for _, permissionName := range permissionNames {
+					if hasPermissionFn(c, currentStaff.RoleID, permissionName) {
+						if grant := scopeGrant(permissionName); grant != nil {
+							c.Set(string(domain.PermissionScopeKey), grant)
+						}
+						return next(c)
+					}
 				}
 
 				return xerror.E(xerror.ErrForbidden).SetStatusCode(xerror.ErrCodeForbidden)
@@ -34,3 +50,18 @@ func RestrictPermissions(
 		}
 	}
 }
+
+// scopeGrant grant of the permission name {system}.{resource}.{action}.{scope}, nil when the scope is not filtered
+func scopeGrant(permissionName string) *domain.ScopeGrant {
+	args := strings.Split(permissionName, ".")
+	if len(args) != 4 {
+		return nil
+	}
+	switch args[3] {
+	case permission.ScopeOrg:
+		return &domain.ScopeGrant{Resource: args[1], Scope: domain.PermissionScopeOrg}
+	case permission.ScopeOwn:
+		return &domain.ScopeGrant{Resource: args[1], Scope: domain.PermissionScopeOwn}
+	}
+	return nil
+}
@@ -16,6 +16,10 @@ func Verify(
 ) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// service account is authenticated by api key, permissions are checked by its scopes
+			if domain.ServiceAccountFromContext(c) != nil {
+				return next(c)
+			}
 			id := domain.UserID(c)
 			if id == "" {
 				return xerror.EForbidden().SetDebugInfo("Verify User id", fmt.Sprintf("%v", id))
@@ -33,6 +37,9 @@ func Verify(
 
 				// ต้องทำให้ส่ง id แล้ว get role แทน
 				c.Set(string(domain.UserKey), user)
+				if err := attachActor(c, getStaff); err != nil {
+					return err
+				}
 				return next(c)
 			}
 			// Custom logic for staff
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"fmt"
+	"go_base/domain"
+	"go_base/validate"
+	"go_base/xerror"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type OrganizationHandler struct {
+	Services *domain.AllServices
+}
+
+// GET /organizations
+func (h OrganizationHandler) Find(ctx echo.Context) error {
+	m, err := h.Services.Organization.Find(ctx, domain.PaginationFromCtx[domain.Organization](ctx))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, m)
+}
+
+// GET /organizations/:id
+func (h OrganizationHandler) Get(ctx echo.Context) error {
+	idStr, _ := domain.GetUUIDFromParam(ctx, "id")
+	m, err := h.Services.Organization.GetByID(ctx, idStr)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, m)
+}
+
+// POST /organizations
+func (h OrganizationHandler) Create(ctx echo.Context) error {
+	var m domain.OrganizationCreate
+	if err := ctx.Bind(&m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := validate.Struct(m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	organization, err := h.Services.Organization.Create(ctx, &m)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusCreated, organization)
+}
+
+// PUT /organizations/:id
+func (h OrganizationHandler) Update(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidInput(fmt.Errorf("invalid id: %s", id))
+	}
+	var m domain.OrganizationUpdate
+	m.ID = uid
+	if err := ctx.Bind(&m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := validate.Struct(m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := h.Services.Organization.Update(ctx, &m); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// DELETE /organizations/:id
+func (h OrganizationHandler) Delete(ctx echo.Context) error {
+	_, id := domain.GetUUIDFromParam(ctx, "id")
+	if err := h.Services.Organization.Delete(ctx, id); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// GET /organizations/:id/history
+func (h OrganizationHandler) History(ctx echo.Context) error {
+	return history(ctx, h.Services.Organization.History)
+}
@@ -72,3 +72,8 @@ func (h ProjectHandler) Delete(ctx echo.Context) error {
 	}
 	return ctx.NoContent(http.StatusOK)
 }
+
+// GET /projects/:id/history
+func (h ProjectHandler) History(ctx echo.Context) error {
+	return history(ctx, h.Services.IProject.History)
+}
@@ -3,12 +3,14 @@ package controller
 import (
 	"fmt"
 	"go_base/domain"
+	"go_base/domain/permission"
 	"go_base/validate"
 	"go_base/xerror"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 )
 
 type RoleHandler struct {
@@ -25,10 +27,11 @@ func (h RoleHandler) Create(ctx echo.Context) error {
 		return xerror.EInvalidInput(err)
 	}
 	if err := h.Services.Role.Create(ctx, &domain.Role{
-		Type:        role.Type,
-		Name:        role.Name,
-		Description: role.Description,
-		Permissions: role.Permissions,
+		Type:             role.Type,
+		Name:             role.Name,
+		Description:      role.Description,
+		Permissions:      role.Permissions,
+		RequireTwoFactor: role.RequireTwoFactor,
 	}); err != nil {
 		return err
 	}
@@ -65,3 +68,75 @@ func (h RoleHandler) Update(ctx echo.Context) error {
 	}
 	return ctx.NoContent(http.StatusOK)
 }
+
+// Delete role /roles/:id?reassign_to=
+func (h RoleHandler) Delete(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidInput(fmt.Errorf("invalid id: %s", id))
+	}
+	var query domain.RoleDelete
+	if err := ctx.Bind(&query); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := validate.Struct(query); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	var reassignTo *uuid.UUID
+	if query.ReassignTo != nil {
+		reassignTo = lo.ToPtr(uuid.MustParse(*query.ReassignTo))
+	}
+	if err := h.Services.Role.Delete(ctx, uid, reassignTo); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// Clone role /roles/:id/clone
+func (h RoleHandler) Clone(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidInput(fmt.Errorf("invalid id: %s", id))
+	}
+	var clone domain.RoleClone
+	if err := ctx.Bind(&clone); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := validate.Struct(clone); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	role, err := h.Services.Role.Clone(ctx, uid, &clone)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusCreated, role)
+}
+
+// AssignStaffs set the role of the staff /roles/:id/staffs
+func (h RoleHandler) AssignStaffs(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidInput(fmt.Errorf("invalid id: %s", id))
+	}
+	var staffs domain.RoleStaffs
+	if err := ctx.Bind(&staffs); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := validate.Struct(staffs); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := h.Services.Role.AssignStaffs(ctx, uid, staffs.StaffIDs); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// Catalog system -> resource -> action tree of the registered permissions /roles/permissions/catalog
+func (h RoleHandler) Catalog(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, permission.GetCatalog())
+}
+
+// GET /roles/:id/history
+func (h RoleHandler) History(ctx echo.Context) error {
+	return history(ctx, h.Services.Role.History)
+}
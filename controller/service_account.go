@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"fmt"
+	"go_base/domain"
+	"go_base/validate"
+	"go_base/xerror"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ServiceAccountHandler struct {
+	Services *domain.AllServices
+}
+
+// GET /service-accounts
+func (h ServiceAccountHandler) Find(ctx echo.Context) error {
+	m, err := h.Services.ServiceAccount.Find(ctx, domain.PaginationFromCtx[domain.ServiceAccount](ctx))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, m)
+}
+
+// GET /service-accounts/:id
+func (h ServiceAccountHandler) Get(ctx echo.Context) error {
+	idStr, _ := domain.GetUUIDFromParam(ctx, "id")
+	m, err := h.Services.ServiceAccount.GetByID(ctx, idStr)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, m)
+}
+
+// POST /service-accounts the api key is returned only once
+func (h ServiceAccountHandler) Create(ctx echo.Context) error {
+	var m domain.ServiceAccountCreate
+	if err := ctx.Bind(&m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := validate.Struct(m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	account, err := h.Services.ServiceAccount.Create(ctx, &m)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusCreated, account)
+}
+
+// PUT /service-accounts/:id
+func (h ServiceAccountHandler) Update(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidInput(fmt.Errorf("invalid id: %s", id))
+	}
+	var m domain.ServiceAccountUpdate
+	m.ID = uid
+	if err := ctx.Bind(&m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := validate.Struct(m); err != nil {
+		return xerror.EInvalidInput(err)
+	}
+	if err := h.Services.ServiceAccount.Update(ctx, &m); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /service-accounts/:id/rotate the new api key is returned only once
+func (h ServiceAccountHandler) RotateKey(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidInput(fmt.Errorf("invalid id: %s", id))
+	}
+	account, err := h.Services.ServiceAccount.RotateKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, account)
+}
+
+// DELETE /service-accounts/:id
+func (h ServiceAccountHandler) Delete(ctx echo.Context) error {
+	_, id := domain.GetUUIDFromParam(ctx, "id")
+	if err := h.Services.ServiceAccount.Delete(ctx, id); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
@@ -7,6 +7,7 @@ import (
 	"go_base/xerror"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
@@ -141,3 +142,216 @@ func (h StaffHandler) GetLog(ctx echo.Context) error {
 	}
 	return ctx.JSON(http.StatusOK, staffLog)
 }
+
+// POST /staff/token/refresh
+func (h StaffHandler) RefreshToken(ctx echo.Context) error {
+	var req domain.RefreshTokenRequest
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	jwt, err := h.Services.AuthAdmin.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Set(domain.AuthHeaderKeyStaff, domain.BearerKey+jwt.AccessToken)
+	return ctx.JSON(http.StatusOK, jwt)
+}
+
+// POST /staff/logout
+func (h StaffHandler) Logout(ctx echo.Context) error {
+	if err := h.Services.AuthAdmin.Logout(ctx); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /staff/logout/all
+func (h StaffHandler) LogoutAll(ctx echo.Context) error {
+	if err := h.Services.AuthAdmin.LogoutAll(ctx); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /staff/:id/logout
+func (h StaffHandler) ForceLogout(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidParameter(nil)
+	}
+	if err := h.Services.AuthAdmin.ForceLogout(ctx, id); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /staff/login/secure
+func (h StaffHandler) SecureLogin(ctx echo.Context) error {
+	var creds domain.LoginCredentials
+	if err := ctx.Bind(&creds); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(creds); err != nil {
+		return err
+	}
+	ref, err := h.Services.AuthAdmin.SecureLogin(ctx, creds)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, ref)
+}
+
+// POST /staff/login/verify
+func (h StaffHandler) VerifyOTP(ctx echo.Context) error {
+	var req domain.VerifyEmailOTP
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	jwt, err := h.Services.AuthAdmin.VerifyOTP(ctx, req)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Set(domain.AuthHeaderKeyStaff, domain.BearerKey+jwt.AccessToken)
+	return ctx.JSON(http.StatusOK, jwt)
+}
+
+// POST /staff/login/resend
+func (h StaffHandler) ResendOTP(ctx echo.Context) error {
+	var req domain.UsersEmail
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	ref, err := h.Services.AuthAdmin.ResendOTP(ctx, req)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, ref)
+}
+
+// POST /staff/2fa/enroll
+func (h StaffHandler) EnrollTwoFactor(ctx echo.Context) error {
+	var login domain.StaffLogin
+	if err := ctx.Bind(&login); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(login); err != nil {
+		return err
+	}
+	enrollment, err := h.Services.TwoFactor.Enroll(ctx, login)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, enrollment)
+}
+
+// POST /staff/2fa/enable
+func (h StaffHandler) EnableTwoFactor(ctx echo.Context) error {
+	var req domain.TwoFactorEnable
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.TwoFactor.Enable(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /staff/password/forgot
+func (h StaffHandler) ForgotPassword(ctx echo.Context) error {
+	var req domain.PasswordForgot
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.Staff.ForgotPassword(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /staff/password/reset
+func (h StaffHandler) ResetPassword(ctx echo.Context) error {
+	var req domain.PasswordReset
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.Staff.ResetPassword(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /staff/password/change
+func (h StaffHandler) ChangePassword(ctx echo.Context) error {
+	var req domain.PasswordChange
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.Staff.ChangePassword(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// GET /staff/oidc/providers
+func (h StaffHandler) OIDCProviders(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, h.Services.OIDC.Providers())
+}
+
+// GET /staff/oidc/:provider/authorize
+func (h StaffHandler) OIDCAuthorize(ctx echo.Context) error {
+	var req domain.OIDCAuthorize
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	authorization, err := h.Services.OIDC.Authorize(ctx, ctx.Param("provider"), req)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, authorization)
+}
+
+// POST /staff/oidc/:provider/callback
+func (h StaffHandler) OIDCCallback(ctx echo.Context) error {
+	var req domain.OIDCCallback
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	jwt, err := h.Services.OIDC.Callback(ctx, ctx.Param("provider"), req)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Set(domain.AuthHeaderKeyStaff, domain.BearerKey+jwt.AccessToken)
+	return ctx.JSON(http.StatusOK, jwt)
+}
+
+// GET /staffs/:id/history
+func (h StaffHandler) History(ctx echo.Context) error {
+	return history(ctx, h.Services.Staff.History)
+}
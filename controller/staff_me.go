@@ -35,7 +35,7 @@ func (h StaffMeHandler) UpdatePassword(ctx echo.Context) error {
 	}
 
 	if err := h.Services.Staff.UpdatePassword(ctx, staff); err != nil {
-		return xerror.EInvalidInput(nil)
+		return err
 	}
 	return ctx.NoContent(http.StatusOK)
 }
@@ -48,3 +48,35 @@ func (h StaffMeHandler) GetMe(ctx echo.Context) error {
 	}
 	return ctx.JSON(http.StatusOK, staff)
 }
+
+// Get sessions /me/sessions
+func (h StaffMeHandler) FindSessions(ctx echo.Context) error {
+	sessions, err := h.Services.Session.FindMe(ctx)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, sessions)
+}
+
+// Revoke session /me/sessions/:id
+func (h StaffMeHandler) RevokeSession(ctx echo.Context) error {
+	if err := h.Services.Session.RevokeMe(ctx, ctx.Param("id")); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// Disable two factor /me/2fa
+func (h StaffMeHandler) DisableTwoFactor(ctx echo.Context) error {
+	var req domain.TwoFactorCode
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.TwoFactor.Disable(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
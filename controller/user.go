@@ -7,6 +7,7 @@ import (
 	"go_base/xerror"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
@@ -73,6 +74,18 @@ func (h UserHandler) Create(ctx echo.Context) error {
 	return ctx.JSON(http.StatusCreated, s)
 }
 
+// GET /user/:id
+func (h UserHandler) Get(ctx echo.Context) error {
+	user, err := h.Services.User.Get(ctx, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return xerror.ENotFoundResource("user")
+		}
+		return err
+	}
+	return ctx.JSON(http.StatusOK, user)
+}
+
 // DELETE /user/:id
 func (h UserHandler) Delete(ctx echo.Context) error {
 	if err := h.Services.User.Delete(ctx); err != nil {
@@ -153,7 +166,7 @@ func (h UserHandler) UpdatePassword(ctx echo.Context) error {
 	}
 
 	if err := h.Services.User.UpdatePassword(ctx, user); err != nil {
-		return xerror.EInvalidInput(nil)
+		return err
 	}
 	return ctx.NoContent(http.StatusOK)
 }
@@ -210,3 +223,165 @@ func (h UserHandler) GetLogMe(ctx echo.Context) error {
 	}
 	return ctx.JSON(http.StatusOK, user)
 }
+
+// Get sessions /users/me/sessions
+func (h UserHandler) FindSessions(ctx echo.Context) error {
+	sessions, err := h.Services.Session.FindMe(ctx)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, sessions)
+}
+
+// Revoke session /users/me/sessions/:id
+func (h UserHandler) RevokeSession(ctx echo.Context) error {
+	if err := h.Services.Session.RevokeMe(ctx, ctx.Param("id")); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /user/token/refresh
+func (h UserHandler) RefreshToken(ctx echo.Context) error {
+	var req domain.RefreshTokenRequest
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	jwt, err := h.Services.AuthUser.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Set(domain.AuthHeaderKeyUser, domain.BearerKey+jwt.AccessToken)
+	return ctx.JSON(http.StatusOK, jwt)
+}
+
+// POST /users/logout
+func (h UserHandler) Logout(ctx echo.Context) error {
+	if err := h.Services.AuthUser.Logout(ctx); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /users/logout/all
+func (h UserHandler) LogoutAll(ctx echo.Context) error {
+	if err := h.Services.AuthUser.LogoutAll(ctx); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /users/login/secure
+func (h UserHandler) SecureLogin(ctx echo.Context) error {
+	var creds domain.LoginCredentials
+	if err := ctx.Bind(&creds); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(creds); err != nil {
+		return err
+	}
+	ref, err := h.Services.AuthUser.SecureLogin(ctx, creds)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, ref)
+}
+
+// POST /users/login/verify
+func (h UserHandler) VerifyOTP(ctx echo.Context) error {
+	var req domain.VerifyEmailOTP
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	jwt, err := h.Services.AuthUser.VerifyOTP(ctx, req)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Set(domain.AuthHeaderKeyUser, domain.BearerKey+jwt.AccessToken)
+	return ctx.JSON(http.StatusOK, jwt)
+}
+
+// POST /users/login/resend
+func (h UserHandler) ResendOTP(ctx echo.Context) error {
+	var req domain.UsersEmail
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	ref, err := h.Services.AuthUser.ResendOTP(ctx, req)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, ref)
+}
+
+// POST /users/password/forgot
+func (h UserHandler) ForgotPassword(ctx echo.Context) error {
+	var req domain.PasswordForgot
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.User.ForgotPassword(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /users/password/reset
+func (h UserHandler) ResetPassword(ctx echo.Context) error {
+	var req domain.PasswordReset
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.User.ResetPassword(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /users/password/change
+func (h UserHandler) ChangePassword(ctx echo.Context) error {
+	var req domain.PasswordChange
+	if err := ctx.Bind(&req); err != nil {
+		return xerror.EInvalidInput(nil)
+	}
+	if err := validate.Struct(req); err != nil {
+		return err
+	}
+	if err := h.Services.User.ChangePassword(ctx, req); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// POST /users/:id/impersonate
+func (h UserHandler) Impersonate(ctx echo.Context) error {
+	id, uid := domain.GetUUIDFromParam(ctx, "id")
+	if uid == uuid.Nil {
+		return xerror.EInvalidParameter(nil)
+	}
+	token, err := h.Services.User.Impersonate(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, token)
+}
+
+// GET /users/:id/history
+func (h UserHandler) History(ctx echo.Context) error {
+	return history(ctx, h.Services.User.History)
+}
@@ -12,7 +12,8 @@ import (
 
 func RegisterRoutesAsset(g echoswagger.ApiGroup, cfg *domain.Config) {
 	handler := controller.AssetHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
+	authKey := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff, domain.AudienceService)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
@@ -20,28 +21,34 @@ func RegisterRoutesAsset(g echoswagger.ApiGroup, cfg *domain.Config) {
 	restrict := middleware.RestrictPermissions(cfg.Services.Role.HasPermission)
 
 	// GET /assets
-	g.GET("", handler.Find, auth, attach, verify, restrict(permission.ASSET_VIEW_ALL)).
+	g.GET("", handler.Find, authKey, attach, verify, restrict(permission.ASSET_VIEW_ALL, permission.ASSET_VIEW_ORG, permission.ASSET_VIEW_OWN)).
 		AddParamQueryNested(domain.PaginationSwagger{}).
 		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.Asset]{}, nil)
 
 	// GET /assets/:id
-	g.GET("/:id", handler.Get, auth, attach, verify, restrict(permission.ASSET_VIEW_ALL)).
+	g.GET("/:id", handler.Get, authKey, attach, verify, restrict(permission.ASSET_VIEW_ALL, permission.ASSET_VIEW_ORG, permission.ASSET_VIEW_OWN)).
 		AddParamPath("", "id", "ID").
 		AddResponse(http.StatusOK, "OK", domain.Asset{}, nil)
 
+	// GET /assets/:id/history
+	g.GET("/:id/history", handler.History, authKey, attach, verify, restrict(permission.ASSET_VIEW_ALL, permission.ASSET_VIEW_ORG, permission.ASSET_VIEW_OWN)).
+		AddParamPath("", "id", "ID").
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.LogHistory]{}, nil)
+
 	// POST /assets
 	g.POST("", handler.Create, auth, attach, verify, restrict(permission.ASSET_CREATE_ALL)).
 		AddParamFormNested(domain.AssetCreate{}).
 		AddResponse(http.StatusCreated, "OK", nil, nil)
 
 	// Update /assets/:id
-	g.PUT("/:id", handler.Update, auth, attach, verify, restrict(permission.ASSET_UPDATE_ALL)).
+	g.PUT("/:id", handler.Update, auth, attach, verify, restrict(permission.ASSET_UPDATE_ALL, permission.ASSET_UPDATE_ORG, permission.ASSET_UPDATE_OWN)).
 		AddParamPath("", "id", "ID").
 		AddParamFormNested(domain.AssetUpdate{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
 	// DELETE /assets/:id
-	g.DELETE("/:id", handler.Delete, auth, attach, verify, restrict(permission.ASSET_DELETE_ALL)).
+	g.DELETE("/:id", handler.Delete, auth, attach, verify, restrict(permission.ASSET_DELETE_ALL, permission.ASSET_DELETE_ORG, permission.ASSET_DELETE_OWN)).
 		AddParamPath("", "id", "ID").
 		AddResponse(http.StatusNoContent, "OK", nil, nil)
 
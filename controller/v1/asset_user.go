@@ -11,7 +11,7 @@ import (
 
 func RegisterRoutesAssetUser(g echoswagger.ApiGroup, cfg *domain.Config) {
 	handler := controller.AssetHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceUser)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
@@ -12,7 +12,7 @@ import (
 
 func RegisterRoutesDeveloper(g echoswagger.ApiGroup, cfg *domain.Config) {
 	handler := controller.DeveloperHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
@@ -29,6 +29,12 @@ func RegisterRoutesDeveloper(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddParamPath("", "id", "ID").
 		AddResponse(http.StatusOK, "OK", domain.Developer{}, nil)
 
+	// GET /developers/:id/history
+	g.GET("/:id/history", handler.History, auth, attach, verify).
+		AddParamPath("", "id", "ID").
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.LogHistory]{}, nil)
+
 	// POST /developers
 	g.POST("", handler.Create, auth, attach, verify, restrict(permission.DEVELOPER_CREATE_ALL)).
 		AddParamFormNested(domain.DeveloperCreate{}).
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"go_base/controller"
+	"go_base/controller/middleware"
+	"go_base/domain"
+	"go_base/domain/permission"
+	"net/http"
+
+	"github.com/pangpanglabs/echoswagger/v2"
+)
+
+func RegisterRoutesOrganization(g echoswagger.ApiGroup, cfg *domain.Config) {
+	handler := controller.OrganizationHandler{Services: cfg.Services}
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
+	authKey := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff, domain.AudienceService)
+	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
+	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
+
+	g.SetSecurity(domain.AuthHeaderKeyStaff).SetDescription("Organization")
+	restrict := middleware.RestrictPermissions(cfg.Services.Role.HasPermission)
+
+	// GET /organizations
+	g.GET("", handler.Find, authKey, attach, verify, restrict(permission.ORGANIZATION_VIEW_ALL)).
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.Organization]{}, nil)
+
+	// GET /organizations/:id
+	g.GET("/:id", handler.Get, authKey, attach, verify, restrict(permission.ORGANIZATION_VIEW_ALL)).
+		AddParamPath("", "id", "ID").
+		AddResponse(http.StatusOK, "OK", domain.Organization{}, nil)
+
+	// GET /organizations/:id/history
+	g.GET("/:id/history", handler.History, authKey, attach, verify, restrict(permission.ORGANIZATION_VIEW_ALL)).
+		AddParamPath("", "id", "ID").
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.LogHistory]{}, nil)
+
+	// POST /organizations
+	g.POST("", handler.Create, auth, attach, verify, restrict(permission.ORGANIZATION_CREATE_ALL)).
+		AddParamBody(domain.OrganizationCreate{}, "body", "", true).
+		AddResponse(http.StatusCreated, "OK", domain.Organization{}, nil)
+
+	// PUT /organizations/:id
+	g.PUT("/:id", handler.Update, auth, attach, verify, restrict(permission.ORGANIZATION_UPDATE_ALL)).
+		AddParamPath("", "id", "ID").
+		AddParamBody(domain.OrganizationUpdate{}, "body", "", true).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// DELETE /organizations/:id, refused while staff are members
+	g.DELETE("/:id", handler.Delete, auth, attach, verify, restrict(permission.ORGANIZATION_DELETE_ALL)).
+		AddParamPath("", "id", "ID").
+		AddResponse(http.StatusOK, "OK", nil, nil)
+}
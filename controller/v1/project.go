@@ -12,7 +12,7 @@ import (
 
 func RegisterRoutesProject(g echoswagger.ApiGroup, cfg *domain.Config) {
 	handler := controller.ProjectHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
@@ -29,6 +29,12 @@ func RegisterRoutesProject(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddParamPath("", "id", "ID").
 		AddResponse(http.StatusOK, "OK", domain.Project{}, nil)
 
+	// GET /projects/:id/history
+	g.GET("/:id/history", handler.History, auth, attach, verify).
+		AddParamPath("", "id", "ID").
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.LogHistory]{}, nil)
+
 	// POST /projects
 	g.POST("", handler.Create, auth, attach, verify, restrict(permission.PROJECT_CREATE_ALL)).
 		AddParamFormNested(domain.ProjectCreate{}).
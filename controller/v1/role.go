@@ -12,7 +12,7 @@ import (
 
 func RegisterRoutesRole(g echoswagger.ApiGroup, cfg *domain.Config) {
 	h := controller.RoleHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
@@ -23,6 +23,17 @@ func RegisterRoutesRole(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddParamQueryNested(domain.PaginationSwagger{}).
 		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.Role]{}, nil)
 
+	// Permission catalog /roles/permissions/catalog
+	g.GET("/permissions/catalog", h.Catalog, auth, attach, verify).
+		SetDescription("registered permissions with descriptions, the values of a role tree are false or one of the scopes").
+		AddResponse(http.StatusOK, "OK", permission.Catalog{}, nil)
+
+	// History of role /roles/:id/history
+	g.GET("/:id/history", h.History, auth, attach, verify).
+		AddParamPath("", "id", "ID").
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.LogHistory]{}, nil)
+
 	// Create role /roles
 	g.POST("", h.Create, auth, attach, verify, restrict(permission.ROLE_CREATE)).
 		AddParamBody(domain.RoleSwaggerCreate{}, "body", "", true).
@@ -34,4 +45,23 @@ func RegisterRoutesRole(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddParamBody(domain.RoleUpdate{}, "body", "", true).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
+	// Delete role /roles/:id, refused while staff have the role unless reassign_to is set
+	g.DELETE("/:id", h.Delete, auth, attach, verify, restrict(permission.ROLE_DELETE)).
+		AddParamPath("", "id", "ID").
+		AddParamQueryNested(domain.RoleDelete{}).
+		AddResponse(http.StatusOK, "OK", nil, nil).
+		AddResponse(http.StatusConflict, "staff have the role", nil, nil)
+
+	// Clone role /roles/:id/clone
+	g.POST("/:id/clone", h.Clone, auth, attach, verify, restrict(permission.ROLE_CREATE)).
+		AddParamPath("", "id", "ID").
+		AddParamBody(domain.RoleClone{}, "body", "", true).
+		AddResponse(http.StatusCreated, "OK", domain.Role{}, nil)
+
+	// Assign staffs /roles/:id/staffs
+	g.PUT("/:id/staffs", h.AssignStaffs, auth, attach, verify, restrict(permission.ROLE_UPDATE)).
+		AddParamPath("", "id", "ID").
+		AddParamBody(domain.RoleStaffs{}, "body", "", true).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
 }
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"go_base/controller"
+	"go_base/controller/middleware"
+	"go_base/domain"
+	"go_base/domain/permission"
+	"net/http"
+
+	"github.com/pangpanglabs/echoswagger/v2"
+)
+
+func RegisterRoutesServiceAccount(g echoswagger.ApiGroup, cfg *domain.Config) {
+	handler := controller.ServiceAccountHandler{Services: cfg.Services}
+	// service accounts are managed by staff only, api keys can't create other keys
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
+	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
+	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
+
+	g.SetSecurity(domain.AuthHeaderKeyStaff).SetDescription("Service Account")
+	restrict := middleware.RestrictPermissions(cfg.Services.Role.HasPermission)
+
+	// GET /service-accounts
+	g.GET("", handler.Find, auth, attach, verify, restrict(permission.SERVICE_ACCOUNT_VIEW_ALL)).
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.ServiceAccount]{}, nil)
+
+	// GET /service-accounts/:id
+	g.GET("/:id", handler.Get, auth, attach, verify, restrict(permission.SERVICE_ACCOUNT_VIEW_ALL)).
+		AddParamPath("", "id", "ID").
+		AddResponse(http.StatusOK, "OK", domain.ServiceAccount{}, nil)
+
+	// POST /service-accounts
+	g.POST("", handler.Create, auth, attach, verify, restrict(permission.SERVICE_ACCOUNT_CREATE_ALL)).
+		AddParamBody(domain.ServiceAccountCreate{}, "body", "", true).
+		AddResponse(http.StatusCreated, "OK", domain.ServiceAccountAPIKey{}, nil)
+
+	// PUT /service-accounts/:id
+	g.PUT("/:id", handler.Update, auth, attach, verify, restrict(permission.SERVICE_ACCOUNT_UPDATE_ALL)).
+		AddParamPath("", "id", "ID").
+		AddParamBody(domain.ServiceAccountUpdate{}, "body", "", true).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /service-accounts/:id/rotate
+	g.POST("/:id/rotate", handler.RotateKey, auth, attach, verify, restrict(permission.SERVICE_ACCOUNT_UPDATE_ALL)).
+		AddParamPath("", "id", "ID").
+		AddResponse(http.StatusOK, "OK", domain.ServiceAccountAPIKey{}, nil)
+
+	// DELETE /service-accounts/:id
+	g.DELETE("/:id", handler.Delete, auth, attach, verify, restrict(permission.SERVICE_ACCOUNT_DELETE_ALL)).
+		AddParamPath("", "id", "ID").
+		AddResponse(http.StatusOK, "OK", nil, nil)
+}
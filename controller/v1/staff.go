@@ -12,7 +12,8 @@ import (
 
 func RegisterRoutesStaff(g echoswagger.ApiGroup, cfg *domain.Config) {
 	handler := controller.StaffHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
+	authKey := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff, domain.AudienceService)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
@@ -20,14 +21,21 @@ func RegisterRoutesStaff(g echoswagger.ApiGroup, cfg *domain.Config) {
 	restrict := middleware.RestrictPermissions(cfg.Services.Role.HasPermission)
 
 	// GET /staff
-	g.GET("", handler.Find, auth, attach, verify, restrict(permission.STAFF_VIEW_ALL)).
+	g.GET("", handler.Find, authKey, attach, verify, restrict(permission.STAFF_VIEW_ALL, permission.STAFF_VIEW_ORG)).
 		AddParamQueryNested(domain.PaginationSwagger{}).
 		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.Staff]{}, nil)
 
-	// POST /staff/token
-	g.POST("/token", handler.GetToken).
-		AddParamFormNested(domain.StaffGetToken{}).
-		AddResponse(http.StatusOK, "OK", domain.StaffGetTokenResponse{}, nil)
+	// POST /staff/token mock, the token is sent by email outside development
+	if cfg.IsDev() {
+		g.POST("/token", handler.GetToken).
+			AddParamFormNested(domain.StaffGetToken{}).
+			AddResponse(http.StatusOK, "OK", domain.StaffGetTokenResponse{}, nil)
+	}
+
+	// POST /staff/token/refresh
+	g.POST("/token/refresh", handler.RefreshToken).
+		AddParamFormNested(domain.RefreshTokenRequest{}).
+		AddResponse(http.StatusOK, "OK", domain.AuthResult{}, nil)
 
 	// POST /staff
 	g.POST("", handler.Create).
@@ -39,18 +47,87 @@ func RegisterRoutesStaff(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddParamFormNested(domain.StaffLogin{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
+	// POST /staff/login/secure
+	g.POST("/login/secure", handler.SecureLogin).
+		AddParamFormNested(domain.LoginCredentials{}).
+		AddResponse(http.StatusOK, "OK", domain.ReferenceCode{}, nil)
+
+	// POST /staff/login/verify
+	g.POST("/login/verify", handler.VerifyOTP).
+		AddParamFormNested(domain.VerifyEmailOTP{}).
+		AddResponse(http.StatusOK, "OK", domain.AuthResult{}, nil)
+
+	// POST /staff/login/resend
+	g.POST("/login/resend", handler.ResendOTP).
+		AddParamFormNested(domain.UsersEmail{}).
+		AddResponse(http.StatusOK, "OK", domain.ReferenceCode{}, nil)
+
+	// POST /staff/2fa/enroll
+	g.POST("/2fa/enroll", handler.EnrollTwoFactor).
+		AddParamFormNested(domain.StaffLogin{}).
+		AddResponse(http.StatusOK, "OK", domain.TwoFactorEnrollment{}, nil)
+
+	// POST /staff/2fa/enable
+	g.POST("/2fa/enable", handler.EnableTwoFactor).
+		AddParamFormNested(domain.TwoFactorEnable{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// GET /staff/oidc/providers
+	g.GET("/oidc/providers", handler.OIDCProviders).
+		AddResponse(http.StatusOK, "OK", []string{}, nil)
+
+	// GET /staff/oidc/:provider/authorize, redirect the browser to url
+	g.GET("/oidc/:provider/authorize", handler.OIDCAuthorize).
+		AddParamPath("", "provider", "provider name").
+		AddParamQueryNested(domain.OIDCAuthorize{}).
+		AddResponse(http.StatusOK, "OK", domain.OIDCAuthorization{}, nil)
+
+	// POST /staff/oidc/:provider/callback, code and state of the provider redirect
+	g.POST("/oidc/:provider/callback", handler.OIDCCallback).
+		AddParamPath("", "provider", "provider name").
+		AddParamFormNested(domain.OIDCCallback{}).
+		AddResponse(http.StatusOK, "OK", domain.AuthResult{}, nil)
+
+	// POST /staff/password/forgot
+	g.POST("/password/forgot", handler.ForgotPassword).
+		AddParamFormNested(domain.PasswordForgot{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /staff/password/reset
+	g.POST("/password/reset", handler.ResetPassword).
+		AddParamFormNested(domain.PasswordReset{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /staff/password/change
+	g.POST("/password/change", handler.ChangePassword).
+		AddParamFormNested(domain.PasswordChange{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /staff/logout
+	g.POST("/logout", handler.Logout, auth, attach).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /staff/logout/all
+	g.POST("/logout/all", handler.LogoutAll, auth, attach).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /staff/:id/logout
+	g.POST("/:id/logout", handler.ForceLogout, auth, attach, verify, restrict(permission.STAFF_UPDATE_ALL)).
+		AddParamPath("", "id", "staff id").
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
 	// POST /staff/unlock
 	g.POST("/unlock", handler.Unlock, auth, attach, verify, restrict(permission.STAFF_UNLOCK_ALL)).
 		AddParamFormNested(domain.StaffUnlock{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
 	// DELETE /staff/:id
-	g.DELETE("/:id", handler.Delete, auth, attach, verify, restrict(permission.STAFF_DELETE_ALL)).
+	g.DELETE("/:id", handler.Delete, auth, attach, verify, restrict(permission.STAFF_DELETE_ALL, permission.STAFF_DELETE_ORG)).
 		AddParamPath("", "id", "staff id").
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
 	// UPDATE /staff/:id
-	g.PUT("/:id", handler.Update, auth, attach, verify, restrict(permission.STAFF_UPDATE_ALL)).
+	g.PUT("/:id", handler.Update, auth, attach, verify, restrict(permission.STAFF_UPDATE_ALL, permission.STAFF_UPDATE_ORG)).
 		SetSecurity(domain.AuthHeaderKeyStaff).
 		AddParamFormNested(domain.StaffUpdate{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
@@ -61,8 +138,14 @@ func RegisterRoutesStaff(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddResponse(http.StatusOK, "OK", domain.StaffVerifyTokenResponse{}, nil)
 
 	// GET /staff/log
-	g.GET("/log/:id", handler.GetLog, auth, attach, verify, restrict(permission.STAFF_VIEW_ALL)).
+	g.GET("/log/:id", handler.GetLog, authKey, attach, verify, restrict(permission.STAFF_VIEW_ALL)).
 		AddParamQueryNested(domain.PaginationSwagger{}).
 		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.Logs[domain.Staff]]{}, nil)
 
+	// GET /staff/:id/history
+	g.GET("/:id/history", handler.History, authKey, attach, verify, restrict(permission.STAFF_VIEW_ALL, permission.STAFF_VIEW_ORG)).
+		AddParamPath("", "id", "staff id").
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.LogHistory]{}, nil)
+
 }
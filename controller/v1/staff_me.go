@@ -12,7 +12,7 @@ import (
 
 func RegisterRoutesStaffMe(g echoswagger.ApiGroup, cfg *domain.Config) {
 	handler := controller.StaffMeHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
@@ -30,4 +30,18 @@ func RegisterRoutesStaffMe(g echoswagger.ApiGroup, cfg *domain.Config) {
 	// Get me /staff/me
 	g.GET("", handler.GetMe, auth, attach).
 		AddResponse(http.StatusOK, "OK", domain.StaffMe{}, nil)
+
+	// Get sessions /me/sessions
+	g.GET("/sessions", handler.FindSessions, auth, attach).
+		AddResponse(http.StatusOK, "OK", []domain.Session{}, nil)
+
+	// Revoke session /me/sessions/:id
+	g.DELETE("/sessions/:id", handler.RevokeSession, auth, attach).
+		AddParamPath("", "id", "session id").
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// Disable two factor /me/2fa
+	g.DELETE("/2fa", handler.DisableTwoFactor, auth, attach).
+		AddParamFormNested(domain.TwoFactorCode{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
 }
@@ -12,22 +12,33 @@ import (
 
 func RegisterRoutesUser(g echoswagger.ApiGroup, cfg *domain.Config) {
 	handler := controller.UserHandler{Services: cfg.Services}
-	auth := middleware.Auth(cfg.AdminAuthSecret, cfg.UserAuthSecret, cfg.CacheFunc)
+	auth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceUser)
+	authKey := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff, domain.AudienceService)
+	staffAuth := middleware.Auth(cfg.AdminKeys, cfg.UserKeys, cfg.CacheFunc, cfg.Services.ServiceAccount.Authenticate, cfg.Services.Session.Touch, domain.AudienceStaff)
 	attach := middleware.Attach(cfg.Services.User.Get, cfg.Services.Staff.Get)
 	verify := middleware.Verify(cfg.Services.User.Get, cfg.Services.Staff.Get)
 
 	g.SetSecurity(domain.AuthHeaderKeyUser).SetDescription("User")
 	restrict := middleware.RestrictPermissions(cfg.Services.Role.HasPermission)
+	// sensitive actions of the user can't be done by the staff impersonating
+	block := middleware.BlockImpersonation()
 
 	// GET /users
-	g.GET("", handler.Find, auth, attach, verify, restrict(permission.USER_VIEW_ALL)).
+	g.GET("", handler.Find, authKey, attach, verify, restrict(permission.USER_VIEW_ALL, permission.USER_VIEW_ORG, permission.USER_VIEW_OWN)).
 		AddParamQueryNested(domain.PaginationSwagger{}).
 		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.User]{}, nil)
 
-	// POST /users/token
-	g.POST("/token", handler.GetToken).
-		AddParamFormNested(domain.UserGetToken{}).
-		AddResponse(http.StatusOK, "OK", domain.UserGetTokenResponse{}, nil)
+	// POST /users/token mock, the token is sent by email outside development
+	if cfg.IsDev() {
+		g.POST("/token", handler.GetToken).
+			AddParamFormNested(domain.UserGetToken{}).
+			AddResponse(http.StatusOK, "OK", domain.UserGetTokenResponse{}, nil)
+	}
+
+	// POST /users/token/refresh
+	g.POST("/token/refresh", handler.RefreshToken).
+		AddParamFormNested(domain.RefreshTokenRequest{}).
+		AddResponse(http.StatusOK, "OK", domain.AuthResult{}, nil)
 
 	// POST /users
 	g.POST("", handler.Create).
@@ -40,33 +51,52 @@ func RegisterRoutesUser(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
 	// POST /users/unlock
-	g.POST("/unlock", handler.Unlock, auth, attach, verify, restrict(permission.USER_UNLOCK_ALL)).
+	g.POST("/unlock", handler.Unlock, staffAuth, attach, verify, restrict(permission.USER_UNLOCK_ALL)).
 		AddParamFormNested(domain.UserUnlock{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
+	// GET /users/:id
+	g.GET("/:id", handler.Get, authKey, attach, verify, restrict(permission.USER_VIEW_ALL, permission.USER_VIEW_ORG, permission.USER_VIEW_OWN)).
+		SetSecurity(domain.AuthHeaderKeyStaff).
+		AddParamPath("", "id", "user id").
+		AddResponse(http.StatusOK, "OK", domain.User{}, nil)
+
+	// GET /users/:id/history
+	g.GET("/:id/history", handler.History, authKey, attach, verify, restrict(permission.USER_VIEW_ALL, permission.USER_VIEW_ORG, permission.USER_VIEW_OWN)).
+		SetSecurity(domain.AuthHeaderKeyStaff).
+		AddParamPath("", "id", "user id").
+		AddParamQueryNested(domain.PaginationSwagger{}).
+		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.LogHistory]{}, nil)
+
 	// DELETE /users/:id
-	g.DELETE("/:id", handler.Delete, auth, attach, verify, restrict(permission.USER_DELETE_ALL)).
+	g.DELETE("/:id", handler.Delete, staffAuth, attach, verify, restrict(permission.USER_DELETE_ALL, permission.USER_DELETE_ORG, permission.USER_DELETE_OWN)).
 		AddParamPath("", "id", "user id").
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
 	// UPDATE /users/:id
-	g.PUT("/:id", handler.Update, auth, attach, verify, restrict(permission.USER_UPDATE_ALL)).
-		SetSecurity(domain.AuthHeaderKeyUser).
+	g.PUT("/:id", handler.Update, staffAuth, attach, verify, restrict(permission.USER_UPDATE_ALL, permission.USER_UPDATE_ORG, permission.USER_UPDATE_OWN)).
+		SetSecurity(domain.AuthHeaderKeyStaff).
 		AddParamFormNested(domain.UserUpdate{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
+	// POST /users/:id/impersonate, staff only, the user token carry act claim of the staff
+	g.POST("/:id/impersonate", handler.Impersonate, staffAuth, attach, verify, restrict(permission.USER_IMPERSONATE_ALL)).
+		SetSecurity(domain.AuthHeaderKeyStaff).
+		AddParamPath("", "id", "user id").
+		AddResponse(http.StatusOK, "OK", domain.ImpersonationToken{}, nil)
+
 	// POST /users/verify
 	g.POST("/verify", handler.VerifyToken).
 		AddParamFormNested(domain.UserVerifyToken{}).
 		AddResponse(http.StatusOK, "OK", domain.UserVerifyTokenResponse{}, nil)
 
 	// Update Password /users/password
-	g.PUT("/me/password", handler.UpdatePassword, auth, attach).
+	g.PUT("/me/password", handler.UpdatePassword, auth, attach, block).
 		AddParamFormNested(domain.UserUpdatePassword{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
 	// Update Me /users/me
-	g.PUT("/me", handler.UpdateMe, auth, attach).
+	g.PUT("/me", handler.UpdateMe, auth, attach, block).
 		AddParamFormNested(domain.UserUpdate{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
@@ -79,8 +109,55 @@ func RegisterRoutesUser(g echoswagger.ApiGroup, cfg *domain.Config) {
 		AddParamQueryNested(domain.PaginationSwagger{}).
 		AddResponse(http.StatusOK, "OK", domain.Pagination[domain.Logs[domain.User]]{}, nil)
 
+	// POST /users/login/secure
+	g.POST("/login/secure", handler.SecureLogin).
+		AddParamFormNested(domain.LoginCredentials{}).
+		AddResponse(http.StatusOK, "OK", domain.ReferenceCode{}, nil)
+
+	// POST /users/login/verify
+	g.POST("/login/verify", handler.VerifyOTP).
+		AddParamFormNested(domain.VerifyEmailOTP{}).
+		AddResponse(http.StatusOK, "OK", domain.AuthResult{}, nil)
+
+	// POST /users/login/resend
+	g.POST("/login/resend", handler.ResendOTP).
+		AddParamFormNested(domain.UsersEmail{}).
+		AddResponse(http.StatusOK, "OK", domain.ReferenceCode{}, nil)
+
+	// POST /users/password/forgot
+	g.POST("/password/forgot", handler.ForgotPassword).
+		AddParamFormNested(domain.PasswordForgot{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /users/password/reset
+	g.POST("/password/reset", handler.ResetPassword).
+		AddParamFormNested(domain.PasswordReset{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /users/password/change
+	g.POST("/password/change", handler.ChangePassword).
+		AddParamFormNested(domain.PasswordChange{}).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /users/logout
+	g.POST("/logout", handler.Logout, auth, attach).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// POST /users/logout/all
+	g.POST("/logout/all", handler.LogoutAll, auth, attach, block).
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
+	// Get sessions /users/me/sessions
+	g.GET("/me/sessions", handler.FindSessions, auth, attach).
+		AddResponse(http.StatusOK, "OK", []domain.Session{}, nil)
+
+	// Revoke session /users/me/sessions/:id
+	g.DELETE("/me/sessions/:id", handler.RevokeSession, auth, attach, block).
+		AddParamPath("", "id", "session id").
+		AddResponse(http.StatusOK, "OK", nil, nil)
+
 	// Delete /users/ids
-	g.DELETE("/ids", handler.DeleteIds, auth, attach, verify, restrict(permission.USER_DELETE_ALL)).
+	g.DELETE("/ids", handler.DeleteIds, staffAuth, attach, verify, restrict(permission.USER_DELETE_ALL, permission.USER_DELETE_ORG, permission.USER_DELETE_OWN)).
 		AddParamFormNested(domain.Ids{}).
 		AddResponse(http.StatusOK, "OK", nil, nil)
 
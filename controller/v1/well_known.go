@@ -0,0 +1,17 @@
+package v1
+
+import (
+	"go_base/controller"
+	"go_base/domain"
+	"net/http"
+
+	"github.com/pangpanglabs/echoswagger/v2"
+)
+
+func RegisterRoutesWellKnown(g echoswagger.ApiGroup, cfg *domain.Config) {
+	handler := controller.WellKnownHandler{Services: cfg.Services}
+
+	// GET /.well-known/jwks.json public keys to verify access tokens
+	g.GET("/jwks.json", handler.JWKS).
+		AddResponse(http.StatusOK, "OK", domain.JWKS{}, nil)
+}
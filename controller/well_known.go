@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"go_base/domain"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WellKnownHandler struct {
+	Services *domain.AllServices
+}
+
+// Get public signing keys /.well-known/jwks.json
+func (h WellKnownHandler) JWKS(ctx echo.Context) error {
+	jwks, err := h.Services.TokenKey.JWKS(ctx)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, jwks)
+}
@@ -6,8 +6,13 @@ type AllStores struct {
 	Base      *Store
 	Staff     *StaffStore
 	Auth      *AuthStore
+	Session   *SessionStore
+	TwoFactor *TwoFactorStore
+	Password  *PasswordHistoryStore
 	Role      *RoleStore
 	User      *UserStore
+	Service   *ServiceAccountStore
+	Org       *OrganizationStore
 	Developer *BaseStore[domain.Developer, domain.DeveloperUpdate, domain.DeveloperCreate]
 	Project   *BaseStore[domain.Project, domain.ProjectUpdate, domain.ProjectCreate]
 	Asset     *BaseStore[domain.Asset, domain.AssetUpdate, domain.AssetCreate]
@@ -34,11 +34,11 @@ func (s *AuthStore) CreateAuth(ctx echo.Context, auth *domain.Auth) error {
 }
 
 func (s *AuthStore) UpdateAuth(ctx echo.Context, userID string, update domain.Auth) error {
-	id, idUUID := domain.GetUUID(userID)
-	if idUUID == uuid.Nil {
+	_, idUUID := domain.GetUUID(userID)
+	if idUUID == uuid.Nil || update.IsZeroID() {
 		return xerror.EInvalidParameter(nil)
 	}
-	if err := s.UpdateWhereID(ctx, &update, id, domain.LoginLog); err != nil {
+	if err := s.UpdateWhereID(ctx, &update, update.ID.String(), domain.LoginLog); err != nil {
 		return err
 	}
 	return nil
@@ -50,8 +50,75 @@ func (s *AuthStore) FindAuth(ctx echo.Context, userID string) (*domain.Auth, err
 		return nil, xerror.EInvalidParameter(nil)
 	}
 	var result domain.Auth
-	if err := s.DB.WithContext(ctx.Request().Context()).Where("user_id = ?", id).First(&result).Error; err != nil {
+	if err := s.conn(ctx).Preload("RefreshToken").Where("user_id = ?", id).First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindRefreshToken find refresh token by token string
+func (s *AuthStore) FindRefreshToken(ctx echo.Context, token string) (*domain.TokenExpires, error) {
+	var result domain.TokenExpires
+	if err := s.conn(ctx).Where("token = ?", token).First(&result).Error; err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
+
+// RotateRefreshToken mark refresh token as used and write refresh log, return false if it was already rotated or revoked
+func (s *AuthStore) RotateRefreshToken(ctx echo.Context, rt *domain.TokenExpires) (bool, error) {
+	rotated := false
+	err := s.WithTx(ctx, func(ctx echo.Context) error {
+		tx := s.conn(ctx).Model(&domain.TokenExpires{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", rt.ID).
+			Update("rotated_at", domain.TimeNow())
+		if tx.Error != nil {
+			return tx.Error
+		}
+		if rotated = tx.RowsAffected == 1; !rotated {
+			return nil
+		}
+		return s.WriteLog(ctx, &domain.Auth{UserID: rt.UserID}, domain.RefreshTokenLog)
+	})
+	return rotated, err
+}
+
+// RevokeTokenFamily revoke every refresh token issued in the same family and its session
+func (s *AuthStore) RevokeTokenFamily(ctx echo.Context, rt *domain.TokenExpires) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		db := s.conn(ctx).Model(&domain.TokenExpires{}).Where("revoked_at IS NULL")
+		if rt.FamilyID != uuid.Nil {
+			db = db.Where("family_id = ?", rt.FamilyID)
+		} else {
+			db = db.Where("id = ?", rt.ID)
+		}
+		if err := db.Update("revoked_at", domain.TimeNow()).Error; err != nil {
+			return err
+		}
+		// the family is the session of the login
+		if rt.FamilyID != uuid.Nil {
+			if err := s.conn(ctx).Model(&domain.Session{}).
+				Where("id = ? AND revoked_at IS NULL", rt.FamilyID).
+				Update("revoked_at", domain.TimeNow()).Error; err != nil {
+				return err
+			}
+		}
+		return s.WriteLog(ctx, &domain.Auth{UserID: rt.UserID}, domain.RefreshTokenReuseLog)
+	})
+}
+
+// ClearRefreshToken detach the stored refresh token of the user and write logout log
+//
+//	sessionID uuid.Nil clear the refresh token of any session
+func (s *AuthStore) ClearRefreshToken(ctx echo.Context, userID, sessionID uuid.UUID) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		db := s.conn(ctx).Model(&domain.Auth{}).Where("user_id = ?", userID)
+		if sessionID != uuid.Nil {
+			db = db.Where("token_expires_id IN (?)", s.DB.Model(&domain.TokenExpires{}).Select("id::text").Where("family_id = ?", sessionID))
+		}
+		if err := db.Update("token_expires_id", nil).Error; err != nil {
+			return err
+		}
+		return s.WriteLog(ctx, &domain.Auth{UserID: userID}, domain.LogoutLog)
+	})
+}
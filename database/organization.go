@@ -0,0 +1,44 @@
+package database
+
+import (
+	"go_base/domain"
+	"go_base/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type OrganizationStore struct {
+	*BaseStore[domain.Organization, domain.OrganizationUpdate, domain.Organization]
+}
+
+func NewOrganizationStore(db *gorm.DB, allStorage *storage.AllStorage) *OrganizationStore {
+	return &OrganizationStore{NewBaseStore[domain.Organization, domain.OrganizationUpdate, domain.Organization](db, &BaseStoreConfig{WriteChangelog: true}, allStorage)}
+}
+
+// CountStaff members of the organization
+func (s *OrganizationStore) CountStaff(ctx echo.Context, id uuid.UUID) (int64, error) {
+	var count int64
+	if err := s.conn(ctx).Model(&domain.Staff{}).
+		Where("organization_id = ?", id).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *OrganizationStore) Find(ctx echo.Context, pagination domain.Pagination[domain.Organization]) (*domain.Pagination[domain.Organization], error) {
+	organizations, err := s.BaseStore.Find(ctx, pagination)
+	if err != nil {
+		return nil, err
+	}
+	for i := range organizations.Items {
+		count, err := s.CountStaff(ctx, organizations.Items[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		organizations.Items[i].CountStaff = &count
+	}
+	return organizations, nil
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"go_base/domain"
+	"go_base/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryStore struct {
+	*BaseStore[domain.PasswordHistory, domain.PasswordHistory, domain.PasswordHistory]
+}
+
+func NewPasswordHistoryStore(db *gorm.DB, allStorage *storage.AllStorage) *PasswordHistoryStore {
+	return &PasswordHistoryStore{NewBaseStore[domain.PasswordHistory, domain.PasswordHistory, domain.PasswordHistory](db, &BaseStoreConfig{}, allStorage)}
+}
+
+// FindRecent last n passwords of the user, newest first
+func (s *PasswordHistoryStore) FindRecent(ctx echo.Context, userID uuid.UUID, n int) ([]domain.PasswordHistory, error) {
+	var result []domain.PasswordHistory
+	if err := s.conn(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(n).
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Prune delete every password of the user except the last keep
+func (s *PasswordHistoryStore) Prune(ctx echo.Context, userID uuid.UUID, keep int) error {
+	recent := s.DB.Model(&domain.PasswordHistory{}).
+		Select("id").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(keep)
+	return s.conn(ctx).Unscoped().
+		Where("user_id = ? AND id NOT IN (?)", userID, recent).
+		Delete(&domain.PasswordHistory{}).Error
+}
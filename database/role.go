@@ -1,41 +1,123 @@
 package database
 
 import (
+	"errors"
 	"go_base/domain"
 	"go_base/storage"
+	"go_base/xerror"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RoleStore struct {
 	*BaseStore[domain.Role, domain.RoleUpdate, domain.Role]
+
+	// logs of the staff moved between roles, the table is migrated by StaffStore
+	staffs *BaseStore[domain.Staff, domain.StaffUpdate, domain.StaffCreate]
 }
 
 func NewRoleStore(db *gorm.DB, allStorage *storage.AllStorage) *RoleStore {
 	return &RoleStore{
 		BaseStore: NewBaseStore[domain.Role, domain.RoleUpdate, domain.Role](db, &BaseStoreConfig{WriteChangelog: true}, allStorage),
+		staffs:    &BaseStore[domain.Staff, domain.StaffUpdate, domain.StaffCreate]{DB: db, cfg: &BaseStoreConfig{WriteChangelog: true}, cache: allStorage.Cache, allStorage: allStorage},
 	}
 }
 
 func (s *RoleStore) GetRolesByRoleIDs(ctx echo.Context, roleIDs []uuid.UUID) ([]*domain.Role, error) {
 	var roles []*domain.Role
-	if err := s.DB.WithContext(ctx.Request().Context()).Where("id IN ?", roleIDs).Find(&roles).Error; err != nil {
+	if err := s.conn(ctx).Where("id IN ?", roleIDs).Find(&roles).Error; err != nil {
 		return nil, err
 	}
 	return roles, nil
 }
 
-func (s *RoleStore) UpdateStaffPermissions(ctx echo.Context, staffID uuid.UUID, roleIDs []uuid.UUID) error {
-	return s.DB.WithContext(ctx.Request().Context()).Model(&domain.Staff{
-		BaseModel: domain.BaseModel{ID: staffID},
-	}).Association("Roles").Replace(roleIDs)
+// CountStaff staff of the role
+func (s *RoleStore) CountStaff(ctx echo.Context, roleID uuid.UUID) (int64, error) {
+	var count int64
+	if err := s.conn(ctx).Model(&domain.Staff{}).
+		Where("role_id = ?", roleID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// AssignStaffs set the role of the staff, not_found with missing_ids when one of the staff does not exist
+func (s *RoleStore) AssignStaffs(ctx echo.Context, roleID uuid.UUID, staffIDs []uuid.UUID) error {
+	staffIDs = lo.Uniq(staffIDs)
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		var staffs []domain.Staff
+		if err := s.conn(ctx).Where("id IN ?", staffIDs).Find(&staffs).Error; err != nil {
+			return err
+		}
+		if len(staffs) != len(staffIDs) {
+			missing, _ := lo.Difference(staffIDs, lo.Map(staffs, func(staff domain.Staff, _ int) uuid.UUID { return staff.ID }))
+			return xerror.ENotFound().SetExtraInfo("missing_ids", missing)
+		}
+		return s.moveStaffs(ctx, roleID, staffs)
+	})
+}
+
+/*
+DeleteReassign delete the role
+
+	reassignTo nil: refuse with conflict and count_staff while staff have the role,
+	reassignTo set: move the staff of the role to reassignTo in the same transaction
+*/
+func (s *RoleStore) DeleteReassign(ctx echo.Context, id uuid.UUID, reassignTo *uuid.UUID) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		tx := s.conn(ctx)
+		var role domain.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&role, "id = ?", id).Error; err != nil {
+			return err
+		}
+		var staffs []domain.Staff
+		if err := tx.Where("role_id = ?", id).Find(&staffs).Error; err != nil {
+			return err
+		}
+		if len(staffs) > 0 {
+			if reassignTo == nil {
+				return xerror.EConflict(nil).SetMessage("role has %d staff", len(staffs)).SetExtraInfo("count_staff", len(staffs))
+			}
+			if err := tx.First(&domain.Role{}, "id = ?", *reassignTo).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return xerror.EInvalidInputField("reassign_to")
+				}
+				return err
+			}
+			if err := s.moveStaffs(ctx, *reassignTo, staffs); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&role).Error; err != nil {
+			return err
+		}
+		return s.changeLog(ctx, &role, DeleteLog, &role, nil)
+	})
+}
+
+// moveStaffs update role_id of the staff in the transaction of ctx, each staff log its role_id change
+func (s *RoleStore) moveStaffs(ctx echo.Context, roleID uuid.UUID, staffs []domain.Staff) error {
+	ids := lo.Map(staffs, func(staff domain.Staff, _ int) uuid.UUID { return staff.ID })
+	if err := s.conn(ctx).Model(&domain.Staff{}).Where("id IN ?", ids).Update("role_id", roleID).Error; err != nil {
+		return err
+	}
+	for _, before := range staffs {
+		after := before
+		after.RoleID = lo.ToPtr(roleID)
+		if err := s.staffs.changeLog(ctx, &after, UpdateLog, &before, &after); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *RoleStore) Find(ctx echo.Context, pagination domain.Pagination[domain.Role]) (*domain.Pagination[domain.Role], error) {
-	var _db = s.DB.WithContext(ctx.Request().Context())
+	var _db = s.conn(ctx)
 	roles, err := pagination.Paginate(ctx, _db)
 	if err != nil {
 		return nil, err
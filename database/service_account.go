@@ -0,0 +1,38 @@
+package database
+
+import (
+	"go_base/domain"
+	"go_base/storage"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ServiceAccountStore struct {
+	*BaseStore[domain.ServiceAccount, domain.ServiceAccountUpdate, domain.ServiceAccount]
+}
+
+func NewServiceAccountStore(db *gorm.DB, allStorage *storage.AllStorage) *ServiceAccountStore {
+	return &ServiceAccountStore{NewBaseStore[domain.ServiceAccount, domain.ServiceAccountUpdate, domain.ServiceAccount](db, &BaseStoreConfig{WriteChangelog: true}, allStorage)}
+}
+
+// GetByPrefix account of the api key prefix, deleted accounts are not found
+func (s *ServiceAccountStore) GetByPrefix(ctx echo.Context, prefix string) (*domain.ServiceAccount, error) {
+	var result domain.ServiceAccount
+	if err := s.conn(ctx).
+		Where("key_prefix = ?", prefix).
+		First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Touch update last_used_at when it is older than window, without changelog
+func (s *ServiceAccountStore) Touch(ctx echo.Context, id uuid.UUID, window time.Duration) error {
+	now := domain.TimeNow()
+	return s.conn(ctx).Model(&domain.ServiceAccount{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-window)).
+		UpdateColumn("last_used_at", now).Error
+}
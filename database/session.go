@@ -0,0 +1,122 @@
+package database
+
+import (
+	"go_base/domain"
+	"go_base/storage"
+	"go_base/xerror"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type SessionStore struct {
+	*BaseStore[domain.Session, domain.Session, domain.Session]
+}
+
+func NewSessionStore(db *gorm.DB, allStorage *storage.AllStorage) *SessionStore {
+	return &SessionStore{NewBaseStore[domain.Session, domain.Session, domain.Session](db, &BaseStoreConfig{WriteChangelog: true}, allStorage)}
+}
+
+// FindActive find sessions which are not revoked of the user
+func (s *SessionStore) FindActive(ctx echo.Context, userID uuid.UUID) ([]domain.Session, error) {
+	var result []domain.Session
+	if err := s.conn(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_seen_at desc").
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetActive get session which is not revoked of the user
+func (s *SessionStore) GetActive(ctx echo.Context, userID, sessionID uuid.UUID) (*domain.Session, error) {
+	var result domain.Session
+	if err := s.conn(ctx).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindActiveByDevice find sessions which are not revoked of the user on the device
+func (s *SessionStore) FindActiveByDevice(ctx echo.Context, userID uuid.UUID, deviceID string) ([]domain.Session, error) {
+	var result []domain.Session
+	if err := s.conn(ctx).
+		Where("user_id = ? AND device_id = ? AND revoked_at IS NULL", userID, deviceID).
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Touch update last seen of the active session when it is older than window, without changelog
+func (s *SessionStore) Touch(ctx echo.Context, userID, sessionID uuid.UUID, ip string, window time.Duration) error {
+	now := domain.TimeNow()
+	return s.conn(ctx).Model(&domain.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND last_seen_at < ?", sessionID, userID, now.Add(-window)).
+		UpdateColumns(map[string]any{"last_seen_at": now, "ip": ip}).Error
+}
+
+// Revoke revoke the session and every refresh token issued in the session
+func (s *SessionStore) Revoke(ctx echo.Context, userID, sessionID uuid.UUID) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		tx := s.conn(ctx)
+		now := domain.TimeNow()
+		result := tx.Model(&domain.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return xerror.ENotFound()
+		}
+		if err := tx.Model(&domain.TokenExpires{}).
+			Where("family_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return s.writeLog(ctx, &domain.Session{BaseModel: domain.BaseModel{ID: sessionID}, UserID: userID, RevokedAt: &now}, domain.RevokeSessionLog, &sessionID, nil)
+	})
+}
+
+// RevokeAll revoke every active session of the user and their refresh tokens, return revoked session ids
+func (s *SessionStore) RevokeAll(ctx echo.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := s.WithTx(ctx, func(ctx echo.Context) error {
+		tx := s.conn(ctx)
+		now := domain.TimeNow()
+		if err := tx.Model(&domain.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&domain.Session{}).
+			Where("id IN ?", ids).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.TokenExpires{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := s.writeLog(ctx, &domain.Session{BaseModel: domain.BaseModel{ID: id}, UserID: userID, RevokedAt: &now}, domain.RevokeSessionLog, &id, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
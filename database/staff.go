@@ -33,7 +33,7 @@ func (s *StaffStore) GetByEmail(ctx echo.Context, email domain.SensitiveString)
 	var staff domain.Staff
 	staff.Email = email
 
-	if err := s.DB.WithContext(ctx.Request().Context()).First(&staff, "email = ?", staff.Email).Error; err != nil {
+	if err := s.conn(ctx).First(&staff, "email = ?", staff.Email).Error; err != nil {
 		return nil, err
 	}
 	return &staff, nil
@@ -46,25 +46,25 @@ func (s *StaffStore) DeleteExistData(ctx echo.Context, staff *domain.Staff) erro
 func (s *StaffStore) GetMe(ctx echo.Context) (*domain.StaffMe, error) {
 	staff := domain.StaffFromContext(ctx)
 	var staffMe domain.StaffMe
-	if err := s.DB.WithContext(ctx.Request().Context()).Preload(clause.Associations).First(&staffMe, "id = ?", staff.ID).Error; err != nil {
+	if err := s.conn(ctx).Preload(clause.Associations).First(&staffMe, "id = ?", staff.ID).Error; err != nil {
 		return nil, err
 	}
 	return &staffMe, nil
 }
 
 func (s *StaffStore) UpdateTime(ctx echo.Context, staffID uuid.UUID) error {
-	return s.DB.WithContext(ctx.Request().Context()).Table("staffs").Where("id", lo.ToPtr(staffID)).Update("last_login", time.Now()).Error
+	return s.conn(ctx).Table("staffs").Where("id", lo.ToPtr(staffID)).Update("last_login", time.Now()).Error
 }
 
 func (s *StaffStore) UpdateTokenVerify(ctx echo.Context, staffID uuid.UUID) error {
 	var staff domain.Staff
-	if err := s.DB.WithContext(ctx.Request().Context()).First(&staff, "id = ?", staffID).Error; err != nil {
+	if err := s.conn(ctx).First(&staff, "id = ?", staffID).Error; err != nil {
 		return err
 	}
 
 	staff.IsVerified = true
 	staff.VerifyToken = ""
-	if err := s.DB.WithContext(ctx.Request().Context()).Save(&staff).Error; err != nil {
+	if err := s.conn(ctx).Save(&staff).Error; err != nil {
 		return err
 	}
 	return nil
@@ -76,7 +76,7 @@ func (s *StaffStore) CountJsonGroupRole(ctx echo.Context) (*map[string]int64, er
 		return cache, nil
 	}
 	var roles []domain.Role
-	if err := s.DB.WithContext(ctx.Request().Context()).Find(&roles).Error; err != nil {
+	if err := s.conn(ctx).Find(&roles).Error; err != nil {
 		return nil, err
 	}
 
@@ -84,7 +84,7 @@ func (s *StaffStore) CountJsonGroupRole(ctx echo.Context) (*map[string]int64, er
 	countRolesAll := int64(0)
 	for _, role := range roles {
 		var count int64
-		if err := s.DB.WithContext(ctx.Request().Context()).Model(&domain.Staff{}).Where("role_id = ?", role.ID.String()).Count(&count).Error; err != nil {
+		if err := s.conn(ctx).Model(&domain.Staff{}).Where("role_id = ?", role.ID.String()).Count(&count).Error; err != nil {
 			return nil, err
 		}
 		name := strcase.SnakeCase(role.Name)
@@ -15,7 +15,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jtolds/gls"
 	"github.com/labstack/echo/v4"
 	"github.com/samber/lo"
 	"github.com/stoewer/go-strcase"
@@ -92,7 +91,7 @@ func NewBaseStore[T, U, C any](DB *gorm.DB, cfg *BaseStoreConfig, allStorage *st
 
 // find base on store
 func (s *BaseStore[T, U, C]) Find(ctx echo.Context, pagination domain.Pagination[T], ignoreRelations ...string) (*domain.Pagination[T], error) {
-	iDB := s.DB.WithContext(ctx.Request().Context())
+	iDB := s.conn(ctx).Scopes(s.scope(ctx))
 	if len(ignoreRelations) > 0 {
 		iDB = iDB.Omit(ignoreRelations...)
 	}
@@ -116,7 +115,7 @@ func (s *BaseStore[T, U, C]) GetBy(ctx echo.Context, field any, value any) (*T,
 		value = reflect.ValueOf(value).Elem().Interface()
 	}
 
-	if err := s.DB.WithContext(ctx.Request().Context()).Where(filedName+" = ?", value).First(&result).Error; err != nil {
+	if err := s.conn(ctx).Scopes(s.scope(ctx)).Where(filedName+" = ?", value).First(&result).Error; err != nil {
 		return nil, err
 	}
 
@@ -132,7 +131,7 @@ func (s *BaseStore[T, U, C]) GetByID(ctx echo.Context, idStr string) (*T, error)
 
 	var result T
 
-	if err := s.DB.WithContext(ctx.Request().Context()).Preload(clause.Associations).Where("id = ?", id).First(&result).Error; err != nil {
+	if err := s.conn(ctx).Scopes(s.scope(ctx)).Preload(clause.Associations).Where("id = ?", id).First(&result).Error; err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -142,7 +141,7 @@ func (s *BaseStore[T, U, C]) GetByID(ctx echo.Context, idStr string) (*T, error)
 func (s *BaseStore[T, U, C]) GetByKey(ctx echo.Context, key string, value string) (*T, error) {
 	var result T
 
-	if err := s.DB.WithContext(ctx.Request().Context()).Where(key+" = ?", value).First(&result).Error; err != nil {
+	if err := s.conn(ctx).Where(key+" = ?", value).First(&result).Error; err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -150,154 +149,185 @@ func (s *BaseStore[T, U, C]) GetByKey(ctx echo.Context, key string, value string
 
 // create base on store
 func (s *BaseStore[T, U, C]) Create(ctx echo.Context, model *T, typeLog ...string) error {
-	err := s.DB.WithContext(ctx.Request().Context()).Create(model).Error
-	if err != nil {
-		return err
-	}
-
-	if s.cfg.WriteChangelog {
-		log := CreateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
-		}
-		if err := s.WriteLog(ctx, model, log); err != nil {
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		err := s.conn(ctx).Create(model).Error
+		if err != nil {
 			return err
 		}
-	}
 
-	return nil
+		if s.cfg.WriteChangelog {
+			log := CreateLog
+			if len(typeLog) > 0 {
+				log = typeLog[0]
+			}
+			if err := s.changeAfter(ctx, model, log, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 func (s *BaseStore[T, U, C]) CreateC(ctx echo.Context, model *C, typeLog ...string) error {
-	if err := s.DB.WithContext(ctx.Request().Context()).Create(model).Error; err != nil {
-		return err
-	}
-
-	if s.cfg.WriteChangelog {
-		log := CreateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
-		}
-		if err := s.WriteLog(ctx, model, log); err != nil {
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		if err := s.conn(ctx).Create(model).Error; err != nil {
 			return err
 		}
-	}
 
-	return nil
+		if s.cfg.WriteChangelog {
+			log := CreateLog
+			if len(typeLog) > 0 {
+				log = typeLog[0]
+			}
+			if err := s.changeAfter(ctx, model, log, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
 // update base on store
 func (s *BaseStore[T, U, C]) Update(ctx echo.Context, model *T, typeLog ...string) error {
-	err := s.DB.WithContext(ctx.Request().Context()).Updates(model).Error
-	if err != nil {
-		return err
-	}
-
-	if s.cfg.WriteChangelog {
-		log := UpdateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		before, err := s.image(ctx, recordID(model))
+		if err != nil {
+			return err
 		}
-		if err := s.updateLog(ctx, model, log); err != nil {
+		if err := s.scopedUpdate(ctx, s.conn(ctx).Scopes(s.scope(ctx)).Updates(model)); err != nil {
 			return err
 		}
-	}
-	return nil
+
+		if s.cfg.WriteChangelog {
+			log := UpdateLog
+			if len(typeLog) > 0 {
+				log = typeLog[0]
+			}
+			if err := s.changeAfter(ctx, model, log, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (s *BaseStore[T, U, C]) UpdateU(ctx echo.Context, model *U, typeLog ...string) error {
-	err := s.DB.WithContext(ctx.Request().Context()).Updates(model).Error
-	if err != nil {
-		return err
-	}
-
-	if s.cfg.WriteChangelog {
-		log := UpdateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		before, err := s.image(ctx, recordID(model))
+		if err != nil {
+			return err
 		}
-		if err := s.updateULog(ctx, model, log); err != nil {
+		if err := s.scopedUpdate(ctx, s.conn(ctx).Scopes(s.scope(ctx)).Updates(model)); err != nil {
 			return err
 		}
-	}
-	return nil
+
+		if s.cfg.WriteChangelog {
+			log := UpdateLog
+			if len(typeLog) > 0 {
+				log = typeLog[0]
+			}
+			if err := s.changeAfter(ctx, model, log, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // update one field base on store
 func (s *BaseStore[T, U, C]) UpdateOne(ctx echo.Context, model *T, field any, typeLog ...string) error {
-	filedName, err := helper.GetFieldNameByField(model, field)
-	if err != nil {
-		return err
-	}
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		before, err := s.image(ctx, recordID(model))
+		if err != nil {
+			return err
+		}
+		filedName, err := helper.GetFieldNameByField(model, field)
+		if err != nil {
+			return err
+		}
 
-	value, err := helper.GetValueFromStructByFieldName(model, filedName)
-	if err != nil {
-		return err
-	}
-	if value == nil {
-		logger.L().Panic("dev error: value is nil")
-		return nil
-	}
-	if reflect.TypeOf(value).Kind() == reflect.Ptr {
-		value = reflect.ValueOf(value).Elem().Interface()
-	}
-	err = s.DB.WithContext(ctx.Request().Context()).Model(model).Update(filedName, value).Error
-	if err != nil {
-		return err
-	}
-	if s.cfg.WriteChangelog {
-		log := UpdateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
+		value, err := helper.GetValueFromStructByFieldName(model, filedName)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			logger.L().Panic("dev error: value is nil")
+			return nil
 		}
-		if err := s.updateLog(ctx, model, log); err != nil {
+		if reflect.TypeOf(value).Kind() == reflect.Ptr {
+			value = reflect.ValueOf(value).Elem().Interface()
+		}
+		err = s.conn(ctx).Model(model).Update(filedName, value).Error
+		if err != nil {
 			return err
 		}
-	}
-	return nil
+		if s.cfg.WriteChangelog {
+			log := UpdateLog
+			if len(typeLog) > 0 {
+				log = typeLog[0]
+			}
+			if err := s.changeAfter(ctx, model, log, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // update where id
 func (s *BaseStore[T, U, C]) UpdateWhereID(ctx echo.Context, model *T, idStr string, typeLog ...string) error {
-	id, idUUID := domain.GetUUID(idStr)
-	if idUUID == uuid.Nil {
-		return xerror.EInvalidParameter(nil)
-	}
-
-	err := s.DB.WithContext(ctx.Request().Context()).Model(model).Where("id = ?", id).Updates(model).Error
-	if err != nil {
-		return err
-	}
-	if s.cfg.WriteChangelog {
-		log := UpdateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		id, idUUID := domain.GetUUID(idStr)
+		if idUUID == uuid.Nil {
+			return xerror.EInvalidParameter(nil)
 		}
-		if err := s.updateLog(ctx, model, log); err != nil {
+		before, err := s.image(ctx, &idUUID)
+		if err != nil {
 			return err
 		}
-	}
-	return nil
+
+		if err := s.scopedUpdate(ctx, s.conn(ctx).Scopes(s.scope(ctx)).Model(model).Where("id = ?", id).Updates(model)); err != nil {
+			return err
+		}
+		if s.cfg.WriteChangelog {
+			log := UpdateLog
+			if len(typeLog) > 0 {
+				log = typeLog[0]
+			}
+			if err := s.changeAfter(ctx, model, log, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // delete base on store
 func (s *BaseStore[T, U, C]) Delete(ctx echo.Context, id uuid.UUID) error {
-	var model T
-
-	if err := s.DB.WithContext(ctx.Request().Context()).First(&model, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		var model T
+
+		if err := s.conn(ctx).Scopes(s.scope(ctx)).First(&model, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// the row out of the scope is not found instead of silently not deleted
+				if s.scoped(ctx) {
+					return xerror.ENotFound()
+				}
+				return nil
+			}
+			return err
 		}
-		return err
-	}
-	if err := s.DB.WithContext(ctx.Request().Context()).Delete(&model).Error; err != nil {
-		return err
-	}
-	if s.cfg.WriteChangelog {
-		if err := s.deleteLog(ctx, &model); err != nil {
+		if err := s.conn(ctx).Delete(&model).Error; err != nil {
 			return err
 		}
-	}
-	return nil
+		if s.cfg.WriteChangelog {
+			if err := s.changeLog(ctx, &model, DeleteLog, &model, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // delete if exist base on store
@@ -305,47 +335,149 @@ func (s *BaseStore[T, U, C]) Delete(ctx echo.Context, id uuid.UUID) error {
 //  2. if found is soft delete is exist, then delete
 //  3. if not found, then return nil
 func (s *BaseStore[T, U, C]) DeleteIfExist(ctx echo.Context, model *T, field any, value any, typeLog ...string) error {
-	filedName, err := helper.GetFieldNameByField(model, field)
-	if err != nil {
-		return err
-	}
-	var isSoftDelete *gorm.DeletedAt
-	var newModel T
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		filedName, err := helper.GetFieldNameByField(model, field)
+		if err != nil {
+			return err
+		}
+		var isSoftDelete *gorm.DeletedAt
+		var newModel T
 
-	if err := s.DB.WithContext(ctx.Request().Context()).Unscoped().Model(newModel).Select("deleted_at").Where(filedName+" = ?", value).Scan(&isSoftDelete).Error; err != nil {
-		return err
-	}
-	if isSoftDelete != nil && isSoftDelete.Valid {
-		if err := s.DB.Unscoped().Model(newModel).Where(filedName+" = ?", value).Delete(newModel).Error; err != nil {
+		if err := s.conn(ctx).Unscoped().Model(newModel).Select("deleted_at").Where(filedName+" = ?", value).Scan(&isSoftDelete).Error; err != nil {
 			return err
 		}
-		if s.cfg.WriteChangelog {
-			log := DeleteLog
-			if len(typeLog) > 0 {
-				log = typeLog[0]
+		if isSoftDelete != nil && isSoftDelete.Valid {
+			var before T
+			if err := s.conn(ctx).Unscoped().Where(filedName+" = ?", value).First(&before).Error; err != nil {
+				return err
 			}
-			if err := s.deleteLog(ctx, model, log); err != nil {
+			if err := s.conn(ctx).Unscoped().Model(newModel).Where(filedName+" = ?", value).Delete(newModel).Error; err != nil {
 				return err
 			}
+			if s.cfg.WriteChangelog {
+				log := DeleteLog
+				if len(typeLog) > 0 {
+					log = typeLog[0]
+				}
+				if err := s.changeLog(ctx, model, log, &before, nil); err != nil {
+					return err
+				}
+			}
+			return nil
 		}
+
 		return nil
-	}
+	})
+}
+
+// scope filter of the permission scope granted to the request, see domain.WithPermissionScope
+func (s *BaseStore[T, U, C]) scope(ctx echo.Context) func(db *gorm.DB) *gorm.DB {
+	var model T
+	return domain.WithPermissionScope(ctx, model)
+}
 
+func (s *BaseStore[T, U, C]) scoped(ctx echo.Context) bool {
+	var model T
+	return domain.IsScoped(ctx, model)
+}
+
+// scopedUpdate row out of the scope is not found instead of silently not updated
+func (s *BaseStore[T, U, C]) scopedUpdate(ctx echo.Context, result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 && s.scoped(ctx) {
+		return xerror.ENotFound()
+	}
 	return nil
 }
 
 // write log base on store
 func (s *BaseStore[T, U, C]) WriteLog(ctx echo.Context, _model any, action string) error {
-	gls.Go(func() {
+	return s.writeLog(ctx, _model, action, nil, nil)
+}
+
+// changeLog write the log of model with the field changes of its row from before to after
+func (s *BaseStore[T, U, C]) changeLog(ctx echo.Context, model any, action string, before, after any) error {
+	diff, err := domain.DiffModels(before, after)
+	if err != nil {
+		return err
+	}
+	id := recordID(after)
+	if id == nil {
+		id = recordID(before)
+	}
+	return s.writeLog(ctx, model, action, id, diff)
+}
+
+// changeAfter write the log of the create or update of model, the changes are from before to its row after the change
+func (s *BaseStore[T, U, C]) changeAfter(ctx echo.Context, model any, action string, before *T) error {
+	id := recordID(model)
+	if id == nil {
+		id = recordID(before)
+	}
+	after, err := s.image(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.changeLog(ctx, model, action, before, after)
+}
+
+// image the stored row id locked until the end of the transaction, nil without changelog or row,
+// the change itself apply the scope and the fields out of the table (e.g. tmp_password) are not diffed
+func (s *BaseStore[T, U, C]) image(ctx echo.Context, id *uuid.UUID) (*T, error) {
+	if !s.cfg.WriteChangelog || id == nil {
+		return nil, nil
+	}
+	var row T
+	if err := s.conn(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, "id = ?", *id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &row, nil
+}
+
+// recordID ID field of the model, nil when it is not set
+func recordID(model any) *uuid.UUID {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.FieldByName("ID")
+	if !field.IsValid() {
+		return nil
+	}
+	id, ok := field.Interface().(uuid.UUID)
+	if !ok || id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+func (s *BaseStore[T, U, C]) writeLog(ctx echo.Context, _model any, action string, id *uuid.UUID, diff []domain.FieldChange) error {
+	return s.writeLogs(ctx, func(db *gorm.DB) error {
+		var changes datatypes.JSON
+		if diff != nil {
+			b, err := json.Marshal(diff)
+			if err != nil {
+				return err
+			}
+			changes = b
+		}
 		actionFromCtx := domain.GetActionFromContext(ctx)
 		if action == "" {
 			action = actionFromCtx
 		}
 		model, err := convertAnyIntoJSONType(_model)
-		domain.ErrLogGlsGo(ctx, err)
-		_doer := s.getDoer(ctx)
-		doer, err := convertAnyIntoJSONType(_doer)
-		domain.ErrLogGlsGo(ctx, err)
+		if err != nil {
+			return err
+		}
+		doer, err := convertAnyIntoJSONType(s.getDoer(ctx))
+		if err != nil {
+			return err
+		}
 
 		switch _model.(type) {
 		case *T:
@@ -353,27 +485,33 @@ func (s *BaseStore[T, U, C]) WriteLog(ctx echo.Context, _model any, action strin
 			log.Action = action
 			log.Model = model
 			log.Doer = doer
-			if err := s.DB.Create(log).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			log.RecordID = id
+			log.Diff = changes
+			if err := db.Create(log).Error; err != nil {
+				return err
 			}
 		case *U:
 			log := domain.NewLogs[U]()
 			log.Action = action
 			log.Model = model
 			log.Doer = doer
-			if err := s.DB.Create(log).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			log.RecordID = id
+			log.Diff = changes
+			if err := db.Create(log).Error; err != nil {
+				return err
 			}
 		case *C:
 			log := domain.NewLogs[C]()
 			log.Action = action
 			log.Model = model
 			log.Doer = doer
-			if err := s.DB.Create(log).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			log.RecordID = id
+			log.Diff = changes
+			if err := db.Create(log).Error; err != nil {
+				return err
 			}
 		default:
-			domain.ErrLogGlsGo(ctx, errors.New("model type not found"+reflect.TypeOf(_model).String()))
+			return errors.New("model type not found" + reflect.TypeOf(_model).String())
 		}
 
 		var m T
@@ -393,8 +531,10 @@ func (s *BaseStore[T, U, C]) WriteLog(ctx echo.Context, _model any, action strin
 			logStaff.FromTable = &nameOfModel
 			logStaff.Model = model
 			logStaff.Doer = doer
-			if err := s.DB.Create(&logStaff).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			logStaff.RecordID = id
+			logStaff.Diff = changes
+			if err := db.Create(&logStaff).Error; err != nil {
+				return err
 			}
 		} else if userCtx := domain.UserFromContext(ctx); !strings.Contains(nameOfModel, "user") && userCtx != nil {
 			var logUser domain.Logs[domain.User]
@@ -402,13 +542,31 @@ func (s *BaseStore[T, U, C]) WriteLog(ctx echo.Context, _model any, action strin
 			logUser.FromTable = &nameOfModel
 			logUser.Model = model
 			logUser.Doer = doer
-			if err := s.DB.Create(&logUser).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			logUser.RecordID = id
+			logUser.Diff = changes
+			if err := db.Create(&logUser).Error; err != nil {
+				return err
 			}
 		}
-
+		return nil
 	})
-	return nil
+}
+
+// writeLogs write the changelogs in the transaction of ctx so they commit or roll back with the change,
+// a log without a change (e.g. login fail) is written before the response in its own transaction
+func (s *BaseStore[T, U, C]) writeLogs(ctx echo.Context, write func(db *gorm.DB) error) error {
+	if tx := txFromContext(ctx); tx != nil {
+		return write(tx)
+	}
+	return s.conn(ctx).Transaction(write)
+}
+
+// changelog run the change in a transaction with its logs, no committed change miss its log
+func (s *BaseStore[T, U, C]) changelog(ctx echo.Context, fn func(ctx echo.Context) error) error {
+	if !s.cfg.WriteChangelog {
+		return fn(ctx)
+	}
+	return s.WithTx(ctx, fn)
 }
 func (s *BaseStore[T, U, C]) toLogsT(ctx echo.Context, _model T, action string) (*domain.Logs[T], *datatypes.JSON, *datatypes.JSON, error) {
 	log := domain.NewLogs[T]()
@@ -483,9 +641,9 @@ func (s *BaseStore[T, U, C]) toLogsC(ctx echo.Context, _model C, action string)
 	return log, &model, &doer, nil
 }
 func (s *BaseStore[T, U, C]) WriteLogs(ctx echo.Context, modelsT *[]T, modelsU *[]U, modelsC *[]C, action string) error {
-	gls.Go(func() {
+	return s.writeLogs(ctx, func(db *gorm.DB) error {
 		if modelsT == nil && modelsU == nil && modelsC == nil {
-			return
+			return nil
 		}
 		var logsT []domain.Logs[T]
 		var logsU []domain.Logs[U]
@@ -500,7 +658,18 @@ func (s *BaseStore[T, U, C]) WriteLogs(ctx echo.Context, modelsT *[]T, modelsU *
 
 				t, model, doer, err := s.toLogsT(ctx, _model, action)
 				if err != nil {
-					domain.ErrLogGlsGo(ctx, err)
+					return err
+				}
+				t.RecordID = recordID(&_model)
+				// the rows of a batch delete log their whole pre-image
+				if action == DeleteLog {
+					diff, err := domain.DiffModels(&_model, nil)
+					if err != nil {
+						return err
+					}
+					if t.Diff, err = json.Marshal(diff); err != nil {
+						return err
+					}
 				}
 				logsT = append(logsT, *t)
 				doers = append(doers, *doer)
@@ -514,7 +683,7 @@ func (s *BaseStore[T, U, C]) WriteLogs(ctx echo.Context, modelsT *[]T, modelsU *
 
 				t, model, doer, err := s.toLogsU(ctx, _model, action)
 				if err != nil {
-					domain.ErrLogGlsGo(ctx, err)
+					return err
 				}
 				logsU = append(logsU, *t)
 				doers = append(doers, *doer)
@@ -528,7 +697,7 @@ func (s *BaseStore[T, U, C]) WriteLogs(ctx echo.Context, modelsT *[]T, modelsU *
 
 				t, model, doer, err := s.toLogsC(ctx, _model, action)
 				if err != nil {
-					domain.ErrLogGlsGo(ctx, err)
+					return err
 				}
 				logsC = append(logsC, *t)
 				doers = append(doers, *doer)
@@ -567,27 +736,33 @@ func (s *BaseStore[T, U, C]) WriteLogs(ctx echo.Context, modelsT *[]T, modelsU *
 		}
 
 		if len(logsT) > 0 {
-			if err := s.DB.Create(logsT).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			if err := db.Create(logsT).Error; err != nil {
+				return err
 			}
 		}
 		if len(logStaffs) > 0 {
-			if err := s.DB.Create(logStaffs).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			if err := db.Create(logStaffs).Error; err != nil {
+				return err
 			}
 		}
 		if len(logUsers) > 0 {
-			if err := s.DB.Create(logUsers).Error; err != nil {
-				domain.ErrLogGlsGo(ctx, err)
+			if err := db.Create(logUsers).Error; err != nil {
+				return err
 			}
 		}
+		return nil
 	})
-	return nil
 }
 
 func (s *BaseStore[T, U, C]) getDoer(ctx echo.Context) domain.Doer {
 	isUserKey, ok := ctx.Get(string(domain.IsUserKey)).(bool)
 	var doer domain.Doer
+	if account := domain.ServiceAccountFromContext(ctx); account != nil {
+		doer.ID = account.ID
+		doer.Name = account.Name
+		doer.Type = domain.DoerTypeService
+		return doer
+	}
 	if ok && isUserKey {
 		user := domain.UserFromContext(ctx)
 		if user != nil {
@@ -595,6 +770,15 @@ func (s *BaseStore[T, U, C]) getDoer(ctx echo.Context) domain.Doer {
 			doer.Name = domain.UserGetName(user)
 			doer.Email = string(user.Email)
 			doer.Type = domain.DoerTypeUser
+			if actor := domain.ActorFromContext(ctx); actor != nil {
+				doer.Actor = &domain.Doer{
+					ID:     actor.ID,
+					Name:   domain.StaffGetName(actor),
+					Email:  string(actor.Email),
+					Type:   domain.DoerTypeStaff,
+					RoleID: actor.RoleID,
+				}
+			}
 		} else {
 			doer.Type = domain.DoerTypeSystem
 		}
@@ -610,7 +794,7 @@ func (s *BaseStore[T, U, C]) getDoer(ctx echo.Context) domain.Doer {
 		if staff.RoleID != nil {
 			var r domain.Role
 			doer.RoleID = staff.RoleID
-			if err := s.DB.WithContext(ctx.Request().Context()).Model(staff).Association("Roles").Find(&r); err == nil {
+			if err := s.conn(ctx).First(&r, "id = ?", staff.RoleID).Error; err == nil {
 				doer.Role = lo.ToPtr(r)
 			}
 		}
@@ -621,46 +805,6 @@ func (s *BaseStore[T, U, C]) getDoer(ctx echo.Context) domain.Doer {
 	return doer
 }
 
-// update log base on store
-func (s *BaseStore[T, U, C]) updateLog(ctx echo.Context, model *T, typeLog ...string) error {
-	if s.cfg.WriteChangelog {
-		log := UpdateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
-		}
-		if err := s.WriteLog(ctx, model, log); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-func (s *BaseStore[T, U, C]) updateULog(ctx echo.Context, model *U, typeLog ...string) error {
-	if s.cfg.WriteChangelog {
-		log := UpdateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
-		}
-		if err := s.WriteLog(ctx, model, log); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// delete log base on store
-func (s *BaseStore[T, U, C]) deleteLog(ctx echo.Context, model *T, typeLog ...string) error {
-	if s.cfg.WriteChangelog {
-		log := DeleteLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
-		}
-		if err := s.WriteLog(ctx, model, log); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // convert any into json type base on store
 func convertAnyIntoJSONType(value any) (datatypes.JSON, error) {
 	var result datatypes.JSON
@@ -682,12 +826,27 @@ func (s *BaseStore[T, U, C]) FindByID(ctx echo.Context) (*T, error) {
 		return nil, xerror.EInvalidParameter(nil)
 	}
 
-	if err := s.DB.WithContext(ctx.Request().Context()).Where("id = ?", id).First(&result).Error; err != nil {
+	if err := s.conn(ctx).Where("id = ?", id).First(&result).Error; err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
+// History change logs of the row id, the row must be in the scope of ctx, deleted or not
+func (s *BaseStore[T, U, C]) History(ctx echo.Context, id uuid.UUID) (*domain.Pagination[*domain.Logs[T]], error) {
+	var row T
+	if err := s.conn(ctx).Unscoped().Scopes(s.scope(ctx)).First(&row, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	var pagination domain.Pagination[*domain.Logs[T]]
+	// from_table is set on the logs of the doer, the logs written before record_id match on the model
+	DB := s.conn(ctx).
+		Model(&domain.Logs[T]{}).
+		Where("from_table IS NULL").
+		Where("record_id = ? OR (record_id IS NULL AND model->>'id' = ?)", id, id.String())
+	return pagination.Paginate(ctx, DB)
+}
+
 // query log jsonb base on store
 func (s *BaseStore[T, U, C]) LogsQueryModel(ctx echo.Context, model *domain.Logs[T], fields string, value string) (*domain.Pagination[*domain.Logs[T]], error) {
 	// "id": "ee3590d2-512d-4111-8142-63651d70c34a"
@@ -703,7 +862,7 @@ func (s *BaseStore[T, U, C]) LogsQueryModel(ctx echo.Context, model *domain.Logs
 	}
 	field, key := fieldsArr[0], fieldsArr[1]
 	wherCon := fmt.Sprintf("%s->>'%s' = ?", field, key)
-	DB := s.DB.WithContext(ctx.Request().Context()).
+	DB := s.conn(ctx).
 		Model(model).
 		Where(wherCon, value)
 	return pagination.Paginate(ctx, DB)
@@ -711,22 +870,20 @@ func (s *BaseStore[T, U, C]) LogsQueryModel(ctx echo.Context, model *domain.Logs
 
 // DeleteIds
 func (s *BaseStore[T, U, C]) DeleteIds(ctx echo.Context, ids domain.Ids) error {
-	var models []T
-	var count int64
-	if err := s.DB.WithContext(ctx.Request().Context()).Where("id in ?", ids.IDs).Find(&models).Count(&count).Error; err != nil {
-		return err
-	}
-	if count == 0 {
-		return nil
-	}
-	if err := s.DB.WithContext(ctx.Request().Context()).Where("id in ?", ids.IDs).Delete(&models).Error; err != nil {
-		return err
-	}
-	// write log
-	if err := s.WriteLogs(ctx, &models, nil, nil, DeleteLog); err != nil {
-		return err
-	}
-	return nil
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		var models []T
+		if err := s.conn(ctx).Scopes(s.scope(ctx)).Where("id in ?", ids.IDs).Find(&models).Error; err != nil {
+			return err
+		}
+		if len(models) == 0 {
+			return nil
+		}
+		// only the rows in the scope are deleted
+		if err := s.conn(ctx).Delete(&models).Error; err != nil {
+			return err
+		}
+		return s.WriteLogs(ctx, &models, nil, nil, DeleteLog)
+	})
 }
 
 func (s *BaseStore[T, U, C]) getCache(ctx echo.Context, key string) (*map[string]int64, error) {
@@ -756,14 +913,18 @@ func (s *BaseStore[T, U, C]) setCache(ctx echo.Context, key string, value map[st
 
 // Count group in array jsonb
 func (s *BaseStore[T, U, C]) CountJsonGroup(ctx echo.Context, fieldName string) (*map[string]int64, error) {
-	if cache, err := s.getCache(ctx, fmt.Sprintf(groupCache, fieldName)); err == nil {
-		return cache, nil
+	// the cache is shared by all requests, scoped counts are not cached
+	scoped := s.scoped(ctx)
+	if !scoped {
+		if cache, err := s.getCache(ctx, fmt.Sprintf(groupCache, fieldName)); err == nil {
+			return cache, nil
+		}
 	}
 
 	var count []GroupTypeCount
 	var model T
 	selectStatement := fmt.Sprintf("jsonb_array_elements_text(%s) as field, count(*)", fieldName)
-	if err := s.DB.WithContext(ctx.Request().Context()).Model(&model).Select(selectStatement).Group("field").Scan(&count).Error; err != nil {
+	if err := s.conn(ctx).Model(&model).Scopes(s.scope(ctx)).Select(selectStatement).Group("field").Scan(&count).Error; err != nil {
 		return nil, err
 	}
 
@@ -771,6 +932,9 @@ func (s *BaseStore[T, U, C]) CountJsonGroup(ctx echo.Context, fieldName string)
 	for _, c := range count {
 		g[c.Field] = c.Count
 	}
+	if scoped {
+		return &g, nil
+	}
 
 	if err := s.setCache(ctx, fmt.Sprintf(groupCache, fieldName), g); err != nil {
 		return nil, err
@@ -785,7 +949,7 @@ func (s *BaseStore[T, U, C]) FindWithUserID(ctx echo.Context, pagination domain.
 	if user == nil {
 		return nil, xerror.EForbidden()
 	}
-	iDB := s.DB.WithContext(ctx.Request().Context())
+	iDB := s.conn(ctx)
 	if len(ignoreRelations) > 0 {
 		iDB = iDB.Omit(ignoreRelations...)
 	}
@@ -808,7 +972,7 @@ func (s *BaseStore[T, U, C]) GetWithUserID(ctx echo.Context, idStr string) (*T,
 		return nil, xerror.EInvalidParameter(nil)
 	}
 	var result T
-	if err := s.DB.WithContext(ctx.Request().Context()).Scopes(domain.WithUserID(user.ID)).Where("id = ?", id).First(&result).Error; err != nil {
+	if err := s.conn(ctx).Scopes(domain.WithUserID(user.ID)).Where("id = ?", id).First(&result).Error; err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -816,79 +980,92 @@ func (s *BaseStore[T, U, C]) GetWithUserID(ctx echo.Context, idStr string) (*T,
 
 // delete with user id base on store
 func (s *BaseStore[T, U, C]) DeleteWithUserID(ctx echo.Context, id uuid.UUID) error {
-	user := domain.UserFromContext(ctx)
-	if user == nil {
-		return xerror.EForbidden()
-	}
-	var model T
-	if err := s.DB.WithContext(ctx.Request().Context()).Scopes(domain.WithUserID(user.ID)).First(&model, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		user := domain.UserFromContext(ctx)
+		if user == nil {
+			return xerror.EForbidden()
 		}
-		return err
-	}
-	if err := s.DB.WithContext(ctx.Request().Context()).Scopes(domain.WithUserID(user.ID)).Delete(&model).Error; err != nil {
-		return err
-	}
-	if s.cfg.WriteChangelog {
-		if err := s.deleteLog(ctx, &model); err != nil {
+		var model T
+		if err := s.conn(ctx).Scopes(domain.WithUserID(user.ID)).First(&model, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
 			return err
 		}
-	}
-	return nil
+		if err := s.conn(ctx).Scopes(domain.WithUserID(user.ID)).Delete(&model).Error; err != nil {
+			return err
+		}
+		if s.cfg.WriteChangelog {
+			if err := s.changeLog(ctx, &model, DeleteLog, &model, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // delete if exist with user id base on store
 func (s *BaseStore[T, U, C]) DeleteIfExistWithUserID(ctx echo.Context, model *T, field any, value any, typeLog ...string) error {
-	user := domain.UserFromContext(ctx)
-	if user == nil {
-		return xerror.EForbidden()
-	}
-	filedName, err := helper.GetFieldNameByField(model, field)
-	if err != nil {
-		return err
-	}
-	var isSoftDelete *gorm.DeletedAt
-	var newModel T
-	if err := s.DB.WithContext(ctx.Request().Context()).Unscoped().Model(newModel).Select("deleted_at").Where(filedName+" = ?", value).Scan(&isSoftDelete).Error; err != nil {
-		return err
-	}
-	if isSoftDelete != nil && isSoftDelete.Valid {
-		if err := s.DB.Unscoped().Model(newModel).Where(filedName+" = ?", value).Delete(newModel).Error; err != nil {
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		user := domain.UserFromContext(ctx)
+		if user == nil {
+			return xerror.EForbidden()
+		}
+		filedName, err := helper.GetFieldNameByField(model, field)
+		if err != nil {
 			return err
 		}
-		if s.cfg.WriteChangelog {
-			log := DeleteLog
-			if len(typeLog) > 0 {
-				log = typeLog[0]
+		var isSoftDelete *gorm.DeletedAt
+		var newModel T
+		if err := s.conn(ctx).Unscoped().Model(newModel).Select("deleted_at").Where(filedName+" = ?", value).Scan(&isSoftDelete).Error; err != nil {
+			return err
+		}
+		if isSoftDelete != nil && isSoftDelete.Valid {
+			var before T
+			if err := s.conn(ctx).Unscoped().Where(filedName+" = ?", value).First(&before).Error; err != nil {
+				return err
 			}
-			if err := s.deleteLog(ctx, model, log); err != nil {
+			if err := s.conn(ctx).Unscoped().Model(newModel).Where(filedName+" = ?", value).Delete(newModel).Error; err != nil {
 				return err
 			}
+			if s.cfg.WriteChangelog {
+				log := DeleteLog
+				if len(typeLog) > 0 {
+					log = typeLog[0]
+				}
+				if err := s.changeLog(ctx, model, log, &before, nil); err != nil {
+					return err
+				}
+			}
+			return nil
 		}
 		return nil
-	}
-	return nil
+	})
 }
 
 // update with user id base on store
 func (s *BaseStore[T, U, C]) UpdateWithUserID(ctx echo.Context, model *U, typeLog ...string) error {
-	user := domain.UserFromContext(ctx)
-	if user == nil {
-		return xerror.EForbidden()
-	}
-	err := s.DB.WithContext(ctx.Request().Context()).Scopes(domain.WithUserID(user.ID)).Updates(model).Error
-	if err != nil {
-		return err
-	}
-	if s.cfg.WriteChangelog {
-		log := UpdateLog
-		if len(typeLog) > 0 {
-			log = typeLog[0]
+	return s.changelog(ctx, func(ctx echo.Context) error {
+		user := domain.UserFromContext(ctx)
+		if user == nil {
+			return xerror.EForbidden()
 		}
-		if err := s.updateULog(ctx, model, log); err != nil {
+		before, err := s.image(ctx, recordID(model))
+		if err != nil {
 			return err
 		}
-	}
-	return nil
+		if err := s.conn(ctx).Scopes(domain.WithUserID(user.ID)).Updates(model).Error; err != nil {
+			return err
+		}
+		if s.cfg.WriteChangelog {
+			log := UpdateLog
+			if len(typeLog) > 0 {
+				log = typeLog[0]
+			}
+			if err := s.changeAfter(ctx, model, log, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
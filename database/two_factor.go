@@ -0,0 +1,78 @@
+package database
+
+import (
+	"go_base/domain"
+	"go_base/storage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type TwoFactorStore struct {
+	*BaseStore[domain.TwoFactor, domain.TwoFactor, domain.TwoFactor]
+}
+
+func NewTwoFactorStore(db *gorm.DB, allStorage *storage.AllStorage) *TwoFactorStore {
+	return &TwoFactorStore{NewBaseStore[domain.TwoFactor, domain.TwoFactor, domain.TwoFactor](db, &BaseStoreConfig{
+		WriteChangelog: true,
+	}, allStorage)}
+}
+
+// GetByUserID get two factor of the staff
+func (s *TwoFactorStore) GetByUserID(ctx echo.Context, userID uuid.UUID) (*domain.TwoFactor, error) {
+	var result domain.TwoFactor
+	if err := s.conn(ctx).Where("user_id = ?", userID).First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Replace remove the previous pending enrollment and create the new one
+func (s *TwoFactorStore) Replace(ctx echo.Context, twoFactor *domain.TwoFactor) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		if err := s.conn(ctx).Unscoped().
+			Where("user_id = ? AND enabled_at IS NULL", twoFactor.UserID).
+			Delete(&domain.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		return s.Create(ctx, twoFactor)
+	})
+}
+
+// Enable mark two factor as enabled after the first code is verified
+func (s *TwoFactorStore) Enable(ctx echo.Context, twoFactor *domain.TwoFactor) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		if err := s.conn(ctx).Model(&domain.TwoFactor{}).
+			Where("id = ?", twoFactor.ID).
+			Update("enabled_at", domain.TimeNow()).Error; err != nil {
+			return err
+		}
+		return s.WriteLog(ctx, twoFactor, domain.TwoFactorEnableLog)
+	})
+}
+
+// UpdateRecoveryCodes store remaining hashed recovery codes after one is used
+func (s *TwoFactorStore) UpdateRecoveryCodes(ctx echo.Context, twoFactor *domain.TwoFactor, codes datatypes.JSON) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		if err := s.conn(ctx).Model(&domain.TwoFactor{}).
+			Where("id = ?", twoFactor.ID).
+			Update("recovery_codes", codes).Error; err != nil {
+			return err
+		}
+		return s.WriteLog(ctx, twoFactor, domain.TwoFactorRecoverLog)
+	})
+}
+
+// DeleteByUserID disable two factor of the staff
+func (s *TwoFactorStore) DeleteByUserID(ctx echo.Context, twoFactor *domain.TwoFactor) error {
+	return s.WithTx(ctx, func(ctx echo.Context) error {
+		if err := s.conn(ctx).Unscoped().
+			Where("user_id = ?", twoFactor.UserID).
+			Delete(&domain.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		return s.WriteLog(ctx, twoFactor, domain.TwoFactorDisableLog)
+	})
+}
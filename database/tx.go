@@ -0,0 +1,57 @@
+package database
+
+import (
+	"go_base/domain"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+/*
+WithTx run fn in one transaction
+
+	every store called with the ctx of fn use the transaction, the changelogs are written in it too,
+	fn returning an error or panicking roll back,
+	WithTx inside fn is a savepoint, its error roll back only its own changes when the caller handle it
+*/
+func (s *Store) WithTx(ctx echo.Context, fn func(ctx echo.Context) error) error {
+	return withTx(s.DB, ctx, fn)
+}
+
+// WithTx same as Store.WithTx from a store
+func (s *BaseStore[T, U, C]) WithTx(ctx echo.Context, fn func(ctx echo.Context) error) error {
+	return withTx(s.DB, ctx, fn)
+}
+
+func withTx(db *gorm.DB, ctx echo.Context, fn func(ctx echo.Context) error) error {
+	// gorm begin a savepoint when conn is already a transaction
+	return conn(db, ctx).Transaction(func(tx *gorm.DB) error {
+		parent := ctx.Get(string(domain.TxKey))
+		ctx.Set(string(domain.TxKey), tx)
+		defer ctx.Set(string(domain.TxKey), parent)
+		return fn(ctx)
+	})
+}
+
+// conn the transaction of ctx, else db with the context of the request
+func conn(db *gorm.DB, ctx echo.Context) *gorm.DB {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	return db.WithContext(ctx.Request().Context())
+}
+
+func txFromContext(ctx echo.Context) *gorm.DB {
+	tx, _ := ctx.Get(string(domain.TxKey)).(*gorm.DB)
+	return tx
+}
+
+// Conn the transaction of ctx or the db of the store, services query with it inside WithTx
+func (s *Store) Conn(ctx echo.Context) *gorm.DB {
+	return conn(s.DB, ctx)
+}
+
+// conn the transaction of ctx or the db of the store
+func (s *BaseStore[T, U, C]) conn(ctx echo.Context) *gorm.DB {
+	return conn(s.DB, ctx)
+}
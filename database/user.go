@@ -30,25 +30,25 @@ func (s *UserStore) DeleteExistData(ctx echo.Context, user *domain.User) error {
 func (s *UserStore) GetMe(ctx echo.Context) (*domain.UserMe, error) {
 	staff := domain.UserFromContext(ctx)
 	var staffMe domain.UserMe
-	if err := s.DB.WithContext(ctx.Request().Context()).Preload(clause.Associations).First(&staffMe, "id = ?", staff.ID).Error; err != nil {
+	if err := s.conn(ctx).Preload(clause.Associations).First(&staffMe, "id = ?", staff.ID).Error; err != nil {
 		return nil, err
 	}
 	return &staffMe, nil
 }
 
 func (s *UserStore) UpdateTime(ctx echo.Context, id uuid.UUID) error {
-	return s.DB.WithContext(ctx.Request().Context()).Table("staffs").Where("id", lo.ToPtr(id)).Update("last_login", time.Now()).Error
+	return s.conn(ctx).Table("staffs").Where("id", lo.ToPtr(id)).Update("last_login", time.Now()).Error
 }
 
 func (s *UserStore) UpdateTokenVerify(ctx echo.Context, id uuid.UUID) error {
 	var staff domain.User
-	if err := s.DB.WithContext(ctx.Request().Context()).First(&staff, "id = ?", id).Error; err != nil {
+	if err := s.conn(ctx).First(&staff, "id = ?", id).Error; err != nil {
 		return err
 	}
 
 	staff.IsVerified = true
 	staff.VerifyToken = ""
-	if err := s.DB.WithContext(ctx.Request().Context()).Save(&staff).Error; err != nil {
+	if err := s.conn(ctx).Save(&staff).Error; err != nil {
 		return err
 	}
 	return nil
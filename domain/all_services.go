@@ -18,16 +18,32 @@ type IBaseService[T, U, C any] interface {
 	GetWithUserID(ctx echo.Context, idStr string) (*T, error)
 	UpdateWithUserID(ctx echo.Context, model *U, typeLog ...string) error
 	DeleteWithUserID(ctx echo.Context, id uuid.UUID) error
+	// History field by field timeline of the changes of the row
+	History(ctx echo.Context, id uuid.UUID) (*Pagination[LogHistory], error)
 }
 
 type AllServices struct {
 	Staff      StaffService
 	AuthAdmin  AdminAuthService
 	AuthUser   UserAuthService
+	Session    SessionService
+	TwoFactor  TwoFactorService
+	Mail       MailService
+	Password   PasswordService
+	TokenKey   TokenKeyService
 	Role       RoleService
 	User       UserService
 	IDeveloper IBaseService[Developer, DeveloperUpdate, DeveloperCreate]
 	IProject   IBaseService[Project, ProjectUpdate, ProjectCreate]
 	IAsset     IBaseService[Asset, AssetUpdate, AssetCreate]
 	Asset      IAssetService[Asset, AssetUpdate, AssetCreate]
+
+	// machine to machine clients of X-API-Key
+	ServiceAccount ServiceAccountService
+
+	// staff login with an OpenID provider
+	OIDC OIDCService
+
+	// branch offices of the staff
+	Organization OrganizationService
 }
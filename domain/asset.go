@@ -35,7 +35,7 @@ type Asset struct {
 	// ประเภท
 	Type *string `json:"type,omitempty" gorm:"type:varchar(255);" validate:"omitempty" filter:"assets.type.="`
 	// ราคา (ซื้อ/ขาย)
-	Price *float64 `json:"price,omitempty" gorm:"type:numeric;" validate:"omitempty"`
+	Price *float64 `json:"price,omitempty" gorm:"type:numeric;" validate:"omitempty" perm:"admin.asset.price"`
 }
 
 type AssetCreate struct {
@@ -56,7 +56,7 @@ type AssetCreate struct {
 	Size        *float64 `json:"size,omitempty" validate:"omitempty" form:"size" query:"size"`
 	Zone        *string  `json:"zone,omitempty" validate:"omitempty" form:"zone" query:"zone"`
 	Type        *string  `json:"type,omitempty" validate:"omitempty" form:"type" query:"type"`
-	Price       *float64 `json:"price,omitempty" validate:"omitempty" form:"price" query:"price"`
+	Price       *float64 `json:"price,omitempty" validate:"omitempty" form:"price" query:"price" perm:"admin.asset.price"`
 }
 
 func (AssetCreate) TableName() string {
@@ -79,7 +79,7 @@ type AssetUpdate struct {
 	Size        *float64   `json:"size,omitempty" validate:"omitempty" form:"size" query:"size"`
 	Zone        *string    `json:"zone,omitempty" validate:"omitempty" form:"zone" query:"zone"`
 	Type        *string    `json:"type,omitempty" validate:"omitempty" form:"type" query:"type"`
-	Price       *float64   `json:"price,omitempty" validate:"omitempty" form:"price" query:"price"`
+	Price       *float64   `json:"price,omitempty" validate:"omitempty" form:"price" query:"price" perm:"admin.asset.price"`
 }
 
 func (AssetUpdate) TableName() string {
@@ -12,7 +12,12 @@ import (
 
 var (
 	BearerKey                    = "Bearer "
-	WhitelistAccessTokenCacheKey = "whitelist:access_token:%s"
+	WhitelistAccessTokenCacheKey = "whitelist:access_token:%s:%s" // user id, session id
+
+	// Log
+	RefreshTokenLog      = "refresh_token"
+	RefreshTokenReuseLog = "refresh_token_reuse"
+	LogoutLog            = "logout"
 
 	AuthHeaderKeyStaff = "Authorization_Staff"
 	AuthHeaderKeyUser  = "Authorization"
@@ -30,6 +35,12 @@ type TokenExpires struct {
 	BaseModel
 	Token    string    `json:"token" gorm:"type:text;uniqueIndex"`
 	ExpireAt time.Time `json:"expire_at"`
+
+	// refresh token rotation, every token issued from the same login share the same family
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	FamilyID  uuid.UUID  `json:"family_id" gorm:"type:uuid;index"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
 }
 
 type AuthUpdate struct {
@@ -42,9 +53,43 @@ func (rt TokenExpires) Expired() bool {
 	return TimeNow().After(rt.ExpireAt)
 }
 
+// Rotated reports whether the token was already exchanged for a new pair.
+func (rt TokenExpires) Rotated() bool {
+	return rt.RotatedAt != nil
+}
+
+// Revoked reports whether the token (or its family) was revoked.
+func (rt TokenExpires) Revoked() bool {
+	return rt.RevokedAt != nil
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required" query:"refresh_token" swagger:"desc(refresh_token),required" form:"refresh_token"`
+}
+
+// ReferenceCode is returned by secure login, the otp is sent to email and verified with the reference code
+type ReferenceCode struct {
+	ReferenceCode string    `json:"reference_code"`
+	ExpireAt      time.Time `json:"expire_at"`
+	// only returned when ReturnOTP is enabled (development)
+	OTP string `json:"otp,omitempty"`
+}
+
+type VerifyEmailOTP struct {
+	Email         SensitiveString `json:"email" validate:"required,email" query:"email" swagger:"desc(email),required" form:"email"`
+	ReferenceCode string          `json:"reference_code" validate:"required" query:"reference_code" swagger:"desc(reference_code),required" form:"reference_code"`
+	OTP           string          `json:"otp" validate:"required,numeric,len=6" query:"otp" swagger:"desc(otp),required" form:"otp"`
+	DeviceID      string          `json:"device_id,omitempty" validate:"omitempty,uuid4" query:"device_id" swagger:"desc(device_id)" form:"device_id"`
+	TOTPCode      string          `json:"totp_code,omitempty" query:"totp_code" swagger:"desc(totp or recovery code, staff only)" form:"totp_code"`
+}
+
+type UsersEmail struct {
+	Email SensitiveString `json:"email" validate:"required,email" query:"email" swagger:"desc(email),required" form:"email"`
+}
+
 type LoginCredentials struct {
-	Email    SensitiveString `json:"email" validate:"required,email" `
-	Password string          `json:"password" validate:"required"`
+	Email    SensitiveString `json:"email" validate:"required,email" query:"email" swagger:"desc(email),required" form:"email"`
+	Password string          `json:"password" validate:"required" query:"password" swagger:"desc(password),required" form:"password"`
 }
 
 type AuthResult struct {
@@ -53,6 +98,14 @@ type AuthResult struct {
 	DeviceID     string `json:"device_id,omitempty"`
 }
 
+// aud claim of access / refresh tokens, staff only routes reject user tokens,
+// AudienceService is the X-API-Key of service accounts and is never issued as a token
+const (
+	AudienceStaff   = "staff"
+	AudienceUser    = "user"
+	AudienceService = "service"
+)
+
 type TokenType string
 
 const (
@@ -63,7 +116,16 @@ const (
 type AuthClaims struct {
 	jwt.RegisteredClaims
 	UserID    string    `json:"user_id"`
+	SessionID string    `json:"sid,omitempty"`
 	TokenType TokenType `json:"token_type"`
+
+	// act claim of RFC 8693, set on user tokens issued to a staff by impersonation
+	Actor *ActorClaim `json:"act,omitempty"`
+}
+
+// ActorClaim staff who act as the user of the token
+type ActorClaim struct {
+	Subject string `json:"sub"`
 }
 
 type UserDevice struct {
@@ -85,16 +147,18 @@ type JWTHeader struct {
 
 type AdminAuthService interface {
 	// Login(ctx context.Context, creds LoginCredentials) (*AuthResult, error)
-	// Logout(ctx context.Context, userID string) error
-	// Refresh(ctx context.Context, refreshToken string) (*AuthResult, error)
 	// ClearAdminLoginAttemptCount(ctx context.Context, userID string) error
 	// ClearAdminWhitelistAccessToken(ctx context.Context, userID string) error
-	// SecureLogin(ctx context.Context, creds LoginCredentials) (*ReferenceCode, error)
-	// VerifyOTP(ctx context.Context, req VerifyEmailOTP) (*AuthResult, error)
-	// ResendOTP(ctx context.Context, email UsersEmail) (*ReferenceCode, error)
 	CreateAuth(ctx echo.Context, auth *Auth) error
 	UpdateAuth(ctx echo.Context, userID string, update Auth) error
 	FindAuth(ctx echo.Context, userID string) (*Auth, error)
+	Refresh(ctx echo.Context, refreshToken string) (*AuthResult, error)
+	Logout(ctx echo.Context) error
+	LogoutAll(ctx echo.Context) error
+	SecureLogin(ctx echo.Context, creds LoginCredentials) (*ReferenceCode, error)
+	VerifyOTP(ctx echo.Context, req VerifyEmailOTP) (*AuthResult, error)
+	ResendOTP(ctx echo.Context, email UsersEmail) (*ReferenceCode, error)
+	ForceLogout(ctx echo.Context, staffID string) error
 }
 
 type AdminAuthStore interface {
@@ -106,16 +170,17 @@ type AdminAuthStore interface {
 
 type UserAuthService interface {
 	// Login(ctx context.Context, creds LoginCredentials) (*AuthResult, error)
-	// Logout(ctx context.Context, userID string) error
-	// Refresh(ctx context.Context, refreshToken string) (*AuthResult, error)
 	// ClearUserLoginAttemptCount(ctx context.Context, userID string) error
 	// ClearUserWhitelistAccessToken(ctx context.Context, userID string) error
-	// SecureLogin(ctx context.Context, creds LoginCredentials) (*ReferenceCode, error)
-	// VerifyOTP(ctx context.Context, req VerifyEmailOTP) (*AuthResult, error)
-	// ResendOTP(ctx context.Context, email UsersEmail) (*ReferenceCode, error)
 	CreateAuth(ctx echo.Context, auth *Auth) error
 	UpdateAuth(ctx echo.Context, userID string, update Auth) error
 	FindAuth(ctx echo.Context, userID string) (*Auth, error)
+	Refresh(ctx echo.Context, refreshToken string) (*AuthResult, error)
+	Logout(ctx echo.Context) error
+	LogoutAll(ctx echo.Context) error
+	SecureLogin(ctx echo.Context, creds LoginCredentials) (*ReferenceCode, error)
+	VerifyOTP(ctx echo.Context, req VerifyEmailOTP) (*AuthResult, error)
+	ResendOTP(ctx echo.Context, email UsersEmail) (*ReferenceCode, error)
 }
 
 type UserAuthStore interface {
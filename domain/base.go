@@ -14,6 +14,7 @@ var TableNames []string = []string{
 	"Staff",
 	"Token_expire",
 	"Auth",
+	"Session",
 	"User",
 	"Asset",
 	"Developer",
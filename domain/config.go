@@ -2,11 +2,18 @@ package domain
 
 import "context"
 
+const EnvDevelopment = "development"
+
 type Config struct {
-	Services        *AllServices
-	ENV             string
-	Version         string
-	AdminAuthSecret string
-	UserAuthSecret  string
-	CacheFunc       func(ctx context.Context, key string) (string, error)
+	Services  *AllServices
+	ENV       string
+	Version   string
+	AdminKeys *KeySet
+	UserKeys  *KeySet
+	CacheFunc func(ctx context.Context, key string) (string, error)
+}
+
+// IsDev mock endpoints are registered only in development
+func (c *Config) IsDev() bool {
+	return c.ENV == EnvDevelopment
 }
@@ -19,6 +19,9 @@ var (
 	UserKey          = ContextKey("user")
 
 	IsUserKey = ContextKey("is_user")
+
+	// transaction of database.Store.WithTx, every store use it while it is set
+	TxKey = ContextKey("db_tx")
 )
 
 func UserID(ctx echo.Context) string {
@@ -57,6 +60,14 @@ func UserFromContext(ctx echo.Context) *User {
 	return user
 }
 
+func ServiceAccountFromContext(ctx echo.Context) *ServiceAccount {
+	account, ok := ctx.Get(string(ServiceAccountKey)).(*ServiceAccount)
+	if !ok {
+		return nil
+	}
+	return account
+}
+
 func GetActionFromContext(ctx echo.Context) string {
 	path := ctx.Path()
 	if path == "" {
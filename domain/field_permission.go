@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+/*
+FieldPermissionTag struct tag of the fields which need a permission, e.g. perm:"admin.user.budget"
+
+	the value is {system}.{resource}.{action}, the caller need {system}.{resource}.{action}.true,
+	the field is redacted from responses and its write is rejected without the permission
+*/
+const FieldPermissionTag = "perm"
+
+// FieldAllowFunc the caller has the permission of the perm tag
+type FieldAllowFunc func(perm string) bool
+
+// maxRedactDepth nested models of a response, e.g. Pagination.Items -> User -> Staff
+const maxRedactDepth = 8
+
+// types which have a perm tag in their fields or nested models
+var fieldPermissionTypes sync.Map
+
+// RedactFields zero the perm fields of v which are not allowed, v is a pointer to a model, a slice or a Pagination
+func RedactFields(v any, allow FieldAllowFunc) {
+	if v == nil {
+		return
+	}
+	value := reflect.ValueOf(v)
+	if !HasFieldPermission(value.Type()) {
+		return
+	}
+	redactFields(value, allow, 0)
+}
+
+func redactFields(v reflect.Value, allow FieldAllowFunc, depth int) {
+	if depth > maxRedactDepth {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			redactFields(v.Elem(), allow, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		if !HasFieldPermission(v.Type().Elem()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			redactFields(v.Index(i), allow, depth+1)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			if perm := field.Tag.Get(FieldPermissionTag); perm != "" {
+				if !allow(perm) && fv.CanSet() {
+					fv.Set(reflect.Zero(field.Type))
+				}
+				continue
+			}
+			if HasFieldPermission(field.Type) {
+				redactFields(fv, allow, depth+1)
+			}
+		}
+	}
+}
+
+// DeniedFields json names of the perm fields set in v which are not allowed, v is a create or update model
+func DeniedFields(v any, allow FieldAllowFunc) []string {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	var denied []string
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		perm := field.Tag.Get(FieldPermissionTag)
+		if perm == "" || value.Field(i).IsZero() || allow(perm) {
+			continue
+		}
+		denied = append(denied, jsonName(field))
+	}
+	return denied
+}
+
+// FieldPermissions json name -> perm tag of the fields of the model t
+func FieldPermissions(t reflect.Type) map[string]string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	perms := map[string]string{}
+	if t.Kind() != reflect.Struct {
+		return perms
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if perm := field.Tag.Get(FieldPermissionTag); perm != "" {
+			perms[jsonName(field)] = perm
+		}
+	}
+	return perms
+}
+
+// ColumnPermissions same as FieldPermissions by column, the fields of the log diffs are columns
+func ColumnPermissions(t reflect.Type) map[string]string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	perms := map[string]string{}
+	if t.Kind() != reflect.Struct {
+		return perms
+	}
+	modelSchema, err := schema.Parse(reflect.New(t).Interface(), diffSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return perms
+	}
+	for _, field := range modelSchema.Fields {
+		if perm := field.Tag.Get(FieldPermissionTag); perm != "" && field.DBName != "" {
+			perms[field.DBName] = perm
+		}
+	}
+	return perms
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// HasFieldPermission t or one of its nested models has a perm field
+func HasFieldPermission(t reflect.Type) bool {
+	if found, ok := fieldPermissionTypes.Load(t); ok {
+		return found.(bool)
+	}
+	found := hasFieldPermission(t, map[reflect.Type]bool{})
+	fieldPermissionTypes.Store(t, found)
+	return found
+}
+
+func hasFieldPermission(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasFieldPermission(t.Elem(), seen)
+	case reflect.Struct:
+	default:
+		return false
+	}
+	// recursive models, e.g. staff -> users -> staff
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Tag.Get(FieldPermissionTag) != "" || hasFieldPermission(field.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"go_base/domain/permission"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+func TestRedactFields(t *testing.T) {
+	allowPrice := func(perm string) bool { return perm == "admin.asset.price" }
+	staffID := lo.ToPtr(uuid.New())
+	page := &Pagination[User]{Items: []User{
+		{FirstName: "Lead", BudgetBuy: lo.ToPtr(1.0), BudgetSell: lo.ToPtr(2.0), StaffID: staffID},
+	}}
+	RedactFields(page, allowPrice)
+	if page.Items[0].BudgetBuy != nil || page.Items[0].BudgetSell != nil {
+		t.Errorf("RedactFields() budget = %v, want nil", page.Items[0].BudgetBuy)
+	}
+	if page.Items[0].FirstName != "Lead" || page.Items[0].StaffID != staffID {
+		t.Errorf("RedactFields() changed the fields without perm tag")
+	}
+
+	asset := &Asset{Price: lo.ToPtr(10.0)}
+	RedactFields(asset, allowPrice)
+	if asset.Price == nil {
+		t.Errorf("RedactFields() redacted the allowed price")
+	}
+	RedactFields(asset, func(string) bool { return false })
+	if asset.Price != nil {
+		t.Errorf("RedactFields() price = %v, want nil", *asset.Price)
+	}
+
+	// model without perm tag is not walked
+	if HasFieldPermission(reflect.TypeOf(Role{})) {
+		t.Errorf("HasFieldPermission(Role) = true")
+	}
+	if !HasFieldPermission(reflect.TypeOf(&Pagination[Asset]{})) {
+		t.Errorf("HasFieldPermission(*Pagination[Asset]) = false")
+	}
+}
+
+func TestDeniedFields(t *testing.T) {
+	deny := func(string) bool { return false }
+	tests := []struct {
+		name  string
+		model any
+		allow FieldAllowFunc
+		want  []string
+	}{
+		{name: "budget set", model: &UserUpdate{BudgetBuy: lo.ToPtr(1.0), FirstName: lo.ToPtr("A")}, allow: deny, want: []string{"budget_buy"}},
+		{name: "budget not set", model: &UserUpdate{FirstName: lo.ToPtr("A")}, allow: deny},
+		{name: "budget allowed", model: &UserUpdate{BudgetSell: lo.ToPtr(1.0)}, allow: func(perm string) bool { return perm == "admin.user.budget" }},
+		{name: "price set", model: AssetUpdate{Price: lo.ToPtr(1.0)}, allow: deny, want: []string{"price"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeniedFields(tt.model, tt.allow); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeniedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// every perm tag is a registered permission with scope true, so roles can grant it
+func TestFieldPermission_Registered(t *testing.T) {
+	for _, model := range []any{User{}, UserUpdate{}, Asset{}, AssetCreate{}, AssetUpdate{}} {
+		rt := reflect.TypeOf(model)
+		for i := 0; i < rt.NumField(); i++ {
+			perm := rt.Field(i).Tag.Get(FieldPermissionTag)
+			if perm == "" {
+				continue
+			}
+			parts := strings.Split(perm, ".")
+			if len(parts) != 3 {
+				t.Fatalf("%s.%s perm %q is not {system}.{resource}.{action}", rt.Name(), rt.Field(i).Name, perm)
+			}
+			if err := permission.Check(parts[0], parts[1], parts[2], permission.ScopeAll); err != nil {
+				t.Errorf("%s.%s: %v", rt.Name(), rt.Field(i).Name, err)
+			}
+		}
+	}
+}
+
+func TestFieldPermissions(t *testing.T) {
+	got := FieldPermissions(reflect.TypeOf(&User{}))
+	want := map[string]string{"budget_buy": "admin.user.budget", "budget_sell": "admin.user.budget", "budget_per_month": "admin.user.budget"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldPermissions(User) = %v, want %v", got, want)
+	}
+	if got := FieldPermissions(reflect.TypeOf(Role{})); len(got) != 0 {
+		t.Errorf("FieldPermissions(Role) = %v, want empty", got)
+	}
+}
+
+func TestColumnPermissions(t *testing.T) {
+	got := ColumnPermissions(reflect.TypeOf(&Asset{}))
+	want := map[string]string{"price": "admin.asset.price"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ColumnPermissions(Asset) = %v, want %v", got, want)
+	}
+}
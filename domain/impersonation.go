@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	ActorIDKey = ContextKey("actor_id")
+	ActorKey   = ContextKey("actor")
+
+	// one impersonation session per staff and user, a new impersonation revoke the previous one
+	ImpersonationDeviceID        = "impersonation:%s" // staff id
+	DefaultImpersonationDuration = 15 * time.Minute
+
+	// Log
+	ImpersonateLog = "impersonate"
+)
+
+// ImpersonationToken access token of the user with act claim of the staff
+type ImpersonationToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	DeviceID    string    `json:"device_id"`
+}
+
+// ActorFromContext staff impersonating the user of the request, nil without impersonation
+func ActorFromContext(ctx echo.Context) *Staff {
+	actor, ok := ctx.Get(string(ActorKey)).(*Staff)
+	if !ok {
+		return nil
+	}
+	return actor
+}
+
+// IsImpersonating the token of the request has act claim
+func IsImpersonating(ctx echo.Context) bool {
+	actorID, _ := ctx.Get(string(ActorIDKey)).(string)
+	return actorID != ""
+}
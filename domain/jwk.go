@@ -0,0 +1,247 @@
+package domain
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go_base/xerror"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	ErrSigningKeyNotSupported = errors.New("signing key must be RSA or Ed25519")
+	ErrSigningKeyNotFound     = errors.New("signing key not found")
+)
+
+// SigningKey asymmetric key of kid, Private is nil when the key is kept only to verify old tokens
+type SigningKey struct {
+	KID     string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet sign access / refresh tokens with the active key and verify with every key by kid,
+// without asymmetric keys HS256 with secret is used
+type KeySet struct {
+	secret   string
+	issuer   string
+	audience string
+	active   *SigningKey
+	keys     map[string]*SigningKey
+}
+
+// JWK public key of RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type TokenKeyService interface {
+	Admin() *KeySet
+	User() *KeySet
+	// GET /.well-known/jwks.json public keys of admin and user
+	JWKS(ctx echo.Context) (*JWKS, error)
+}
+
+// NewKeySet the first key with private key is active, secret keep HS256 tokens valid while moving to asymmetric keys
+func NewKeySet(secret string, keys ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{secret: secret, keys: map[string]*SigningKey{}}
+	for _, key := range keys {
+		if _, ok := ks.keys[key.KID]; ok {
+			return nil, fmt.Errorf("duplicated kid: %s", key.KID)
+		}
+		ks.keys[key.KID] = key
+		if ks.active == nil && key.Private != nil {
+			ks.active = key
+		}
+	}
+	if ks.active == nil && secret == "" {
+		return nil, ErrSigningKeyNotFound
+	}
+	return ks, nil
+}
+
+// WithAudience iss / aud claims of the tokens of the set, Parse reject tokens of another issuer or audience
+func (ks *KeySet) WithAudience(issuer, audience string) *KeySet {
+	ks.issuer = issuer
+	ks.audience = audience
+	return ks
+}
+
+func (ks *KeySet) Issuer() string {
+	return ks.issuer
+}
+
+func (ks *KeySet) Audience() string {
+	return ks.audience
+}
+
+// NewVerifyKeySet public keys of another issuer (OIDC provider), the set can only verify
+func NewVerifyKeySet(keys ...*SigningKey) *KeySet {
+	ks := &KeySet{keys: map[string]*SigningKey{}}
+	for _, key := range keys {
+		ks.keys[key.KID] = key
+	}
+	return ks
+}
+
+// HasKey kid is in the set
+func (ks *KeySet) HasKey(kid string) bool {
+	_, ok := ks.keys[kid]
+	return ok
+}
+
+// ParseSigningKeyPEM PKCS#8 / PKCS#1 private key or PKIX public key
+func ParseSigningKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem of kid %s", kid)
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("pem type %s of kid %s not supported", block.Type, kid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(kid, key)
+}
+
+// NewSigningKey key is rsa / ed25519 private or public key
+func NewSigningKey(kid string, key any) (*SigningKey, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodEdDSA, Public: k}, nil
+	default:
+		return nil, ErrSigningKeyNotSupported
+	}
+}
+
+// Sign with the active key and its kid header, HS256 with secret without active key
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil && ks.secret == "" {
+		return "", ErrSigningKeyNotFound
+	}
+	if ks.active == nil {
+		return GenerateAccessToken(claims, ks.secret)
+	}
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.KID
+	return token.SignedString(ks.active.Private)
+}
+
+// Parse verify with the key of kid header, token without kid is verified with secret (HS256),
+// iss / aud must match the set when WithAudience is set
+func (ks *KeySet) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	var opts []jwt.ParserOption
+	if ks.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(ks.issuer))
+	}
+	if ks.audience != "" {
+		opts = append(opts, jwt.WithAudience(ks.audience))
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc, opts...)
+	if err != nil || !token.Valid {
+		return nil, xerror.E(xerror.ErrUnauthorized).SetStatusCode(xerror.ErrCodeUnauthorized).SetDebugInfo("invalid_token", err)
+	}
+	return token, nil
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || ks.secret == "" {
+			return nil, ErrSigningKeyNotFound
+		}
+		return []byte(ks.secret), nil
+	}
+	key, ok := ks.keys[kid]
+	if !ok || key.Method.Alg() != token.Method.Alg() {
+		return nil, ErrSigningKeyNotFound
+	}
+	return key.Public, nil
+}
+
+// ParseJWK public key of RFC 7517, RSA and Ed25519 only
+func ParseJWK(jwk JWK) (*SigningKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(jwk.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())})
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrSigningKeyNotSupported
+		}
+		return NewSigningKey(jwk.Kid, ed25519.PublicKey(x))
+	default:
+		return nil, ErrSigningKeyNotSupported
+	}
+}
+
+// JWKs public keys of the set, HS256 secret is never exposed
+func (ks *KeySet) JWKs() []JWK {
+	jwks := make([]JWK, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.KID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		jwks = append(jwks, jwk)
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
@@ -0,0 +1,237 @@
+package domain
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims() jwt.Claims {
+	return jwt.RegisteredClaims{
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+}
+
+func TestKeySet_SignParse(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rs, _ := NewSigningKey("rs-1", rsaKey)
+	ed, _ := NewSigningKey("ed-1", edKey)
+	rsPublic, _ := NewSigningKey("rs-1", &rsaKey.PublicKey)
+
+	tests := []struct {
+		name    string
+		signer  *KeySet
+		parser  *KeySet
+		wantAlg string
+		wantErr bool
+	}{
+		{name: "RS256", signer: mustKeySet(t, "", rs), parser: mustKeySet(t, "", rs), wantAlg: "RS256"},
+		{name: "EdDSA", signer: mustKeySet(t, "", ed), parser: mustKeySet(t, "", ed), wantAlg: "EdDSA"},
+		{name: "HS256 fallback", signer: mustKeySet(t, "secret"), parser: mustKeySet(t, "secret"), wantAlg: "HS256"},
+		{name: "rotated, old key public only", signer: mustKeySet(t, "", rs), parser: mustKeySet(t, "", ed, rsPublic), wantAlg: "RS256"},
+		{name: "HS256 token after moving to RS256", signer: mustKeySet(t, "secret"), parser: mustKeySet(t, "secret", rs), wantAlg: "HS256"},
+		{name: "unknown kid", signer: mustKeySet(t, "", ed), parser: mustKeySet(t, "", rs), wantErr: true},
+		{name: "HS256 without secret", signer: mustKeySet(t, "secret"), parser: mustKeySet(t, "", rs), wantErr: true},
+		{name: "wrong secret", signer: mustKeySet(t, "secret"), parser: mustKeySet(t, "other"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenStr, err := tt.signer.Sign(testClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := tt.parser.Parse(tokenStr, &jwt.RegisteredClaims{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && token.Method.Alg() != tt.wantAlg {
+				t.Errorf("Parse() alg = %v, want %v", token.Method.Alg(), tt.wantAlg)
+			}
+		})
+	}
+}
+
+// a token claiming the kid of an RSA key must not be verified with another algorithm
+func TestKeySet_Parse_AlgMismatch(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rs, _ := NewSigningKey("shared", rsaKey)
+	ed, _ := NewSigningKey("shared", edKey)
+
+	tokenStr, err := mustKeySet(t, "", ed).Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mustKeySet(t, "", rs).Parse(tokenStr, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("Parse() accepted EdDSA token for RS256 kid")
+	}
+}
+
+func TestParseSigningKeyPEM(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	pkix, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+
+	tests := []struct {
+		name        string
+		pem         []byte
+		wantAlg     string
+		wantPrivate bool
+		wantErr     bool
+	}{
+		{name: "PKCS1 RSA", pem: pemEncode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), wantAlg: "RS256", wantPrivate: true},
+		{name: "PKCS8 Ed25519", pem: pemEncode("PRIVATE KEY", pkcs8), wantAlg: "EdDSA", wantPrivate: true},
+		{name: "PKIX RSA public", pem: pemEncode("PUBLIC KEY", pkix), wantAlg: "RS256"},
+		{name: "unsupported type", pem: pemEncode("CERTIFICATE", pkix), wantErr: true},
+		{name: "not pem", pem: []byte("secret"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseSigningKeyPEM("kid", tt.pem)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSigningKeyPEM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if key.Method.Alg() != tt.wantAlg {
+				t.Errorf("ParseSigningKeyPEM() alg = %v, want %v", key.Method.Alg(), tt.wantAlg)
+			}
+			if (key.Private != nil) != tt.wantPrivate {
+				t.Errorf("ParseSigningKeyPEM() private = %v, want %v", key.Private != nil, tt.wantPrivate)
+			}
+		})
+	}
+}
+
+func TestKeySet_JWKs(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rs, _ := NewSigningKey("b-rs", rsaKey)
+	ed, _ := NewSigningKey("a-ed", edKey)
+
+	jwks := mustKeySet(t, "secret", rs, ed).JWKs()
+	if len(jwks) != 2 {
+		t.Fatalf("JWKs() = %d keys, want 2", len(jwks))
+	}
+	if got := jwks[0]; got.Kid != "a-ed" || got.Kty != "OKP" || got.Crv != "Ed25519" || got.Alg != "EdDSA" || got.X == "" {
+		t.Errorf("JWKs()[0] = %+v", got)
+	}
+	if got := jwks[1]; got.Kid != "b-rs" || got.Kty != "RSA" || got.Alg != "RS256" || got.N == "" || got.E != "AQAB" {
+		t.Errorf("JWKs()[1] = %+v", got)
+	}
+	if len(mustKeySet(t, "secret").JWKs()) != 0 {
+		t.Error("JWKs() exposed HS256 secret")
+	}
+}
+
+func TestNewKeySet(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rs, _ := NewSigningKey("rs", rsaKey)
+	public, _ := NewSigningKey("rs-public", &rsaKey.PublicKey)
+	if _, err := NewKeySet("", rs, rs); err == nil {
+		t.Error("NewKeySet() accepted duplicated kid")
+	}
+	if _, err := NewKeySet("", public); err == nil {
+		t.Error("NewKeySet() accepted set without private key or secret")
+	}
+}
+
+func mustKeySet(t *testing.T, secret string, keys ...*SigningKey) *KeySet {
+	t.Helper()
+	ks, err := NewKeySet(secret, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func pemEncode(typ string, b []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b})
+}
+
+func TestKeySet_Parse_Audience(t *testing.T) {
+	staff := mustKeySet(t, "secret").WithAudience("base-services", AudienceStaff)
+	user := mustKeySet(t, "secret").WithAudience("base-services", AudienceUser)
+	claims := func(iss, aud string) jwt.Claims {
+		return jwt.RegisteredClaims{
+			Issuer:    iss,
+			Audience:  jwt.ClaimStrings{aud},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}
+	tests := []struct {
+		name    string
+		claims  jwt.Claims
+		wantErr bool
+	}{
+		{name: "staff token", claims: claims("base-services", AudienceStaff)},
+		{name: "user token", claims: claims("base-services", AudienceUser), wantErr: true},
+		{name: "other issuer", claims: claims("other", AudienceStaff), wantErr: true},
+		{name: "without aud", claims: testClaims(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the same secret signs both audiences, only aud tells them apart
+			tokenStr, err := user.Sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := staff.Parse(tokenStr, &jwt.RegisteredClaims{}); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseJWK(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rs, _ := NewSigningKey("rs", rsaKey)
+	ed, _ := NewSigningKey("ed", edKey)
+	signer := mustKeySet(t, "", rs, ed)
+
+	// public keys of the jwks verify tokens of the signer
+	keys := make([]*SigningKey, 0, 2)
+	for _, jwk := range signer.JWKs() {
+		key, err := ParseJWK(jwk)
+		if err != nil {
+			t.Fatalf("ParseJWK(%s) error = %v", jwk.Kid, err)
+		}
+		if key.Private != nil {
+			t.Errorf("ParseJWK(%s) has private key", jwk.Kid)
+		}
+		keys = append(keys, key)
+	}
+	verifier := NewVerifyKeySet(keys...)
+	if !verifier.HasKey("rs") || !verifier.HasKey("ed") || verifier.HasKey("other") {
+		t.Error("HasKey() of the parsed keys")
+	}
+	tokenStr, _ := signer.Sign(testClaims())
+	if _, err := verifier.Parse(tokenStr, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+	if _, err := verifier.Sign(testClaims()); err != ErrSigningKeyNotFound {
+		t.Errorf("Sign() of verify only set error = %v", err)
+	}
+
+	for _, jwk := range []JWK{
+		{Kty: "EC", Kid: "ec", Crv: "P-256"},
+		{Kty: "OKP", Kid: "x25519", Crv: "X25519", X: "AAAA"},
+		{Kty: "RSA", Kid: "bad", N: "!", E: "AQAB"},
+	} {
+		if _, err := ParseJWK(jwk); err == nil {
+			t.Errorf("ParseJWK(%s) accepted", jwk.Kid)
+		}
+	}
+}
@@ -1,21 +1,29 @@
 package domain
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	helper "go_base/domain/helper"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 var (
-	DoerTypeStaff  = "staff"
-	DoerTypeUser   = "user"
-	DoerTypeSystem = "system"
+	DoerTypeStaff   = "staff"
+	DoerTypeUser    = "user"
+	DoerTypeSystem  = "system"
+	DoerTypeService = "service"
 )
 
 var removeWordTableName = map[string]string{
@@ -33,9 +41,29 @@ type Logs[T any] struct {
 	// ex: {"id":1,"name":"admin","email":"admin@localhost", type:"staff"}
 	Doer datatypes.JSON `json:"doer" gorm:"type:jsonb;not null"`
 
+	// row of the change and its field changes, set by the create, update and delete of BaseStore
+	RecordID *uuid.UUID     `json:"record_id,omitempty" gorm:"type:uuid;index"`
+	Diff     datatypes.JSON `json:"diff,omitempty" gorm:"type:jsonb"`
+
 	LogModel T `json:"-" gorm:"-"`
 }
 
+// FieldChange one field of a change, old is null on create and new is null on delete
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// LogHistory one change of the timeline of a row /:id/history
+type LogHistory struct {
+	ID        uuid.UUID      `json:"id"`
+	Action    string         `json:"action"`
+	CreatedAt time.Time      `json:"created_at"`
+	Doer      datatypes.JSON `json:"doer"`
+	Changes   []FieldChange  `json:"changes"`
+}
+
 type Doer struct {
 	ID     uuid.UUID  `json:"id"`
 	Name   string     `json:"name"`
@@ -43,6 +71,7 @@ type Doer struct {
 	Type   string     `json:"type"`
 	RoleID *uuid.UUID `json:"role_id,omitempty"`
 	Role   *Role      `json:"role,omitempty"`
+	Actor  *Doer      `json:"actor,omitempty"` // staff impersonating the user doer
 }
 
 func NewLogs[T any]() *Logs[T] {
@@ -57,6 +86,92 @@ func (l *Logs[T]) TableName() string {
 	return fmt.Sprintf("%s_logs", helper.ToSnakeCase(fieldName))
 }
 
+// History timeline entry of the log, the changes of a log written before the diffs are empty
+func (l *Logs[T]) History() (LogHistory, error) {
+	history := LogHistory{ID: l.ID, Action: l.Action, CreatedAt: l.CreatedAt, Doer: l.Doer, Changes: []FieldChange{}}
+	if len(l.Diff) == 0 {
+		return history, nil
+	}
+	if err := json.Unmarshal(l.Diff, &history.Changes); err != nil {
+		return history, err
+	}
+	return history, nil
+}
+
+// diffIgnoredFields change with every update
+var diffIgnoredFields = map[string]bool{"updated_at": true}
+
+// diffDeniedFields secrets are never written to the diff, even as hash
+var diffDeniedFields = map[string]bool{
+	"password":       true,
+	"verify_token":   true,
+	"key_hash":       true,
+	"secret":         true,
+	"recovery_codes": true,
+	"token":          true,
+}
+
+var diffSchemas = &sync.Map{}
+
+/*
+DiffModels field changes between the columns of before and after, sorted by column
+
+	before nil: every column of after is created,
+	after nil: every column of before is deleted,
+	the columns hidden from json (json:"-") are diffed too, except the secrets of diffDeniedFields
+*/
+func DiffModels(before, after any) ([]FieldChange, error) {
+	oldFields, err := columnFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := columnFields(after)
+	if err != nil {
+		return nil, err
+	}
+	fields := lo.Uniq(append(lo.Keys(oldFields), lo.Keys(newFields)...))
+	sort.Strings(fields)
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if diffIgnoredFields[field] || reflect.DeepEqual(oldFields[field], newFields[field]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Old: oldFields[field], New: newFields[field]})
+	}
+	return changes, nil
+}
+
+// columnFields json value of every column of the gorm schema of v, a nil pointer has none
+func columnFields(v any) (map[string]any, error) {
+	fields := map[string]any{}
+	value := reflect.ValueOf(v)
+	if v == nil || (value.Kind() == reflect.Pointer && value.IsNil()) {
+		return fields, nil
+	}
+	modelSchema, err := schema.Parse(v, diffSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	value = reflect.Indirect(value)
+	for _, field := range modelSchema.Fields {
+		if field.DBName == "" || diffDeniedFields[field.DBName] {
+			continue
+		}
+		fieldValue, _ := field.ValueOf(context.Background(), value)
+		// same value as the json of the model, e.g. uuid and time are string
+		b, err := json.Marshal(fieldValue)
+		if err != nil {
+			return nil, err
+		}
+		var column any
+		if err := json.Unmarshal(b, &column); err != nil {
+			return nil, err
+		}
+		fields[field.DBName] = column
+	}
+	return fields, nil
+}
+
 // Find is pagination for logs
 func (l *Logs[T]) Find(ctx echo.Context, db *gorm.DB) (*Pagination[Logs[T]], error) {
 
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDiffModels(t *testing.T) {
+	id := uuid.New()
+	before := &Organization{BaseModel: BaseModel{ID: id, UpdatedAt: time.Unix(1, 0)}, Name: "Old", Description: "same"}
+	after := &Organization{BaseModel: BaseModel{ID: id, UpdatedAt: time.Unix(2, 0)}, Name: "New", Description: "same"}
+	roleID, changedAt := uuid.New(), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	staffBefore := &Staff{BaseModel: BaseModel{ID: id}, Password: "old hash", VerifyToken: "old token"}
+	staffAfter := &Staff{BaseModel: BaseModel{ID: id}, Password: "new hash", RoleID: &roleID, PasswordChangedAt: &changedAt}
+	tests := []struct {
+		name   string
+		before any
+		after  any
+		want   []FieldChange
+	}{
+		{name: "update", before: before, after: after, want: []FieldChange{{Field: "name", Old: "Old", New: "New"}}},
+		{name: "no change", before: before, after: before, want: []FieldChange{}},
+		{name: "create", before: nil, after: &DeveloperCreate{ID: id, Name: "Dev"}, want: []FieldChange{
+			{Field: "id", Old: nil, New: id.String()},
+			{Field: "name", Old: nil, New: "Dev"},
+		}},
+		{name: "hidden columns without secrets", before: staffBefore, after: staffAfter, want: []FieldChange{
+			{Field: "password_changed_at", Old: nil, New: changedAt.Format(time.RFC3339)},
+			{Field: "role_id", Old: nil, New: roleID.String()},
+		}},
+		{name: "delete nil pointer", before: &DeveloperCreate{ID: id, Name: "Dev"}, after: (*DeveloperCreate)(nil), want: []FieldChange{
+			{Field: "id", Old: id.String(), New: nil},
+			{Field: "name", Old: "Dev", New: nil},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffModels(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffModels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogs_History(t *testing.T) {
+	changes := []FieldChange{{Field: "name", Old: "Old", New: "New"}}
+	diff, _ := json.Marshal(changes)
+	log := Logs[Organization]{Action: "update", Diff: diff}
+	got, err := log.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Action != "update" || !reflect.DeepEqual(got.Changes, changes) {
+		t.Errorf("History() = %+v, want changes %v", got, changes)
+	}
+
+	// logs written before the diffs have no changes
+	got, err = (&Logs[Organization]{Action: "create"}).History()
+	if err != nil || got.Changes == nil || len(got.Changes) != 0 {
+		t.Errorf("History() changes = %v, %v, want empty", got.Changes, err)
+	}
+}
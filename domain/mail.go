@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// pages of the frontend at mail.baseurl, the token is appended as ?token= and the page POST it
+	// to the api route of the same path. the api has no GET route, a mail scanner opening the link
+	// must not consume the one time token and the password reset need the new password anyway
+	StaffVerifyPath        = "/staffs/verify"
+	UserVerifyPath         = "/users/verify"
+	StaffPasswordResetPath = "/staffs/password/reset"
+	UserPasswordResetPath  = "/users/password/reset"
+)
+
+// MailToken email with a link token, TmpPassword is only sent with the invitation / verification
+type MailToken struct {
+	Name        string
+	TmpPassword string
+	Token       string
+	Path        string
+	ExpireAt    time.Time
+}
+
+type MailOTP struct {
+	OTP           string
+	ReferenceCode string
+	ExpireAt      time.Time
+}
+
+// MailLockout the account is locked until LockedUntil after Attempts failed logins
+type MailLockout struct {
+	Name        string
+	Attempts    int
+	LockedUntil time.Time
+}
+
+type MailService interface {
+	// staff created by admin
+	SendInvitation(ctx echo.Context, to string, data MailToken) error
+	// user sign up
+	SendVerification(ctx echo.Context, to string, data MailToken) error
+	SendOTP(ctx echo.Context, to string, data MailOTP) error
+	SendPasswordReset(ctx echo.Context, to string, data MailToken) error
+	SendAccountLocked(ctx echo.Context, to string, data MailLockout) error
+}
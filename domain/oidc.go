@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	OIDCStateCache    = "oidc:state:%s" // state
+	OIDCStateDuration = 10 * time.Minute
+
+	// Log
+	OIDCLoginLog     = "oidc_login"
+	OIDCProvisionLog = "oidc_provision"
+)
+
+// OIDCAuthorize GET /staffs/oidc/:provider/authorize
+type OIDCAuthorize struct {
+	DeviceID string `json:"device_id,omitempty" validate:"omitempty,uuid4" query:"device_id" swagger:"desc(device_id)" form:"device_id"`
+}
+
+// OIDCAuthorization the client redirect the browser to URL, the provider redirect back with code and state
+type OIDCAuthorization struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+// OIDCCallback POST /staffs/oidc/:provider/callback
+type OIDCCallback struct {
+	Code  string `json:"code" validate:"required" query:"code" swagger:"desc(code),required" form:"code"`
+	State string `json:"state" validate:"required" query:"state" swagger:"desc(state),required" form:"state"`
+	// the provider mfa is not trusted, staff who enabled two factor still send the totp
+	TOTPCode string `json:"totp_code,omitempty" query:"totp_code" swagger:"desc(totp or recovery code)" form:"totp_code"`
+}
+
+// OIDCState kept in cache between authorize and callback, can be used once
+type OIDCState struct {
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	DeviceID     string `json:"device_id,omitempty"`
+}
+
+// OIDCIdentity claims of the verified id token
+type OIDCIdentity struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+}
+
+type OIDCService interface {
+	// Providers names of the configured providers
+	Providers() []string
+	// Authorize authorization url with state, nonce and PKCE challenge kept in cache
+	Authorize(ctx echo.Context, provider string, req OIDCAuthorize) (*OIDCAuthorization, error)
+	// Callback exchange the code, map the email to a staff (or provision it) and issue tokens
+	Callback(ctx echo.Context, provider string, req OIDCCallback) (*AuthResult, error)
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Organization branch office, staff are members of one organization
+type Organization struct {
+	BaseModel
+	Name        string `json:"name" gorm:"type:varchar(255);not null;uniqueIndex" validate:"required" filter:"like"`
+	Description string `json:"description" gorm:"type:text"`
+	CountStaff  *int64 `json:"count_staff,omitempty" gorm:"-"`
+}
+
+type OrganizationCreate struct {
+	Name        string `json:"name" validate:"required,max=255" form:"name" query:"name"`
+	Description string `json:"description" validate:"max=1000" form:"description" query:"description"`
+}
+
+type OrganizationUpdate struct {
+	ID          uuid.UUID `json:"id" validate:"required,uuid" form:"-" query:"-"`
+	Name        *string   `json:"name,omitempty" validate:"omitempty,max=255" form:"name" query:"name"`
+	Description *string   `json:"description,omitempty" validate:"omitempty,max=1000" form:"description" query:"description"`
+}
+
+func (OrganizationUpdate) TableName() string {
+	return "organizations"
+}
+
+type OrganizationService interface {
+	Create(ctx echo.Context, create *OrganizationCreate) (*Organization, error)
+	Update(ctx echo.Context, update *OrganizationUpdate) error
+	GetByID(ctx echo.Context, id string) (*Organization, error)
+	Find(ctx echo.Context, pagination Pagination[Organization]) (*Pagination[Organization], error)
+	// Delete refuse while staff are members
+	Delete(ctx echo.Context, id uuid.UUID) error
+	History(ctx echo.Context, id uuid.UUID) (*Pagination[LogHistory], error)
+}
+
+// staff of the organization
+func (Staff) OrganizationScope(organizationID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("staffs.organization_id = ?", organizationID)
+	}
+}
+
+// users (leads) owned by staff of the organization
+func (User) OrganizationScope(organizationID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.staff_id IN (?)", staffOfOrganization(db, organizationID))
+	}
+}
+
+// assets of users owned by staff of the organization
+func (Asset) OrganizationScope(organizationID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		users := db.Session(&gorm.Session{NewDB: true}).Table("users").Select("id").
+			Where("deleted_at IS NULL AND staff_id IN (?)", staffOfOrganization(db, organizationID))
+		return db.Where("assets.user_id IN (?)", users)
+	}
+}
+
+func staffOfOrganization(db *gorm.DB, organizationID uuid.UUID) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Table("staffs").Select("id").
+		Where("deleted_at IS NULL AND organization_id = ?", organizationID)
+}
@@ -1,32 +1,184 @@
 package domain
 
 import (
+	"fmt"
 	"go_base/hash"
 	"go_base/logger"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 )
 
 type Password string
 
-// CompareBcrypt
+var (
+	// common passwords, always denied with PasswordPolicy.Denylist
+	CommonPasswords = []string{
+		"123456", "12345678", "123456789", "1234567890", "password", "password1", "password123",
+		"qwerty", "qwerty123", "qwertyuiop", "abc123", "111111", "000000", "1q2w3e4r", "1qaz2wsx",
+		"iloveyou", "admin", "admin123", "welcome", "welcome1", "letmein", "monkey", "dragon",
+		"football", "baseball", "sunshine", "princess", "trustno1", "changeme", "p@ssw0rd",
+	}
+)
+
+// PasswordPolicy rules of the new password, configured per audience in AuthConfig
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Denylist      []string
+	// reuse of the last History passwords is denied, 0 disable
+	History int
+	// password older than MaxAge must be changed before login, 0 disable
+	MaxAge time.Duration
+}
+
+// PasswordHistory hash of the previous passwords of staff and user
+type PasswordHistory struct {
+	BaseModel
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	Password Password  `json:"-" gorm:"not null"`
+}
+
+// POST /password/forgot
+type PasswordForgot struct {
+	Email SensitiveString `json:"email" validate:"required,email" query:"email" swagger:"desc(email),required" form:"email"`
+}
+
+// POST /password/reset, token is sent to email by forgot password and can be used once
+type PasswordReset struct {
+	Token    string `json:"token" validate:"required" query:"token" swagger:"desc(token),required" form:"token"`
+	Password string `json:"password" validate:"required" query:"password" swagger:"desc(password),required" form:"password"`
+}
+
+// POST /staffs/password/change, change the expired password with the credentials
+type PasswordChange struct {
+	Email       SensitiveString `json:"email" validate:"required,email" query:"email" swagger:"desc(email),required" form:"email"`
+	OldPassword string          `json:"old_password" validate:"required" query:"old_password" swagger:"desc(old_password),required" form:"old_password"`
+	Password    string          `json:"password" validate:"required" query:"password" swagger:"desc(password),required" form:"password"`
+}
+
+type PasswordService interface {
+	// Validate check the policy and the reuse of current and the last passwords, error has field errors of field
+	Validate(ctx echo.Context, policy PasswordPolicy, userID uuid.UUID, current Password, field, password string) error
+	// Record keep the hash for reuse check, only the last policy.History are kept
+	Record(ctx echo.Context, policy PasswordPolicy, userID uuid.UUID, hashed Password) error
+}
+
+// Violations messages of every rule the password break, empty when valid
+func (p PasswordPolicy) Violations(password string) []string {
+	var violations []string
+	if strings.TrimSpace(password) != password {
+		violations = append(violations, "must not start or end with space")
+	}
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+	if p.Denied(password) {
+		violations = append(violations, "is too common")
+	}
+	return violations
+}
+
+// Denied password is in CommonPasswords or Denylist, case insensitive
+func (p PasswordPolicy) Denied(password string) bool {
+	password = strings.ToLower(password)
+	for _, list := range [][]string{CommonPasswords, p.Denylist} {
+		for _, denied := range list {
+			if strings.ToLower(denied) == password {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Expired password changed at changedAt must be changed
+func (p PasswordPolicy) Expired(changedAt time.Time) bool {
+	return p.MaxAge > 0 && time.Since(changedAt) > p.MaxAge
+}
+
+// Validate the policy can be generated, checked when the config is loaded
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength > hash.MaxRandomStringLength {
+		return fmt.Errorf("password min length %d is longer than %d", p.MinLength, hash.MaxRandomStringLength)
+	}
+	return nil
+}
+
+// Generate random password of at least length which pass the policy, for temporary password,
+// the policy must pass Validate or no generated password is long enough
+func (p PasswordPolicy) Generate(length int) string {
+	if length < p.MinLength {
+		length = p.MinLength
+	}
+	for i := 1; ; i++ {
+		// too short to contain every required class
+		if i%10 == 0 {
+			length++
+		}
+		password := hash.GenerateRandomString(length)
+		if len(p.Violations(password)) == 0 {
+			return password
+		}
+	}
+}
+
+// CompareBcrypt compare bcrypt or argon2id hash, the name is kept for the callers
 func (p Password) CompareBcrypt(password string) bool {
 	p = Password(hash.Trim(string(p)))
 	if p == "" || password == "" {
 		logger.L().Warn("password is nil")
 		return false
 	}
-	return hash.CompareBcrypt(string(p), password)
+	return hash.ComparePassword(string(p), password)
 }
 
+// Hash of the password as is with the configured hasher (argon2id by default), spaces are rejected by PasswordPolicy instead of trimmed
 func (p Password) Hash() Password {
-	p = Password(hash.Trim(string(p)))
 	if p == "" {
 		logger.L().Warn("password is nil")
 		return ""
 	}
-	hash, _ := hash.HashBcrypt(string(p))
+	hash, _ := hash.HashPassword(string(p))
 	return Password(hash)
 }
 
+// NeedsRehash hash is not of the configured hasher, e.g. legacy bcrypt
+func (p Password) NeedsRehash() bool {
+	return hash.NeedsRehash(string(p))
+}
+
 func (p Password) String() string {
 	return string(p)
 }
@@ -1,8 +1,10 @@
 package domain
 
 import (
+	"go_base/hash"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPassword_CompareBcrypt(t *testing.T) {
@@ -54,7 +56,7 @@ func TestPassword_Hash(t *testing.T) {
 		{
 			name: "p ",
 			p:    Password("a"),
-			want: "$2a$10$",
+			want: "$argon2id$v=19$",
 		},
 	}
 	for _, tt := range tests {
@@ -97,3 +99,86 @@ func TestPassword_IsHashed(t *testing.T) {
 		})
 	}
 }
+
+func TestPasswordPolicy_Violations(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		Denylist:      []string{"Str0ng!Company"},
+	}
+	tests := []struct {
+		name     string
+		password string
+		want     int
+	}{
+		{name: "valid", password: "Str0ng!Passw0rd", want: 0},
+		{name: "too short", password: "Sh0rt!", want: 1},
+		{name: "no upper", password: "str0ng!passw0rd", want: 1},
+		{name: "no lower", password: "STR0NG!PASSW0RD", want: 1},
+		{name: "no digit", password: "Strong!Password", want: 1},
+		{name: "no symbol", password: "Str0ngPassw0rd", want: 1},
+		{name: "space padded", password: " Str0ng!Passw0rd", want: 1},
+		{name: "denylist case insensitive", password: "str0ng!COMPANY", want: 1},
+		{name: "common", password: "password", want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Violations(tt.password); len(got) != tt.want {
+				t.Errorf("PasswordPolicy.Violations() = %v, want %d violations", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_Expired(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxAge    time.Duration
+		changedAt time.Time
+		want      bool
+	}{
+		{name: "disabled", maxAge: 0, changedAt: time.Now().Add(-24 * 365 * time.Hour), want: false},
+		{name: "not expired", maxAge: time.Hour, changedAt: time.Now(), want: false},
+		{name: "expired", maxAge: time.Hour, changedAt: time.Now().Add(-2 * time.Hour), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (PasswordPolicy{MaxAge: tt.maxAge}).Expired(tt.changedAt); got != tt.want {
+				t.Errorf("PasswordPolicy.Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_Generate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	password := policy.Generate(8)
+	if len(password) < 12 {
+		t.Errorf("PasswordPolicy.Generate() length = %d, want at least 12", len(password))
+	}
+	if violations := policy.Violations(password); len(violations) != 0 {
+		t.Errorf("PasswordPolicy.Generate() = %v, violations %v", password, violations)
+	}
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		minLength int
+		wantErr   bool
+	}{
+		{name: "default", minLength: 0},
+		{name: "longest generated", minLength: hash.MaxRandomStringLength},
+		{name: "longer than generated", minLength: hash.MaxRandomStringLength + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (PasswordPolicy{MinLength: tt.minLength}).Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("PasswordPolicy.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
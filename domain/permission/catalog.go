@@ -0,0 +1,143 @@
+package permission
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ScopeDeny value of the tree which block the action, view false can't view and can't access the resource
+const ScopeDeny = "false"
+
+// Catalog system -> resource -> action of every registered permission
+type Catalog map[string]map[string]*CatalogResource
+
+type CatalogResource struct {
+	Description string                    `json:"description"`
+	Actions     map[string]*CatalogAction `json:"actions"`
+}
+
+type CatalogAction struct {
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"` // values which grant the action, false is always accepted
+}
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = Catalog{}
+)
+
+// Resource describe the resource of system, the permissions of the resource are added by Register
+func Resource(system, resource, description string) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	catalogResource(system, resource).Description = description
+}
+
+/*
+Register declare the permission once and return its name to restrict the routes
+
+	name format: {system}.{resource}.{action}.{scope}
+	registering the same action with another scope add the scope, the first description is kept
+*/
+func Register(name, description string) string {
+	parts := strings.Split(name, ".")
+	if len(parts) != 4 || hasEmpty(parts) {
+		panic(fmt.Sprintf("permission: invalid name %q", name))
+	}
+	system, resource, action, scope := parts[0], parts[1], parts[2], parts[3]
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	r := catalogResource(system, resource)
+	a, ok := r.Actions[action]
+	if !ok {
+		a = &CatalogAction{Description: description}
+		r.Actions[action] = a
+	}
+	if !contains(a.Scopes, scope) {
+		a.Scopes = append(a.Scopes, scope)
+		sort.Strings(a.Scopes)
+	}
+	return name
+}
+
+// GetCatalog copy of the catalog
+func GetCatalog() Catalog {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	out := make(Catalog, len(catalog))
+	for system, resources := range catalog {
+		out[system] = make(map[string]*CatalogResource, len(resources))
+		for name, r := range resources {
+			actions := make(map[string]*CatalogAction, len(r.Actions))
+			for action, a := range r.Actions {
+				actions[action] = &CatalogAction{Description: a.Description, Scopes: append([]string{}, a.Scopes...)}
+			}
+			out[system][name] = &CatalogResource{Description: r.Description, Actions: actions}
+		}
+	}
+	return out
+}
+
+// Resources registered resources of system, sorted
+func Resources(system string) []string {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	resources := make([]string, 0, len(catalog[system]))
+	for name := range catalog[system] {
+		resources = append(resources, name)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// Check the action of the resource is registered and value is false or one of its scopes
+func Check(system, resource, action, value string) error {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	r, ok := catalog[system][resource]
+	if !ok {
+		return fmt.Errorf("unknown resource %s.%s", system, resource)
+	}
+	a, ok := r.Actions[action]
+	if !ok {
+		return fmt.Errorf("unknown action %s.%s.%s", system, resource, action)
+	}
+	if value != ScopeDeny && !contains(a.Scopes, value) {
+		return fmt.Errorf("invalid scope %s of %s.%s.%s", value, system, resource, action)
+	}
+	return nil
+}
+
+// catalogResource get or add the resource, the caller hold the lock
+func catalogResource(system, resource string) *CatalogResource {
+	if catalog[system] == nil {
+		catalog[system] = map[string]*CatalogResource{}
+	}
+	r, ok := catalog[system][resource]
+	if !ok {
+		r = &CatalogResource{Actions: map[string]*CatalogAction{}}
+		catalog[system][resource] = r
+	}
+	return r
+}
+
+func hasEmpty(parts []string) bool {
+	for _, part := range parts {
+		if part == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package permission
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		system  string
+		rsc     string
+		action  string
+		value   string
+		wantErr bool
+	}{
+		{name: "registered", system: "admin", rsc: "staff", action: "view", value: "true"},
+		{name: "organization scope", system: "admin", rsc: "user", action: "update", value: "organization"},
+		{name: "deny is always accepted", system: "admin", rsc: "project", action: "export", value: "false"},
+		{name: "unknown system", system: "shop", rsc: "staff", action: "view", value: "true", wantErr: true},
+		{name: "unknown resource", system: "admin", rsc: "booking", action: "view", value: "true", wantErr: true},
+		{name: "unknown action", system: "admin", rsc: "staff", action: "impersonate", value: "true", wantErr: true},
+		{name: "scope not registered on the action", system: "admin", rsc: "user", action: "impersonate", value: "organization", wantErr: true},
+		{name: "unknown scope", system: "admin", rsc: "staff", action: "view", value: "yes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(tt.system, tt.rsc, tt.action, tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// the seeded roles only use registered permissions, except the super admin wildcard
+func TestCheck_SeedRoles(t *testing.T) {
+	b, err := os.ReadFile("../../storage/seed/roles.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roles []struct {
+		Type        string                                  `json:"type"`
+		Permissions map[string]map[string]map[string]string `json:"permissions"`
+	}
+	if err := json.Unmarshal(b, &roles); err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range roles {
+		if role.Permissions["*"]["*"]["*"] == "*" {
+			continue
+		}
+		for system, resources := range role.Permissions {
+			for resource, actions := range resources {
+				for action, value := range actions {
+					if err := Check(system, resource, action, value); err != nil {
+						t.Errorf("role %s: %v", role.Type, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGetCatalog_Copy(t *testing.T) {
+	c := GetCatalog()
+	c["admin"]["staff"].Actions["view"].Scopes[0] = "changed"
+	if err := Check("admin", "staff", "view", ScopeAll); err != nil {
+		t.Errorf("catalog changed by its copy: %v", err)
+	}
+	if c["admin"]["staff"].Description == "" {
+		t.Error("resource description is empty")
+	}
+}
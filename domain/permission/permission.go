@@ -1,11 +1,5 @@
 package permission
 
-var (
-	Resources = []string{
-		"staff",
-	}
-)
-
 const (
 	SystemAdmin = "admin"
 )
@@ -17,52 +11,112 @@ const (
 	ActionUpdate = "update"
 	ActionDelete = "delete"
 	ActionUnlock = "unlock"
+
+	ActionImpersonate = "impersonate"
 )
 
 const (
 	ScopeAll = "true"
+	// only the rows of the organization of the staff, see domain.WithPermissionScope
+	ScopeOrg = "organization"
+	// only the rows the staff is responsible for, e.g. users with staff_id of the staff
+	ScopeOwn = "own"
 )
 
 // Permission name format: {system}.{resource}.{action}.{scope}
-const (
-	ROLE_VIEW_ALL   = "admin.role.view.true"
-	ROLE_CREATE_ALL = "admin.role.create.true"
-	ROLE_UPDATE_ALL = "admin.role.update.true"
-	ROLE_EXPORT_ALL = "admin.role.export.true"
-
-	STAFF_VIEW_ALL   = "admin.staff.view.true"
-	STAFF_CREATE_ALL = "admin.staff.create.true"
-	STAFF_UPDATE_ALL = "admin.staff.update.true"
-	STAFF_DELETE_ALL = "admin.staff.delete.true"
-	STAFF_UNLOCK_ALL = "admin.staff.unlock.true"
-
-	STAFF_ME_FIND_SELF = "admin.staff_me.view.true"
-	STAFF_ME_LOG_SELF  = "admin.staff_me.log.true"
-
-	USER_VIEW_ALL   = "admin.user.view.true"
-	USER_UPDATE_ALL = "admin.user.update.true"
-	USER_DELETE_ALL = "admin.user.delete.true"
-	USER_UNLOCK_ALL = "admin.user.unlock.true"
-
-	ROLE_FIND   = "admin.role.view.true"
-	ROLE_CREATE = "admin.role.create.true"
-	ROLE_UPDATE = "admin.role.update.true"
-
-	DEVELOPER_VIEW_ALL   = "admin.developer.view.true"
-	DEVELOPER_CREATE_ALL = "admin.developer.create.true"
-	DEVELOPER_UPDATE_ALL = "admin.developer.update.true"
-	DEVELOPER_EXPORT_ALL = "admin.developer.export.true"
-	DEVELOPER_DELETE_ALL = "admin.developer.delete.true"
-
-	PROJECT_VIEW_ALL   = "admin.project.view.true"
-	PROJECT_CREATE_ALL = "admin.project.create.true"
-	PROJECT_UPDATE_ALL = "admin.project.update.true"
-	PROJECT_EXPORT_ALL = "admin.project.export.true"
-	PROJECT_DELETE_ALL = "admin.project.delete.true"
-
-	ASSET_VIEW_ALL   = "admin.asset.view.true"
-	ASSET_CREATE_ALL = "admin.asset.create.true"
-	ASSET_UPDATE_ALL = "admin.asset.update.true"
-	ASSET_EXPORT_ALL = "admin.asset.export.true"
-	ASSET_DELETE_ALL = "admin.asset.delete.true"
+// every permission is registered in the catalog, roles can only use registered actions and scopes
+var (
+	ROLE_VIEW_ALL   = Register("admin.role.view.true", "view roles")
+	ROLE_CREATE_ALL = Register("admin.role.create.true", "create roles")
+	ROLE_UPDATE_ALL = Register("admin.role.update.true", "update roles")
+	ROLE_EXPORT_ALL = Register("admin.role.export.true", "export roles")
+	ROLE_DELETE_ALL = Register("admin.role.delete.true", "delete roles")
+
+	STAFF_VIEW_ALL   = Register("admin.staff.view.true", "view staff")
+	STAFF_CREATE_ALL = Register("admin.staff.create.true", "create staff")
+	STAFF_UPDATE_ALL = Register("admin.staff.update.true", "update staff")
+	STAFF_DELETE_ALL = Register("admin.staff.delete.true", "delete staff")
+	STAFF_UNLOCK_ALL = Register("admin.staff.unlock.true", "unlock the login of staff")
+
+	STAFF_VIEW_ORG   = Register("admin.staff.view.organization", "view staff")
+	STAFF_UPDATE_ORG = Register("admin.staff.update.organization", "update staff")
+	STAFF_DELETE_ORG = Register("admin.staff.delete.organization", "delete staff")
+
+	STAFF_ME_FIND_SELF = Register("admin.staff_me.view.true", "view own profile")
+	STAFF_ME_LOG_SELF  = Register("admin.staff_me.log.true", "view own logs")
+
+	USER_VIEW_ALL   = Register("admin.user.view.true", "view users")
+	USER_CREATE_ALL = Register("admin.user.create.true", "create users")
+	USER_UPDATE_ALL = Register("admin.user.update.true", "update users")
+	USER_DELETE_ALL = Register("admin.user.delete.true", "delete users")
+	USER_UNLOCK_ALL = Register("admin.user.unlock.true", "unlock the login of users")
+
+	USER_VIEW_ORG   = Register("admin.user.view.organization", "view users")
+	USER_UPDATE_ORG = Register("admin.user.update.organization", "update users")
+	USER_DELETE_ORG = Register("admin.user.delete.organization", "delete users")
+
+	USER_VIEW_OWN   = Register("admin.user.view.own", "view users")
+	USER_UPDATE_OWN = Register("admin.user.update.own", "update users")
+	USER_DELETE_OWN = Register("admin.user.delete.own", "delete users")
+
+	USER_IMPERSONATE_ALL = Register("admin.user.impersonate.true", "login as the user")
+
+	// fields tagged perm:"admin.user.budget"
+	USER_BUDGET_ALL = Register("admin.user.budget.true", "view and change the budget of users")
+
+	ROLE_FIND   = ROLE_VIEW_ALL
+	ROLE_CREATE = ROLE_CREATE_ALL
+	ROLE_UPDATE = ROLE_UPDATE_ALL
+	ROLE_DELETE = ROLE_DELETE_ALL
+
+	DEVELOPER_VIEW_ALL   = Register("admin.developer.view.true", "view developers")
+	DEVELOPER_CREATE_ALL = Register("admin.developer.create.true", "create developers")
+	DEVELOPER_UPDATE_ALL = Register("admin.developer.update.true", "update developers")
+	DEVELOPER_EXPORT_ALL = Register("admin.developer.export.true", "export developers")
+	DEVELOPER_DELETE_ALL = Register("admin.developer.delete.true", "delete developers")
+
+	PROJECT_VIEW_ALL   = Register("admin.project.view.true", "view projects")
+	PROJECT_CREATE_ALL = Register("admin.project.create.true", "create projects")
+	PROJECT_UPDATE_ALL = Register("admin.project.update.true", "update projects")
+	PROJECT_EXPORT_ALL = Register("admin.project.export.true", "export projects")
+	PROJECT_DELETE_ALL = Register("admin.project.delete.true", "delete projects")
+
+	ASSET_VIEW_ALL   = Register("admin.asset.view.true", "view assets")
+	ASSET_CREATE_ALL = Register("admin.asset.create.true", "create assets")
+	ASSET_UPDATE_ALL = Register("admin.asset.update.true", "update assets")
+	ASSET_EXPORT_ALL = Register("admin.asset.export.true", "export assets")
+	ASSET_DELETE_ALL = Register("admin.asset.delete.true", "delete assets")
+
+	ASSET_VIEW_ORG   = Register("admin.asset.view.organization", "view assets")
+	ASSET_UPDATE_ORG = Register("admin.asset.update.organization", "update assets")
+	ASSET_DELETE_ORG = Register("admin.asset.delete.organization", "delete assets")
+
+	// fields tagged perm:"admin.asset.price"
+	ASSET_PRICE_ALL = Register("admin.asset.price.true", "view and change the price of assets")
+
+	ASSET_VIEW_OWN   = Register("admin.asset.view.own", "view assets")
+	ASSET_UPDATE_OWN = Register("admin.asset.update.own", "update assets")
+	ASSET_DELETE_OWN = Register("admin.asset.delete.own", "delete assets")
+
+	SERVICE_ACCOUNT_VIEW_ALL   = Register("admin.service_account.view.true", "view service accounts")
+	SERVICE_ACCOUNT_CREATE_ALL = Register("admin.service_account.create.true", "create service accounts")
+	SERVICE_ACCOUNT_UPDATE_ALL = Register("admin.service_account.update.true", "update and rotate the key of service accounts")
+	SERVICE_ACCOUNT_DELETE_ALL = Register("admin.service_account.delete.true", "delete service accounts")
+
+	ORGANIZATION_VIEW_ALL   = Register("admin.organization.view.true", "view organizations")
+	ORGANIZATION_CREATE_ALL = Register("admin.organization.create.true", "create organizations")
+	ORGANIZATION_UPDATE_ALL = Register("admin.organization.update.true", "update organizations")
+	ORGANIZATION_DELETE_ALL = Register("admin.organization.delete.true", "delete organizations")
 )
+
+func init() {
+	Resource(SystemAdmin, "role", "roles and their permissions")
+	Resource(SystemAdmin, "staff", "staff accounts")
+	Resource(SystemAdmin, "staff_me", "profile of the logged in staff")
+	Resource(SystemAdmin, "user", "users (leads) and their assignment to staff")
+	Resource(SystemAdmin, "developer", "developers")
+	Resource(SystemAdmin, "project", "projects")
+	Resource(SystemAdmin, "asset", "assets of users")
+	Resource(SystemAdmin, "service_account", "machine clients authenticated by X-API-Key")
+	Resource(SystemAdmin, "organization", "organizations (branch offices) of staff")
+}
@@ -1,7 +1,10 @@
 package domain
 
 import (
+	"encoding/json"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
@@ -21,6 +24,18 @@ const (
 
 	PermissionScopeAll PermissionScope = "all"
 	PermissionScopeOrg PermissionScope = "organization"
+	PermissionScopeOwn PermissionScope = "own"
+)
+
+var (
+	// compiled permissions of the role, see RoleService.HasPermission
+	RolePermissionCache = "role:permissions:%s" // role id
+	// bumped by every change of the role, the cached permissions of an older version are stale on every instance
+	RolePermissionVersionCache = "role:permissions_version:%s" // role id
+	// default of Config.CacheExpireRole
+	RolePermissionCacheDuration = 60 * time.Second
+	// default of Config.CacheCheckRole, the longest another instance use the permissions of a changed role
+	RolePermissionVersionCheck = 5 * time.Second
 )
 
 type RoleType string
@@ -67,20 +82,71 @@ func (s PermissionAction) String() string { return string(s) }
 
 func (s PermissionScope) String() string { return string(s) }
 
+/*
+PermissionSet compiled permission tree of a role, Allows is one map lookup
+
+	*.*.*=* grant everything,
+	the actions of a resource with view false are not granted,
+	the other actions grant {system}.{resource}.{action}.{value} unless value is false
+*/
+type PermissionSet struct {
+	All     bool            `json:"all,omitempty"`
+	Granted map[string]bool `json:"granted,omitempty"`
+}
+
+func CompilePermissions(permissions datatypes.JSON) (*PermissionSet, error) {
+	set := &PermissionSet{Granted: map[string]bool{}}
+	if len(permissions) == 0 {
+		return set, nil
+	}
+	var pt PermissionTree
+	if err := json.Unmarshal(permissions, &pt); err != nil {
+		return nil, err
+	}
+	if pt["*"]["*"]["*"] == "*" {
+		set.All = true
+		return set, nil
+	}
+	for system, resources := range pt {
+		for resource, actions := range resources {
+			if actions["view"] == "false" {
+				continue
+			}
+			for action, value := range actions {
+				if value == "false" {
+					continue
+				}
+				set.Granted[strings.Join([]string{system, resource, action, value}, ".")] = true
+			}
+		}
+	}
+	return set, nil
+}
+
+// Allows the permission name {system}.{resource}.{action}.{scope} is granted
+func (s *PermissionSet) Allows(name string) bool {
+	if s == nil {
+		return false
+	}
+	return s.All || s.Granted[name]
+}
+
 type Role struct {
 	BaseModel
-	Type        RoleType       ` json:"type" gorm:"index:,unique,composite:idx_type_name_tier_level"`
-	Name        string         ` json:"name" gorm:"index:,unique,composite:idx_type_name_tier_level"`
-	Description string         ` json:"description"`
-	Permissions datatypes.JSON ` json:"permissions" gorm:"type:jsonb"`
-	CountStaff  *int64         ` json:"count_staff,omitempty" gorm:"-"`
+	Type             RoleType       ` json:"type" gorm:"index:,unique,composite:idx_type_name_tier_level"`
+	Name             string         ` json:"name" gorm:"index:,unique,composite:idx_type_name_tier_level"`
+	Description      string         ` json:"description"`
+	Permissions      datatypes.JSON ` json:"permissions" gorm:"type:jsonb"`
+	RequireTwoFactor bool           ` json:"require_two_factor" gorm:"default:false"` // staff of this role must enable totp before login
+	CountStaff       *int64         ` json:"count_staff,omitempty" gorm:"-"`
 }
 type RoleUpdate struct {
-	ID          uuid.UUID       `json:"id" form:"-" query:"-" validate:"required,uuid4"`
-	Type        *RoleType       ` json:"type" form:"type" query:"type" validate:"omitempty"`
-	Name        *string         ` json:"name" form:"name" query:"name" validate:"omitempty,max=20"`
-	Description *string         ` json:"description" form:"description" query:"description" validate:"omitempty,max=100"`
-	Permissions *datatypes.JSON ` json:"permissions" form:"permissions" query:"permissions" validate:"omitempty,valid_permissions"`
+	ID               uuid.UUID       `json:"id" form:"-" query:"-" validate:"required,uuid4"`
+	Type             *RoleType       ` json:"type" form:"type" query:"type" validate:"omitempty"`
+	Name             *string         ` json:"name" form:"name" query:"name" validate:"omitempty,max=20"`
+	Description      *string         ` json:"description" form:"description" query:"description" validate:"omitempty,max=100"`
+	Permissions      *datatypes.JSON ` json:"permissions" form:"permissions" query:"permissions" validate:"omitempty,valid_permissions"`
+	RequireTwoFactor *bool           ` json:"require_two_factor" form:"require_two_factor" query:"require_two_factor" validate:"omitempty"`
 }
 
 func (r *RoleUpdate) TableName() string {
@@ -88,10 +154,27 @@ func (r *RoleUpdate) TableName() string {
 }
 
 type RoleSwaggerCreate struct {
-	Type        RoleType       ` json:"type" validate:"required"`
-	Name        string         ` json:"name" validate:"required,max=20"`
-	Description string         ` json:"description" validate:"max=100"`
-	Permissions datatypes.JSON ` json:"permissions" validate:"required,valid_permissions"`
+	Type             RoleType       ` json:"type" validate:"required"`
+	Name             string         ` json:"name" validate:"required,max=20"`
+	Description      string         ` json:"description" validate:"max=100"`
+	Permissions      datatypes.JSON ` json:"permissions" validate:"required,valid_permissions"`
+	RequireTwoFactor bool           ` json:"require_two_factor"`
+}
+
+// RoleDelete query of DELETE /roles/:id, the staff of the role are moved to ReassignTo
+type RoleDelete struct {
+	ReassignTo *string `query:"reassign_to" swagger:"desc(role which receive the staff of the deleted role)" validate:"omitempty,uuid"`
+}
+
+// RoleClone copy the permissions of the role to a new role
+type RoleClone struct {
+	Name        string  `json:"name" form:"name" query:"name" validate:"required,max=20"`
+	Description *string `json:"description,omitempty" form:"description" query:"description" validate:"omitempty,max=100"`
+}
+
+// RoleStaffs set the role of the staff
+type RoleStaffs struct {
+	StaffIDs []uuid.UUID `json:"staff_ids" form:"staff_ids" query:"staff_ids" validate:"required,min=1,max=500"`
 }
 
 type RoleMetadata struct {
@@ -11,6 +11,11 @@ type RoleService interface {
 	GetByID(ctx echo.Context, id string) (*Role, error)
 	Find(ctx echo.Context, pagination Pagination[Role]) (*Pagination[Role], error)
 	HasPermission(ctx echo.Context, roleID *uuid.UUID, requiredPermissions ...string) bool
+	// Delete refuse while staff have the role unless reassignTo receive them
+	Delete(ctx echo.Context, id uuid.UUID, reassignTo *uuid.UUID) error
+	Clone(ctx echo.Context, id uuid.UUID, clone *RoleClone) (*Role, error)
+	AssignStaffs(ctx echo.Context, id uuid.UUID, staffIDs []uuid.UUID) error
+	History(ctx echo.Context, id uuid.UUID) (*Pagination[LogHistory], error)
 	// FindList(ctx context.Context, filter *Filter[RoleFilter]) (*Pagination[*Model[*RoleWithStaffCount]], error)
 	// GetByTypeName(ctx context.Context, roleType RoleType, name string) (*Model[*Role], error)
 	// GetByIDs(ctx context.Context, IDs []uuid.UUID) ([]*Model[*Role], error)
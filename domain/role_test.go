@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+var benchPermissions = datatypes.JSON(`{
+	"admin": {
+		"staff_me": {"view": "true", "log": "true"},
+		"staff": {"view": "organization", "update": "organization", "delete": "organization"},
+		"user": {"view": "true", "create": "true", "update": "true", "delete": "true", "unlock": "true"},
+		"asset": {"view": "organization", "update": "organization", "delete": "organization"},
+		"project": {"view": "false", "create": "true"}
+	}
+}`)
+
+func TestCompilePermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions datatypes.JSON
+		permission  string
+		want        bool
+	}{
+		{name: "granted", permissions: benchPermissions, permission: "admin.user.update.true", want: true},
+		{name: "granted with scope", permissions: benchPermissions, permission: "admin.staff.view.organization", want: true},
+		{name: "other scope", permissions: benchPermissions, permission: "admin.staff.view.true", want: false},
+		{name: "view false block the resource", permissions: benchPermissions, permission: "admin.project.create.true", want: false},
+		{name: "not granted", permissions: benchPermissions, permission: "admin.role.view.true", want: false},
+		{name: "invalid name", permissions: benchPermissions, permission: "admin.user", want: false},
+		{name: "super admin", permissions: datatypes.JSON(`{"*":{"*":{"*":"*"}}}`), permission: "admin.role.update.true", want: true},
+		{name: "empty", permissions: nil, permission: "admin.user.view.true", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := CompilePermissions(tt.permissions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := set.Allows(tt.permission); got != tt.want {
+				t.Errorf("PermissionSet.Allows(%s) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+
+	var set *PermissionSet
+	if set.Allows("admin.user.view.true") {
+		t.Error("nil PermissionSet allows")
+	}
+	if _, err := CompilePermissions(datatypes.JSON(`[]`)); err == nil {
+		t.Error("CompilePermissions() of an array, want error")
+	}
+}
+
+// per request cost before the compiled set, unmarshal the role permissions on every check
+func BenchmarkHasPermission_Unmarshal(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var pt PermissionTree
+		if err := json.Unmarshal(benchPermissions, &pt); err != nil {
+			b.Fatal(err)
+		}
+		args := strings.Split("admin.asset.update.organization", ".")
+		_ = pt[args[0]][args[1]]["view"] != "false" && pt[args[0]][args[1]][args[2]] == args[3]
+	}
+}
+
+// per request cost with the compiled set from the in-process cache
+func BenchmarkPermissionSet_Allows(b *testing.B) {
+	set, err := CompilePermissions(benchPermissions)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = set.Allows("admin.asset.update.organization")
+	}
+}
+
+// cost of a cache miss
+func BenchmarkCompilePermissions(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := CompilePermissions(benchPermissions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var (
+	// granted by RestrictPermissions when the permission of the request has the organization or own scope
+	PermissionScopeKey = ContextKey("permission_scope")
+)
+
+// ScopeGrant resource and scope of the permission which allowed the request
+type ScopeGrant struct {
+	Resource string
+	Scope    PermissionScope
+}
+
+func ScopeFromContext(ctx echo.Context) *ScopeGrant {
+	grant, ok := ctx.Get(string(PermissionScopeKey)).(*ScopeGrant)
+	if !ok {
+		return nil
+	}
+	return grant
+}
+
+// PermissionScoped model of a permission resource, BaseStore filter its rows by the scope granted to the request
+type PermissionScoped interface {
+	PermissionResource() string
+}
+
+// OrganizationScoped model which can be granted with the organization scope
+type OrganizationScoped interface {
+	PermissionScoped
+	OrganizationScope(organizationID uuid.UUID) func(db *gorm.DB) *gorm.DB
+}
+
+// OwnerScoped model which can be granted with the own scope, the rows of the staff
+type OwnerScoped interface {
+	PermissionScoped
+	OwnerScope(staffID uuid.UUID) func(db *gorm.DB) *gorm.DB
+}
+
+/*
+WithPermissionScope filter the rows of model by the scope granted to the request
+
+	the request without grant or granted on another resource is not filtered,
+	the model which does not support the granted scope or the request without staff see nothing
+*/
+func WithPermissionScope(ctx echo.Context, model any) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !IsScoped(ctx, model) {
+			return db
+		}
+		staff := StaffFromContext(ctx)
+		switch ScopeFromContext(ctx).Scope {
+		case PermissionScopeOrg:
+			scoped, ok := model.(OrganizationScoped)
+			if !ok || staff == nil || staff.OrganizationID == nil {
+				// staff without organization see nothing
+				break
+			}
+			return scoped.OrganizationScope(*staff.OrganizationID)(db)
+		case PermissionScopeOwn:
+			scoped, ok := model.(OwnerScoped)
+			if !ok || staff == nil {
+				break
+			}
+			return scoped.OwnerScope(staff.ID)(db)
+		}
+		return db.Where("1 = 0")
+	}
+}
+
+// IsScoped the rows of model are filtered for the request
+func IsScoped(ctx echo.Context, model any) bool {
+	grant := ScopeFromContext(ctx)
+	if grant == nil {
+		return false
+	}
+	scoped, ok := model.(PermissionScoped)
+	return ok && scoped.PermissionResource() == grant.Resource
+}
+
+func (Staff) PermissionResource() string { return "staff" }
+
+func (User) PermissionResource() string { return "user" }
+
+func (Asset) PermissionResource() string { return "asset" }
+
+// users (leads) the staff is responsible for
+func (User) OwnerScope(staffID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.staff_id = ?", staffID)
+	}
+}
+
+// assets of the users the staff is responsible for
+func (Asset) OwnerScope(staffID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		users := db.Session(&gorm.Session{NewDB: true}).Table("users").Select("id").
+			Where("deleted_at IS NULL AND staff_id = ?", staffID)
+		return db.Where("assets.user_id IN (?)", users)
+	}
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestWithPermissionScope(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgID := uuid.New()
+	tests := []struct {
+		name     string
+		grant    *ScopeGrant
+		staff    *Staff
+		model    any
+		contains string
+		excludes string
+	}{
+		{name: "without grant", model: User{}, excludes: "staff_id"},
+		{name: "grant of another resource", grant: &ScopeGrant{Resource: "staff", Scope: PermissionScopeOrg}, staff: &Staff{OrganizationID: &orgID}, model: User{}, excludes: "staff_id"},
+		{name: "users of the organization", grant: &ScopeGrant{Resource: "user", Scope: PermissionScopeOrg}, staff: &Staff{OrganizationID: &orgID}, model: User{}, contains: "users.staff_id IN (SELECT id FROM \"staffs\" WHERE deleted_at IS NULL AND organization_id ="},
+		{name: "staff of the organization", grant: &ScopeGrant{Resource: "staff", Scope: PermissionScopeOrg}, staff: &Staff{OrganizationID: &orgID}, model: Staff{}, contains: "staffs.organization_id ="},
+		{name: "assets of the organization", grant: &ScopeGrant{Resource: "asset", Scope: PermissionScopeOrg}, staff: &Staff{OrganizationID: &orgID}, model: Asset{}, contains: "assets.user_id IN (SELECT id FROM \"users\""},
+		{name: "staff without organization", grant: &ScopeGrant{Resource: "user", Scope: PermissionScopeOrg}, staff: &Staff{}, model: User{}, contains: "1 = 0"},
+		{name: "users of the staff", grant: &ScopeGrant{Resource: "user", Scope: PermissionScopeOwn}, staff: &Staff{BaseModel: BaseModel{ID: uuid.New()}}, model: User{}, contains: "users.staff_id ="},
+		{name: "assets of the users of the staff", grant: &ScopeGrant{Resource: "asset", Scope: PermissionScopeOwn}, staff: &Staff{BaseModel: BaseModel{ID: uuid.New()}}, model: Asset{}, contains: "assets.user_id IN (SELECT id FROM \"users\" WHERE deleted_at IS NULL AND staff_id ="},
+		{name: "own scope without staff", grant: &ScopeGrant{Resource: "user", Scope: PermissionScopeOwn}, model: User{}, contains: "1 = 0"},
+		{name: "model without own scope", grant: &ScopeGrant{Resource: "staff", Scope: PermissionScopeOwn}, staff: &Staff{BaseModel: BaseModel{ID: uuid.New()}}, model: Staff{}, contains: "1 = 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			if tt.grant != nil {
+				c.Set(string(PermissionScopeKey), tt.grant)
+			}
+			if tt.staff != nil {
+				c.Set(StaffCtx, tt.staff)
+			}
+			sql := db.Table("rows").Scopes(WithPermissionScope(c, tt.model)).Find(&[]map[string]any{}).Statement.SQL.String()
+			if tt.contains != "" && !strings.Contains(sql, tt.contains) {
+				t.Errorf("WithPermissionScope() sql = %s, want %s", sql, tt.contains)
+			}
+			if tt.excludes != "" && strings.Contains(sql, tt.excludes) {
+				t.Errorf("WithPermissionScope() sql = %s, should not filter %s", sql, tt.excludes)
+			}
+		})
+	}
+}
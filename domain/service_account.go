@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+)
+
+var (
+	APIKeyHeader         = "X-API-Key"
+	APIKeyPrefixLength   = 12
+	APIKeyLastUsedWindow = time.Minute // last_used_at is written at most once per window
+
+	ServiceAccountKey = ContextKey("service_account")
+
+	// Log
+	RotateAPIKeyLog = "rotate_api_key"
+)
+
+// ServiceAccount machine to machine client (lead form, reporting job), authenticated with X-API-Key
+// the api key is <prefix>.<secret>, prefix is stored in plain text to find the account and the key is stored as sha256
+type ServiceAccount struct {
+	BaseModel
+	Name        string                      `json:"name" gorm:"type:varchar(255);not null;uniqueIndex" filter:"like"`
+	Description string                      `json:"description" gorm:"type:text"`
+	KeyPrefix   string                      `json:"key_prefix" gorm:"type:varchar(32);not null;uniqueIndex"`
+	KeyHash     string                      `json:"-" gorm:"type:varchar(64);not null"`
+	Scopes      datatypes.JSONSlice[string] `json:"scopes" gorm:"type:jsonb"` // permission names e.g. admin.developer.view.true
+	ExpiresAt   *time.Time                  `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time                  `json:"last_used_at,omitempty"`
+	CreatedByID *uuid.UUID                  `json:"created_by_id,omitempty" gorm:"type:uuid"`
+}
+
+type ServiceAccountCreate struct {
+	Name        string     `json:"name" validate:"required,max=255" form:"name" query:"name"`
+	Description string     `json:"description" validate:"max=1000" form:"description" query:"description"`
+	Scopes      []string   `json:"scopes" validate:"required,min=1,dive,permission" form:"scopes" query:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" validate:"omitempty" form:"expires_at" query:"expires_at"`
+}
+
+type ServiceAccountUpdate struct {
+	ID          uuid.UUID                    `json:"id" validate:"required,uuid" form:"-" query:"-"`
+	Name        *string                      `json:"name,omitempty" validate:"omitempty,max=255" form:"name" query:"name"`
+	Description *string                      `json:"description,omitempty" validate:"omitempty,max=1000" form:"description" query:"description"`
+	Scopes      *datatypes.JSONSlice[string] `json:"scopes,omitempty" validate:"omitempty,min=1,dive,permission" form:"scopes" query:"scopes"`
+	ExpiresAt   *time.Time                   `json:"expires_at,omitempty" validate:"omitempty" form:"expires_at" query:"expires_at"`
+}
+
+func (ServiceAccountUpdate) TableName() string {
+	return "service_accounts"
+}
+
+// ServiceAccountAPIKey api key is shown only once, on create and rotate
+type ServiceAccountAPIKey struct {
+	ServiceAccount
+	APIKey string `json:"api_key"`
+}
+
+func (a ServiceAccount) Expired() bool {
+	return a.ExpiresAt != nil && TimeNow().After(*a.ExpiresAt)
+}
+
+// HasScope the account has one of the permissions
+func (a ServiceAccount) HasScope(permissions ...string) bool {
+	for _, scope := range a.Scopes {
+		for _, permission := range permissions {
+			if scope == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SplitAPIKey prefix and secret of <prefix>.<secret>
+func SplitAPIKey(apiKey string) (prefix, secret string, ok bool) {
+	prefix, secret, ok = strings.Cut(apiKey, ".")
+	if !ok || len(prefix) != APIKeyPrefixLength || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+type ServiceAccountService interface {
+	Create(ctx echo.Context, create *ServiceAccountCreate) (*ServiceAccountAPIKey, error)
+	Update(ctx echo.Context, update *ServiceAccountUpdate) error
+	GetByID(ctx echo.Context, id string) (*ServiceAccount, error)
+	Find(ctx echo.Context, pagination Pagination[ServiceAccount]) (*Pagination[ServiceAccount], error)
+	Delete(ctx echo.Context, id uuid.UUID) error
+	// RotateKey issue a new api key, the old key stop working immediately
+	RotateKey(ctx echo.Context, id string) (*ServiceAccountAPIKey, error)
+	// Authenticate account of the X-API-Key header
+	Authenticate(ctx echo.Context, apiKey string) (*ServiceAccount, error)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	SessionIDKey = ContextKey("session_id")
+
+	// Log
+	RevokeSessionLog = "revoke_session"
+)
+
+// SessionLastSeenWindow last_seen_at is written at most once per window
+const SessionLastSeenWindow = time.Minute
+
+// Session is created on every login, one per device.
+// The session id is also the family id of refresh tokens issued from this login.
+type Session struct {
+	BaseModel
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;index:,option:CONCURRENTLY;not null"`
+	DeviceID   string     `json:"device_id" gorm:"type:varchar(255);index"`
+	UserAgent  string     `json:"user_agent" gorm:"type:text"`
+	IP         string     `json:"ip" gorm:"type:varchar(64)"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"index"`
+
+	// current session of the caller
+	Current bool `json:"current" gorm:"-"`
+}
+
+func (s Session) Revoked() bool {
+	return s.RevokedAt != nil
+}
+
+type SessionService interface {
+	Create(ctx echo.Context, userID uuid.UUID, deviceID string) (*Session, error)
+	Verify(ctx echo.Context, userID, sessionID uuid.UUID) error
+	Touch(ctx echo.Context, userID, sessionID uuid.UUID) error
+	Revoke(ctx echo.Context, userID uuid.UUID, sessionID string) error
+	RevokeAll(ctx echo.Context, userID uuid.UUID) error
+
+	// current staff or user
+	FindMe(ctx echo.Context) ([]Session, error)
+	RevokeMe(ctx echo.Context, sessionID string) error
+}
+
+func SessionID(ctx echo.Context) string {
+	sessionID, ok := ctx.Get(string(SessionIDKey)).(string)
+	if !ok {
+		return ""
+	}
+	return sessionID
+}
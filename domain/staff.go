@@ -13,9 +13,13 @@ import (
 
 var (
 	StaffCtx                       = "staff"
-	StaffAuthCache                 = "backlist:email:%s"
+	StaffAuthCache                 = "lockout:staff:email:%s"
 	StaffVerifyTokenType TokenType = "verify_token"
 
+	// lockout of the staff login, see auth.Lockout
+	StaffLockedCache = "lockout:staff:locked:%s" // email
+	StaffIPCache     = "lockout:staff:ip:%s"     // ip
+
 	// Log
 	UnlockLog = "unlock"
 
@@ -24,6 +28,11 @@ var (
 
 	ChangePasswordLog       = "change_password"
 	ChangePasswordFailedLog = "change_password_failed"
+	ForgotPasswordLog       = "forgot_password"
+	ResetPasswordLog        = "reset_password"
+	RehashPasswordLog       = "rehash_password"
+
+	StaffPasswordResetCache = "password_reset:staff:%s" // token
 )
 
 // ----
@@ -51,9 +60,16 @@ type Staff struct {
 	Status      Status          `json:"status" gorm:"default:pending" validate:"staff_status" filter:"="`
 	Phone       *string         `json:"phone,omitempty" gorm:"varchar(255);" validate:"omitempty,phone" filter:"="`
 
+	// password older than PasswordPolicy.MaxAge must be changed, nil since created
+	PasswordChangedAt *time.Time `json:"-"`
+
 	// fk role nullable
 	RoleID *uuid.UUID `json:"-" gorm:"type:uuid;index:,option:CONCURRENTLY;" validate:"omitempty,uuid" filter:"="`
 	Role   *Role      `json:"role,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// fk organization nullable, the organization scope of the permissions filter by it
+	OrganizationID *uuid.UUID    `json:"organization_id,omitempty" gorm:"type:uuid;index" validate:"omitempty,uuid" filter:"="`
+	Organization   *Organization `json:"organization,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
 
 func (s *Staff) BeforeCreate(tx *gorm.DB) (err error) {
@@ -77,6 +93,8 @@ type StaffVerifyTokenResponse struct {
 type StaffLogin struct {
 	Email    SensitiveString `json:"email" validate:"required" query:"email" swagger:"desc(email),required" form:"email" `
 	Password string          `json:",omitempty" validate:"required" query:"password" swagger:"desc(password),required" form:"password"`
+	DeviceID string          `json:"device_id,omitempty" validate:"omitempty,uuid4" query:"device_id" swagger:"desc(device_id)" form:"device_id"`
+	TOTPCode string          `json:"totp_code,omitempty" query:"totp_code" swagger:"desc(totp or recovery code)" form:"totp_code"`
 }
 
 type StaffUnlock struct {
@@ -90,6 +108,8 @@ type StaffCreate struct {
 	Phone     *string         `json:"phone,omitempty" validate:"omitempty,phone" query:"phone" swagger:"desc(phone)" form:"phone"`
 
 	RoleID *string `json:"role_id" validate:"omitempty,uuid" query:"role_id" swagger:"desc(role_id)" form:"role_id"`
+
+	OrganizationID *string `json:"organization_id,omitempty" validate:"omitempty,uuid" query:"organization_id" swagger:"desc(organization_id)" form:"organization_id"`
 }
 type StaffUpdate struct {
 	ID        uuid.UUID `json:"id" query:"-" form:"-"`
@@ -101,6 +121,8 @@ type StaffUpdate struct {
 	Phone     *string   `json:"phone,omitempty" gorm:"varchar(255);default:''" validate:"omitempty,phone"`
 
 	RoleID *string `json:"role_id,omitempty" query:"role_id" swagger:"desc(role_id)" form:"role_id" validate:"omitempty,uuid"`
+
+	OrganizationID *string `json:"organization_id,omitempty" query:"organization_id" swagger:"desc(organization_id)" form:"organization_id" validate:"omitempty,uuid"`
 }
 
 func (StaffUpdate) TableName() string {
@@ -135,6 +157,8 @@ type StaffMe struct {
 	// fk role
 	RoleID *uuid.UUID `json:"role_id,omitempty"`
 	Role   *Role      `json:"role,omitempty" `
+
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
 }
 
 func (StaffMe) TableName() string {
@@ -179,7 +203,7 @@ func GenerateVerifyToken(token, secret string, duration time.Duration) (string,
 		Token:     token,
 	}
 
-	t, err := GenerateAccessToken(claims, string(StaffVerifyTokenType))
+	t, err := GenerateAccessToken(claims, secret)
 	if err != nil {
 		logger.L().Error(err)
 		return "", err
@@ -187,11 +211,11 @@ func GenerateVerifyToken(token, secret string, duration time.Duration) (string,
 	return t, nil
 }
 
-func ParseVerifyToken(token string) error {
+func ParseVerifyToken(token, secret string) (*VerifyTokenClaims, error) {
 	var claims VerifyTokenClaims
-	if _, err := ParseToken(token, &claims, string(StaffVerifyTokenType)); err != nil {
+	if _, err := ParseToken(token, &claims, secret); err != nil {
 		logger.L().Error(err)
-		return err
+		return nil, err
 	}
-	return nil
+	return &claims, nil
 }
@@ -1,12 +1,17 @@
 package domain
 
-import "github.com/labstack/echo/v4"
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
 
 type StaffService interface {
 	Get(ctx echo.Context, id string) (*Staff, error)
 	Find(ctx echo.Context, pagination Pagination[Staff]) (*Pagination[Staff], error)
 	Create(ctx echo.Context, staff StaffCreate) (*Staff, error)
 	LoginWithEmailPassword(ctx echo.Context, login StaffLogin) (*AuthResult, error)
+	Authenticate(ctx echo.Context, login StaffLogin) (*Staff, error)
+	CompleteLogin(ctx echo.Context, staff *Staff, deviceID, totpCode string) (*AuthResult, error)
 	Unlock(ctx echo.Context, email StaffUnlock) error
 	Delete(ctx echo.Context) error
 	GetByEmail(ctx echo.Context, email SensitiveString) (*Staff, error)
@@ -14,10 +19,15 @@ type StaffService interface {
 	Verify(ctx echo.Context, staff StaffVerifyToken) (*StaffVerifyTokenResponse, error)
 	GetToken(ctx echo.Context, staff StaffGetToken) (*StaffGetTokenResponse, error)
 	UpdatePassword(ctx echo.Context, staff StaffUpdatePassword) error
+	ForgotPassword(ctx echo.Context, req PasswordForgot) error
+	ResetPassword(ctx echo.Context, req PasswordReset) error
+	ChangePassword(ctx echo.Context, req PasswordChange) error
 	GetLog(ctx echo.Context, staff StaffGetLog) (*Pagination[*Logs[Staff]], error)
 
 	GetMe(ctx echo.Context) (*StaffMe, error)
 
 	// log
 	GetLogMe(ctx echo.Context) (*Pagination[*Logs[Staff]], error)
+	// History field by field timeline of the changes of the staff
+	History(ctx echo.Context, id uuid.UUID) (*Pagination[LogHistory], error)
 }
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+)
+
+var (
+	TwoFactorIssuer            = "go_base"
+	TwoFactorRecoveryCodeCount = 10
+	TwoFactorRecoveryCodeLen   = 10
+
+	// Log
+	TwoFactorEnableLog  = "two_factor_enable"
+	TwoFactorDisableLog = "two_factor_disable"
+	TwoFactorRecoverLog = "two_factor_recover"
+)
+
+// TwoFactor totp of the staff, enabled after the first code is verified
+type TwoFactor struct {
+	BaseModel
+	UserID        uuid.UUID      `json:"user_id" gorm:"type:uuid;uniqueIndex"`
+	Secret        string         `json:"-" gorm:"type:varchar(255);not null"`
+	RecoveryCodes datatypes.JSON `json:"-" gorm:"type:jsonb"` // bcrypt hashed
+	EnabledAt     *time.Time     `json:"enabled_at,omitempty"`
+}
+
+func (t TwoFactor) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorEnrollment secret and recovery codes are shown only once
+type TwoFactorEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorEnable struct {
+	Email    SensitiveString `json:"email" validate:"required,email" query:"email" swagger:"desc(email),required" form:"email"`
+	Password string          `json:"password" validate:"required" query:"password" swagger:"desc(password),required" form:"password"`
+	Code     string          `json:"code" validate:"required,numeric,len=6" query:"code" swagger:"desc(totp code),required" form:"code"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code" validate:"required" query:"code" swagger:"desc(totp or recovery code),required" form:"code"`
+}
+
+type TwoFactorService interface {
+	// enrollment before login, role may require two factor at first login
+	Enroll(ctx echo.Context, login StaffLogin) (*TwoFactorEnrollment, error)
+	Enable(ctx echo.Context, req TwoFactorEnable) error
+	// current staff
+	Disable(ctx echo.Context, req TwoFactorCode) error
+
+	IsEnabled(ctx echo.Context, userID uuid.UUID) (bool, error)
+	Verify(ctx echo.Context, userID uuid.UUID, code string) error
+}
@@ -12,8 +12,14 @@ import (
 
 var (
 	UserCtx                       = "user"
-	UserAuthCache                 = "backlist:email:%s"
+	UserAuthCache                 = "lockout:user:email:%s"
 	UserVerifyTokenType TokenType = "verify_token"
+
+	// lockout of the user login, see auth.Lockout
+	UserLockedCache = "lockout:user:locked:%s" // email
+	UserIPCache     = "lockout:user:ip:%s"     // ip
+
+	UserPasswordResetCache = "password_reset:user:%s" // token
 )
 
 // ----
@@ -29,13 +35,16 @@ type User struct {
 	IsVerified  bool            `json:"is_verified" gorm:"default:false" validate:"bool"`
 	VerifyToken string          `json:"-" gorm:"default:''" validate:"lowercase"`
 
+	// password older than PasswordPolicy.MaxAge must be changed, nil since created
+	PasswordChangedAt *time.Time `json:"-"`
+
 	// Meta data
 	// งบประมาณ (ซื้อ)
-	BudgetBuy *float64 `json:"budget_buy,omitempty" gorm:"type:numeric(17,2);default:0.00"`
+	BudgetBuy *float64 `json:"budget_buy,omitempty" gorm:"type:numeric(17,2);default:0.00" perm:"admin.user.budget"`
 	// งบประมาณ (ขาย)
-	BudgetSell *float64 `json:"budget_sell,omitempty" gorm:"type:numeric(17,2);default:0.00"`
+	BudgetSell *float64 `json:"budget_sell,omitempty" gorm:"type:numeric(17,2);default:0.00" perm:"admin.user.budget"`
 	// งบประมาณ (เช่า)
-	BudgetPerMonth *float64 `json:"budget_per_month,omitempty" gorm:"type:numeric(17,2);default:0.00"`
+	BudgetPerMonth *float64 `json:"budget_per_month,omitempty" gorm:"type:numeric(17,2);default:0.00" perm:"admin.user.budget"`
 
 	Phone *string `json:"phone,omitempty" gorm:"varchar(255);" validate:"omitempty,phone" filter:"="`
 
@@ -99,6 +108,7 @@ type UserVerifyTokenResponse struct {
 type UserLogin struct {
 	Email    SensitiveString `json:"email" validate:"required" query:"email" swagger:"desc(email),required" form:"email" `
 	Password string          `json:",omitempty" validate:"required" query:"password" swagger:"desc(password),required" form:"password"`
+	DeviceID string          `json:"device_id,omitempty" validate:"omitempty,uuid4" query:"device_id" swagger:"desc(device_id)" form:"device_id"`
 }
 
 type UserUnlock struct {
@@ -118,9 +128,9 @@ type UserUpdate struct {
 
 	// Meta data
 	// งบประมาณ
-	BudgetBuy      *float64 `json:"budget_buy,omitempty" form:"budget_buy" query:"budget_buy"`
-	BudgetSell     *float64 `json:"budget_sell,omitempty" form:"budget_sell" query:"budget_sell"`
-	BudgetPerMonth *float64 `json:"budget_per_month,omitempty" form:"budget_per_month" query:"budget_per_month"`
+	BudgetBuy      *float64 `json:"budget_buy,omitempty" form:"budget_buy" query:"budget_buy" perm:"admin.user.budget"`
+	BudgetSell     *float64 `json:"budget_sell,omitempty" form:"budget_sell" query:"budget_sell" perm:"admin.user.budget"`
+	BudgetPerMonth *float64 `json:"budget_per_month,omitempty" form:"budget_per_month" query:"budget_per_month" perm:"admin.user.budget"`
 
 	// แหล่งที่มา
 	Source *string `json:"source,omitempty" form:"source" query:"source"`
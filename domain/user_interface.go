@@ -1,12 +1,17 @@
 package domain
 
-import "github.com/labstack/echo/v4"
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
 
 type UserService interface {
 	Get(ctx echo.Context, id string) (*User, error)
 	Find(ctx echo.Context, pagination Pagination[User]) (*Pagination[User], error)
 	Create(ctx echo.Context, user UserCreate) (*User, error)
 	LoginWithEmailPassword(ctx echo.Context, login UserLogin) (*AuthResult, error)
+	Authenticate(ctx echo.Context, login UserLogin) (*User, error)
+	CompleteLogin(ctx echo.Context, user *User, deviceID string) (*AuthResult, error)
 	Unlock(ctx echo.Context, email UserUnlock) error
 	Delete(ctx echo.Context) error
 	GetByEmail(ctx echo.Context, email SensitiveString) (*User, error)
@@ -14,6 +19,9 @@ type UserService interface {
 	Verify(ctx echo.Context, user UserVerifyToken) (*UserVerifyTokenResponse, error)
 	GetToken(ctx echo.Context, user UserGetToken) (*UserGetTokenResponse, error)
 	UpdatePassword(ctx echo.Context, user UserUpdatePassword) error
+	ForgotPassword(ctx echo.Context, req PasswordForgot) error
+	ResetPassword(ctx echo.Context, req PasswordReset) error
+	ChangePassword(ctx echo.Context, req PasswordChange) error
 	GetLog(ctx echo.Context, user UserGetLog) (*Pagination[*Logs[User]], error)
 
 	GetMe(ctx echo.Context) (*UserMe, error)
@@ -21,6 +29,11 @@ type UserService interface {
 
 	// log
 	GetLogMe(ctx echo.Context) (*Pagination[*Logs[User]], error)
+	// History field by field timeline of the changes of the user
+	History(ctx echo.Context, id uuid.UUID) (*Pagination[LogHistory], error)
 
 	DeleteByIds(ctx echo.Context, ids Ids) error
+
+	// Impersonate short lived access token of the user for the current staff, no refresh token
+	Impersonate(ctx echo.Context, id string) (*ImpersonationToken, error)
 }
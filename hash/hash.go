@@ -1,18 +1,25 @@
 package hash
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"math/big"
 	"regexp"
 	"strings"
-	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// MaxRandomStringLength longest string of GenerateRandomString
+const MaxRandomStringLength = 128
+
 var (
-	CharsetV1 = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	CharsetV2 = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*_+"
-	CharsetV3 = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()_+{}[]:;\"'<>?,./|\\"
+	CharsetV1      = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	CharsetV2      = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*_+"
+	CharsetNumeric = "0123456789"
+	CharsetV3      = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()_+{}[]:;\"'<>?,./|\\"
 
 	bcryptRegex = "^\\$2(?:a|b|x|y)\\$(?:[4-9]|[12][0-9]|3[01])\\$(?:[a-zA-Z\\d\\./]{22})(?:[a-zA-Z\\d\\./]{31})$"
 )
@@ -38,6 +45,7 @@ func Trim(password string) string {
 	return strings.ReplaceAll(password, " ", "")
 }
 
+// GenerateRandomString crypto/rand string of charset (CharsetV2 by default), at most MaxRandomStringLength characters
 func GenerateRandomString(length int, charsetOpt ...string) string {
 	if length <= 0 {
 		return ""
@@ -46,31 +54,52 @@ func GenerateRandomString(length int, charsetOpt ...string) string {
 	if len(charsetOpt) > 0 {
 		charset = charsetOpt[0]
 	}
-	if length > 128 {
-		length = 128
-	}
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[RandomInt(len(charset))]
+	if length > MaxRandomStringLength {
+		length = MaxRandomStringLength
 	}
-	return string(b)
+	return must(RandomString(length, charset))
 }
 
+// RandomInt uniform in [0, max) from crypto/rand
 func RandomInt(max int) int {
 	if max <= 0 {
 		return 0
 	}
-	ran := rand.New(rand.NewSource(time.Now().UnixNano()))
-	return ran.Intn(max)
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		panic(err)
+	}
+	return int(n.Int64())
 }
 
+// GenerateToken url-safe token of TokenBytes random bytes
 func GenerateToken() string {
-	return GenerateRandomString(32)
+	return must(RandomToken(TokenBytes))
+}
+
+// must crypto/rand fail only when the entropy source of the os is broken, nothing can be generated safely
+func must(s string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return s
 }
 
+// IsHashed password is bcrypt or argon2id hash
 func IsHashed(password string) bool {
 	if ok, _ := regexp.MatchString(bcryptRegex, password); ok {
 		return true
 	}
-	return false
+	return isArgon2id(password)
+}
+
+// HashToken sha256 of a high entropy token (api key), bcrypt / argon2id are too slow to run on every request
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompareToken compare token with HashToken in constant time
+func CompareToken(hashed, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashed), []byte(HashToken(token))) == 1
 }
@@ -354,3 +354,23 @@ func TestIsHashed(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareToken(t *testing.T) {
+	hashed := HashToken("prefix.secret")
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "same token", token: "prefix.secret", want: true},
+		{name: "other secret", token: "prefix.other", want: false},
+		{name: "empty", token: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareToken(hashed, tt.token); got != tt.want {
+				t.Errorf("CompareToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package hash
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+
+	argon2idPrefix = "$argon2id$"
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// Hasher hash new passwords, ComparePassword accept the hash of every Hasher
+type Hasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash hash is not of this algorithm or parameters
+	NeedsRehash(hashed string) bool
+}
+
+// Argon2Params argon2id parameters, memory in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params OWASP recommendation, 19 MiB / 2 iterations / 1 thread
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var defaultHasher Hasher = NewArgon2idHasher(DefaultArgon2Params)
+
+// SetDefaultHasher hasher of new passwords, set once on start
+func SetDefaultHasher(h Hasher) {
+	defaultHasher = h
+}
+
+// NewHasher hasher of the algorithm, argon2id when empty
+func NewHasher(algorithm string, params Argon2Params, bcryptCost int) (Hasher, error) {
+	switch algorithm {
+	case AlgorithmArgon2id, "":
+		return NewArgon2idHasher(params), nil
+	case AlgorithmBcrypt:
+		return NewBcryptHasher(bcryptCost), nil
+	default:
+		return nil, fmt.Errorf("password hash algorithm not supported: %s", algorithm)
+	}
+}
+
+// HashPassword hash with the default hasher
+func HashPassword(password string) (string, error) {
+	return defaultHasher.Hash(password)
+}
+
+// ComparePassword compare argon2id or bcrypt hash, the format is taken from the hash
+func ComparePassword(hashed, password string) bool {
+	if strings.HasPrefix(hashed, argon2idPrefix) {
+		return compareArgon2id(hashed, password)
+	}
+	return CompareBcrypt(hashed, password)
+}
+
+// NeedsRehash hash is not of the default hasher, rehash on the next successful login
+func NeedsRehash(hashed string) bool {
+	return defaultHasher.NeedsRehash(hashed)
+}
+
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Params.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultArgon2Params.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	return &Argon2idHasher{params: params}
+}
+
+// Hash PHC string format $argon2id$v=19$m=,t=,p=$salt$key
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt, err := RandomBytes(int(h.params.SaltLength))
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hashed string) bool {
+	params, _, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+func compareArgon2id(hashed, password string) bool {
+	params, salt, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+func decodeArgon2id(hashed string) (*Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return &params, salt, key, nil
+}
+
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	return HashBcrypt(password, h.cost)
+}
+
+func (h *BcryptHasher) NeedsRehash(hashed string) bool {
+	cost, err := bcrypt.Cost([]byte(hashed))
+	if err != nil {
+		return true
+	}
+	return cost != h.cost
+}
+
+// isArgon2id hash is a valid argon2id PHC string
+func isArgon2id(hashed string) bool {
+	_, _, _, err := decodeArgon2id(hashed)
+	return err == nil
+}
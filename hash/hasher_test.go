@@ -0,0 +1,93 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComparePassword(t *testing.T) {
+	fast := Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+	argon, err := NewArgon2idHasher(fast).Hash("Passw0rd!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := HashBcrypt("Passw0rd!", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		hashed   string
+		password string
+		want     bool
+	}{
+		{name: "argon2id", hashed: argon, password: "Passw0rd!", want: true},
+		{name: "argon2id wrong password", hashed: argon, password: "passw0rd!", want: false},
+		{name: "bcrypt", hashed: legacy, password: "Passw0rd!", want: true},
+		{name: "bcrypt wrong password", hashed: legacy, password: "Passw0rd", want: false},
+		{name: "invalid argon2id", hashed: "$argon2id$v=19$m=1024$abc$def", password: "Passw0rd!", want: false},
+		{name: "empty", hashed: "", password: "Passw0rd!", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComparePassword(tt.hashed, tt.password); got != tt.want {
+				t.Errorf("ComparePassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgon2idHasher_Hash(t *testing.T) {
+	h := NewArgon2idHasher(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1})
+	a, _ := h.Hash("Passw0rd!")
+	b, _ := h.Hash("Passw0rd!")
+	if !strings.HasPrefix(a, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Hash() = %v", a)
+	}
+	if a == b {
+		t.Error("Hash() salt is not random")
+	}
+	if !IsHashed(a) {
+		t.Errorf("IsHashed(%v) = false", a)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	params := Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+	current, _ := NewArgon2idHasher(params).Hash("Passw0rd!")
+	weaker, _ := NewArgon2idHasher(Argon2Params{Memory: 512, Iterations: 1, Parallelism: 1}).Hash("Passw0rd!")
+	legacy, _ := HashBcrypt("Passw0rd!", 4)
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hashed string
+		want   bool
+	}{
+		{name: "argon2id same params", hasher: NewArgon2idHasher(params), hashed: current, want: false},
+		{name: "argon2id other params", hasher: NewArgon2idHasher(params), hashed: weaker, want: true},
+		{name: "bcrypt to argon2id", hasher: NewArgon2idHasher(params), hashed: legacy, want: true},
+		{name: "bcrypt same cost", hasher: NewBcryptHasher(4), hashed: legacy, want: false},
+		{name: "bcrypt other cost", hasher: NewBcryptHasher(5), hashed: legacy, want: true},
+		{name: "argon2id to bcrypt", hasher: NewBcryptHasher(4), hashed: current, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hashed); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	if _, err := NewHasher("md5", Argon2Params{}, 0); err == nil {
+		t.Error("NewHasher() unknown algorithm should fail")
+	}
+	h, err := NewHasher("", Argon2Params{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.(*Argon2idHasher); !ok {
+		t.Errorf("NewHasher() default = %T, want *Argon2idHasher", h)
+	}
+}
@@ -0,0 +1,59 @@
+package hash
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+// TokenBytes entropy of GenerateToken, 192 bits encoded as 32 url-safe chars
+const TokenBytes = 24
+
+var ErrInvalidCharset = errors.New("charset must have 1 to 256 characters")
+
+// RandomBytes n bytes from crypto/rand
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// RandomString length characters of charset from crypto/rand, bytes over the
+// largest multiple of len(charset) are rejected so every character has the same probability
+func RandomString(length int, charset string) (string, error) {
+	if len(charset) == 0 || len(charset) > 256 {
+		return "", ErrInvalidCharset
+	}
+	if length <= 0 {
+		return "", nil
+	}
+	limit := 256 - 256%len(charset)
+	result := make([]byte, 0, length)
+	buf := make([]byte, length+length/4+1)
+	for len(result) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, charset[int(b)%len(charset)])
+			if len(result) == length {
+				break
+			}
+		}
+	}
+	return string(result), nil
+}
+
+// RandomToken n random bytes as url-safe base64 without padding, for links and keys
+func RandomToken(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,110 @@
+package hash
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestRandomString(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		charset string
+		wantLen int
+		wantErr bool
+	}{
+		{name: "numeric", length: 6, charset: CharsetNumeric, wantLen: 6},
+		{name: "alphanumeric", length: 32, charset: CharsetV1, wantLen: 32},
+		{name: "zero length", length: 0, charset: CharsetV1, wantLen: 0},
+		{name: "single char", length: 4, charset: "a", wantLen: 4},
+		{name: "empty charset", length: 4, charset: "", wantErr: true},
+		{name: "charset too long", length: 4, charset: strings.Repeat("a", 257), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RandomString(tt.length, tt.charset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RandomString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantLen {
+				t.Errorf("RandomString() = %v, wantLen %v", got, tt.wantLen)
+			}
+			for _, c := range got {
+				if !strings.ContainsRune(tt.charset, c) {
+					t.Errorf("RandomString() = %v, %q is not in charset", got, c)
+				}
+			}
+		})
+	}
+}
+
+// every character of the charset is sampled close to the expected count,
+// CharsetV2 has 72 characters which doesn't divide 256 so modulo bias would show
+func TestRandomString_Distribution(t *testing.T) {
+	const samples = 720000
+	s, err := RandomString(samples, CharsetV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+	if len(counts) != len(CharsetV2) {
+		t.Fatalf("RandomString() sampled %d characters, want %d", len(counts), len(CharsetV2))
+	}
+	expected := float64(samples) / float64(len(CharsetV2))
+	var chiSquare float64
+	for _, count := range counts {
+		d := float64(count) - expected
+		chiSquare += d * d / expected
+	}
+	// 71 degrees of freedom, p = 0.0001 critical value is about 120
+	if chiSquare > 120 {
+		t.Errorf("RandomString() chi-square = %.2f, distribution is not uniform", chiSquare)
+	}
+}
+
+func TestRandomToken_Unique(t *testing.T) {
+	const n = 10000
+	seen := make(map[string]struct{}, n)
+	for i := 0; i < n; i++ {
+		token := GenerateToken()
+		if _, ok := seen[token]; ok {
+			t.Fatalf("GenerateToken() collision after %d tokens", i)
+		}
+		seen[token] = struct{}{}
+	}
+}
+
+func TestRandomToken(t *testing.T) {
+	token, err := RandomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatalf("RandomToken() = %v is not url-safe base64: %v", token, err)
+	}
+	if len(b) != 32 {
+		t.Errorf("RandomToken() decoded %d bytes, want 32", len(b))
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Errorf("RandomToken() = %v is not url-safe", token)
+	}
+}
+
+func TestRandomInt_Range(t *testing.T) {
+	seen := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		n := RandomInt(10)
+		if n < 0 || n >= 10 {
+			t.Fatalf("RandomInt() = %v, want [0, 10)", n)
+		}
+		seen[n] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("RandomInt() sampled %d values, want 10", len(seen))
+	}
+}
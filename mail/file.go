@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"go_base/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileTransport write every message as .eml file, for development and tests
+type FileTransport struct {
+	dir string
+}
+
+func NewFileTransport(dir string) (*FileTransport, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "mail")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileTransport{dir: dir}, nil
+}
+
+func (t *FileTransport) Send(ctx context.Context, msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.ReplaceAll(strings.Join(msg.To, "_"), string(os.PathSeparator), "_"))
+	return os.WriteFile(filepath.Join(t.dir, name), body, 0o600)
+}
+
+// LogTransport only write the recipient, subject and template to log, the body carry tokens and otp
+type LogTransport struct{}
+
+func NewLogTransport() *LogTransport {
+	return &LogTransport{}
+}
+
+func (t *LogTransport) Send(ctx context.Context, msg *Message) error {
+	logger.Ctx(ctx).Infow("send mail", "to", msg.To, "subject", msg.Subject, "template", msg.Template)
+	return nil
+}
@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	TransportSMTP = "smtp"
+	TransportFile = "file"
+	TransportLog  = "log"
+)
+
+// Config outbound mail, smtp for production and file / log for development and tests
+type Config struct {
+	Transport    string // required outside development, empty fallback to log
+	From         string
+	Dir          string // file transport
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	Development bool
+}
+
+// Message outbound email, html is optional
+type Message struct {
+	From     string
+	To       []string
+	Subject  string
+	Text     string
+	HTML     string
+	Template string // name of the rendered template, empty when built by hand
+}
+
+// Transport deliver the message
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+func NewTransport(cfg Config) (Transport, error) {
+	switch cfg.Transport {
+	case TransportSMTP:
+		return NewSMTPTransport(cfg), nil
+	case TransportFile:
+		return NewFileTransport(cfg.Dir)
+	case TransportLog:
+		return NewLogTransport(), nil
+	case "":
+		if !cfg.Development {
+			return nil, fmt.Errorf("mail transport is required outside development")
+		}
+		return NewLogTransport(), nil
+	default:
+		return nil, fmt.Errorf("mail transport not supported: %s", cfg.Transport)
+	}
+}
+
+// Bytes RFC 5322 message with text and html alternative
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", m.From)
+	header.Set("To", strings.Join(m.To, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("MIME-Version", "1.0")
+
+	writer := multipart.NewWriter(&buf)
+	header.Set("Content-Type", "multipart/alternative; boundary="+writer.Boundary())
+	var head bytes.Buffer
+	for _, key := range []string{"From", "To", "Subject", "Date", "MIME-Version", "Content-Type"} {
+		fmt.Fprintf(&head, "%s: %s\r\n", key, header.Get(key))
+	}
+	head.WriteString("\r\n")
+
+	parts := []struct{ contentType, body string }{{"text/plain; charset=utf-8", m.Text}}
+	if m.HTML != "" {
+		parts = append(parts, struct{ contentType, body string }{"text/html; charset=utf-8", m.HTML})
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
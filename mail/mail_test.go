@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	data := map[string]any{
+		"AppName":       "go_base",
+		"Name":          "Jane",
+		"TmpPassword":   "tmp<pwd>",
+		"URL":           "http://localhost/staffs/verify?token=abc",
+		"OTP":           "123456",
+		"ReferenceCode": "ABCDEF",
+		"ExpireAt":      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"Attempts":      3,
+	}
+	tests := []struct {
+		name     string
+		template string
+		subject  string
+		contains string
+	}{
+		{name: "invitation", template: TemplateInvitation, subject: "You are invited to go_base", contains: "tmp<pwd>"},
+		{name: "verification", template: TemplateVerification, subject: "Verify your go_base account", contains: "staffs/verify?token=abc"},
+		{name: "otp", template: TemplateOTP, subject: "Your go_base login code", contains: "123456"},
+		{name: "password reset", template: TemplatePasswordReset, subject: "Reset your go_base password", contains: "staffs/verify?token=abc"},
+		{name: "account locked", template: TemplateAccountLocked, subject: "Your go_base account is locked", contains: "after 3 failed login attempts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Render(tt.template, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("Render() subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if !strings.Contains(msg.Text, tt.contains) {
+				t.Errorf("Render() text missing %q: %s", tt.contains, msg.Text)
+			}
+			if strings.Contains(msg.HTML, "tmp<pwd>") {
+				t.Errorf("Render() html is not escaped: %s", msg.HTML)
+			}
+		})
+	}
+
+	if _, err := Render("unknown", data); err == nil {
+		t.Error("Render() unknown template should fail")
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg := &Message{From: "no-reply@go-base.local", To: []string{"a@b.com"}, Subject: "hello", Text: "text body", HTML: "<p>html body</p>"}
+	b, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: a@b.com\r\n", "Subject: hello\r\n", "multipart/alternative", "text body", "<p>html body</p>"} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("Bytes() missing %q", want)
+		}
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := t.TempDir()
+	transport, err := NewFileTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Send(context.Background(), &Message{To: []string{"a@b.com"}, Subject: "hello", Text: "body"}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Errorf("FileTransport wrote %d files, want 1", len(files))
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "log", cfg: Config{Transport: TransportLog}},
+		{name: "empty in development", cfg: Config{Development: true}},
+		{name: "empty outside development", cfg: Config{}, wantErr: true},
+		{name: "unknown", cfg: Config{Transport: "sendmail", Development: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTransport(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
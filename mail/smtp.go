@@ -0,0 +1,28 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+)
+
+type SMTPTransport struct {
+	addr string
+	auth smtp.Auth
+}
+
+func NewSMTPTransport(cfg Config) *SMTPTransport {
+	t := &SMTPTransport{addr: fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort)}
+	if cfg.SMTPUsername != "" {
+		t.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return t
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(t.addr, t.auth, msg.From, msg.To, body)
+}
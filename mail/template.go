@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+const (
+	TemplateInvitation    = "invitation"
+	TemplateVerification  = "verification"
+	TemplateOTP           = "otp"
+	TemplatePasswordReset = "password_reset"
+	TemplateAccountLocked = "account_locked"
+)
+
+/*
+every template file <name>.tmpl define three blocks
+
+	{{define "<name>.subject"}} {{define "<name>.text"}} {{define "<name>.html"}}
+
+subject and text are rendered with text/template, html with html/template
+*/
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplates = template.Must(template.New("").ParseFS(templateFS, "templates/*.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").ParseFS(templateFS, "templates/*.tmpl"))
+)
+
+// Render message of the template, data is passed to every block
+func Render(name string, data any) (*Message, error) {
+	subject, err := executeText(name, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := executeText(name, "text", data)
+	if err != nil {
+		return nil, err
+	}
+	html, err := executeHTML(name, "html", data)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Subject: subject, Text: text, HTML: html, Template: name}, nil
+}
+
+func executeText(name, block string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&buf, name+"."+block, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func executeHTML(name, block string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&buf, name+"."+block, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -7,7 +7,9 @@ import (
 	"go_base/configs"
 	"go_base/database"
 	"go_base/domain"
+	"go_base/hash"
 	myLogger "go_base/logger"
+	"go_base/mail"
 	"go_base/services"
 	"go_base/services/auth"
 	"go_base/storage"
@@ -73,11 +75,14 @@ func CreateApp(ctx context.Context) (*App, error) {
 }
 
 func CreateAppForTest(ctx context.Context) (*App, error) {
-	loadDotenv := flag.Bool("dotenv", false, "Load app config from .env file.")
+	// the suite and the benchmarks of a test binary each create an app, the flag is defined once
+	if flag.Lookup("dotenv") == nil {
+		flag.Bool("dotenv", false, "Load app config from .env file.")
+	}
 
 	flag.Parse()
 
-	if *loadDotenv {
+	if flag.Lookup("dotenv").Value.String() == "true" {
 		if err := godotenv.Load(); err != nil {
 			return nil, fmt.Errorf("load .env failed: %v", err)
 		}
@@ -133,6 +138,12 @@ func createApp(ctx context.Context, cfg *configs.Config) (*App, error) {
 
 	adminAuthCfg := auth.AuthConfig(cfg.AdminAuth)
 	userAuthCfg := auth.AuthConfig(cfg.UserAuth)
+	if err := adminAuthCfg.PasswordPolicy().Validate(); err != nil {
+		return nil, fmt.Errorf("invalid admin auth password policy: %v", err)
+	}
+	if err := userAuthCfg.PasswordPolicy().Validate(); err != nil {
+		return nil, fmt.Errorf("invalid user auth password policy: %v", err)
+	}
 
 	allStorage := &storage.AllStorage{
 		DB:    postgresql.Client,
@@ -145,20 +156,83 @@ func createApp(ctx context.Context, cfg *configs.Config) (*App, error) {
 		Base:      store,
 		Staff:     database.NewStaffStore(postgresql.Client, allStorage),
 		Auth:      database.NewAuthStore(postgresql.Client, allStorage),
+		Session:   database.NewSessionStore(postgresql.Client, allStorage),
+		TwoFactor: database.NewTwoFactorStore(postgresql.Client, allStorage),
+		Password:  database.NewPasswordHistoryStore(postgresql.Client, allStorage),
 		Role:      database.NewRoleStore(postgresql.Client, allStorage),
 		User:      database.NewUserStore(postgresql.Client, allStorage),
+		Service:   database.NewServiceAccountStore(postgresql.Client, allStorage),
+		Org:       database.NewOrganizationStore(postgresql.Client, allStorage),
 		Developer: database.NewBaseStore[domain.Developer, domain.DeveloperUpdate, domain.DeveloperCreate](postgresql.Client, &database.BaseStoreConfig{WriteChangelog: true, CacheExpire: 10 * time.Minute}, allStorage),
 		Project:   database.NewBaseStore[domain.Project, domain.ProjectUpdate, domain.ProjectCreate](postgresql.Client, &database.BaseStoreConfig{WriteChangelog: true, CacheExpire: 10 * time.Minute}, allStorage),
 		Asset:     database.NewBaseStore[domain.Asset, domain.AssetUpdate, domain.AssetCreate](postgresql.Client, &database.BaseStoreConfig{WriteChangelog: true, CacheExpire: 10 * time.Minute}, allStorage),
 	}
 
+	// jwt signing keys
+	adminKeys, err := auth.LoadKeySet(&adminAuthCfg, domain.AudienceStaff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin signing keys: %v", err)
+	}
+	userKeys, err := auth.LoadKeySet(&userAuthCfg, domain.AudienceUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user signing keys: %v", err)
+	}
+
+	// password hasher
+	hasher, err := hash.NewHasher(cfg.PasswordHasher.Algorithm, hash.Argon2Params{
+		Memory:      cfg.PasswordHasher.Argon2Memory,
+		Iterations:  cfg.PasswordHasher.Argon2Iterations,
+		Parallelism: cfg.PasswordHasher.Argon2Parallelism,
+	}, cfg.PasswordHasher.BcryptCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create password hasher: %v", err)
+	}
+	hash.SetDefaultHasher(hasher)
+
+	// mail
+	mailTransport, err := mail.NewTransport(mail.Config{
+		Transport:    cfg.Mail.Transport,
+		From:         cfg.Mail.From,
+		Dir:          cfg.Mail.Dir,
+		SMTPHost:     cfg.Mail.SMTPHost,
+		SMTPPort:     cfg.Mail.SMTPPort,
+		SMTPUsername: cfg.Mail.SMTPUsername,
+		SMTPPassword: cfg.Mail.SMTPPassword,
+		Development:  cfg.ENV == domain.EnvDevelopment,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail transport: %v", err)
+	}
+	// the links of the email are frontend pages, the api only accept the token by POST
+	mailBaseUrl := cfg.Mail.BaseUrl
+	if mailBaseUrl == "" {
+		if cfg.ENV != domain.EnvDevelopment {
+			return nil, fmt.Errorf("mail base url is required outside development")
+		}
+		mailBaseUrl = cfg.BaseUrl
+	}
+
+	// oidc providers of staff login
+	oidcProviders := make([]*auth.OIDCProvider, 0, len(cfg.OIDC))
+	for _, c := range cfg.OIDC {
+		oidcProviders = append(oidcProviders, auth.NewOIDCProvider(auth.OIDCConfig(c), nil))
+	}
+
 	// all services
 	allServices := &domain.AllServices{}
-	allServices.Role = services.NewRoleService(store, stores.Role, allServices, redis)
+	allServices.TokenKey = services.NewTokenKeyService(adminKeys, userKeys)
+	allServices.Mail = services.NewMailService(mailTransport, cfg.Mail.From, cfg.Mail.AppName, mailBaseUrl)
+	allServices.Role = services.NewRoleService(store, stores.Role, allServices, redis, cfg.CacheExpireRole, cfg.CacheCheckRole)
 	allServices.Staff = services.NewStaffService(store, stores.Staff, allServices, redis, &adminAuthCfg)
-	allServices.AuthAdmin = services.NewAuthAdminService(stores.Auth, allServices)
-	allServices.AuthUser = services.NewAuthUserService(stores.Auth, allServices)
+	allServices.AuthAdmin = services.NewAuthAdminService(stores.Auth, allServices, redis, &adminAuthCfg)
+	allServices.AuthUser = services.NewAuthUserService(stores.Auth, allServices, redis, &userAuthCfg)
+	allServices.Session = services.NewSessionService(store, stores.Session, allServices, redis)
+	allServices.TwoFactor = services.NewTwoFactorService(store, stores.TwoFactor, allServices, redis)
+	allServices.Password = services.NewPasswordService(store, stores.Password, allServices, redis)
 	allServices.User = services.NewUserService(store, stores.User, allServices, redis, &userAuthCfg)
+	allServices.ServiceAccount = services.NewServiceAccountService(store, stores.Service, allServices, redis)
+	allServices.OIDC = services.NewOIDCService(store, stores.Staff, allServices, redis, &adminAuthCfg, oidcProviders...)
+	allServices.Organization = services.NewOrganizationService(store, stores.Org, allServices, redis)
 	allServices.IDeveloper = services.NewBaseService(store, stores.Developer, allServices, redis)
 	allServices.IProject = services.NewBaseService(store, stores.Project, allServices, redis)
 	allServices.IAsset = services.NewBaseService(store, stores.Asset, allServices, redis)
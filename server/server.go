@@ -91,7 +91,8 @@ func run(ctx context.Context, app *App) error {
 			URL:  cfg.SwaggerLicense.URL,
 		}}).
 		AddSecurityAPIKey(domain.AuthHeaderKeyStaff, "staff", echoswagger.SecurityInHeader).
-		AddSecurityAPIKey(domain.AuthHeaderKeyUser, "user", echoswagger.SecurityInHeader)
+		AddSecurityAPIKey(domain.AuthHeaderKeyUser, "user", echoswagger.SecurityInHeader).
+		AddSecurityAPIKey(domain.APIKeyHeader, "service account", echoswagger.SecurityInHeader)
 	e := ewg.Echo()
 	var se echoswagger.ApiRoot
 	if app.Cfg.ENV != "production" {
@@ -141,72 +142,98 @@ func run(ctx context.Context, app *App) error {
 	// staff
 	groupStaff := ewg.Group("staff", apiV1+"/staffs")
 	v1.RegisterRoutesStaff(groupStaff, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		ENV:       app.Cfg.ENV,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
 	})
 	// staff me
 	groupStaffMe := ewg.Group("staff_me", apiV1+"/me")
 	v1.RegisterRoutesStaffMe(groupStaffMe, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
 	})
 
 	// role
 	groupRole := ewg.Group("role", apiV1+"/roles")
 	v1.RegisterRoutesRole(groupRole, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
 	})
 
 	// user
 	groupUser := ewg.Group("user", apiV1+"/users")
 	v1.RegisterRoutesUser(groupUser, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		ENV:       app.Cfg.ENV,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
 	})
 
 	// developer
 	groupDeveloper := ewg.Group("developer", apiV1+"/developers")
 	v1.RegisterRoutesDeveloper(groupDeveloper, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
 	})
 
 	// project
 	groupProject := ewg.Group("project", apiV1+"/projects")
 	v1.RegisterRoutesProject(groupProject, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
 	})
 
 	// asset
 	groupAsset := ewg.Group("asset", apiV1+"/assets")
 	v1.RegisterRoutesAsset(groupAsset, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
 	})
 
 	// asset user
 	groupAssetUser := ewg.Group("asset user", apiV1+"/assets/user")
 	v1.RegisterRoutesAssetUser(groupAssetUser, &domain.Config{
-		Services:        app.Services,
-		CacheFunc:       app.Redis.GetStringValue,
-		AdminAuthSecret: cfg.AdminAuth.JWTSecret,
-		UserAuthSecret:  cfg.UserAuth.JWTSecret,
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
+	})
+
+	// service account
+	groupServiceAccount := ewg.Group("service_account", apiV1+"/service-accounts")
+	v1.RegisterRoutesServiceAccount(groupServiceAccount, &domain.Config{
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
+	})
+
+	// organization
+	groupOrganization := ewg.Group("organization", apiV1+"/organizations")
+	v1.RegisterRoutesOrganization(groupOrganization, &domain.Config{
+		Services:  app.Services,
+		CacheFunc: app.Redis.GetStringValue,
+		AdminKeys: app.Services.TokenKey.Admin(),
+		UserKeys:  app.Services.TokenKey.User(),
+	})
+
+	// well known
+	groupWellKnown := ewg.Group("well_known", "/.well-known")
+	v1.RegisterRoutesWellKnown(groupWellKnown, &domain.Config{
+		Services: app.Services,
 	})
 
 	errCh := make(chan error)
@@ -9,6 +9,7 @@ import (
 	"go_base/xerror"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
 )
 
 type IBaseService[T domain.Asset, U domain.AssetUpdate, C domain.AssetCreate] struct {
@@ -23,19 +24,19 @@ func NewAssetService[T domain.Asset, U domain.AssetUpdate, C domain.AssetCreate]
 }
 
 func (s *IBaseService[T, U, C]) CreateScope(ctx echo.Context, c *C) error {
-
-	user := domain.UserFromContext(ctx)
 	cTmp := helper.Copy[domain.AssetCreate](c)
 
-	if user == nil {
-		if err := s.store.DB.First(&user, cTmp.UserID).Error; err != nil {
+	return s.store.WithTx(ctx, func(ctx echo.Context) error {
+		db := s.store.Conn(ctx)
+		// lock the user until commit, concurrent requests of the user would pass the check below
+		if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&domain.User{}, "id = ?", cTmp.UserID).Error; err != nil {
 			return xerror.ENotFound()
 		}
-	}
 
-	if err := s.store.DB.Where("user_id = ? AND project_id = ?", cTmp.UserID, cTmp.ProjectID).First(&domain.Asset{}).Error; err == nil {
-		return xerror.EConflict(errors.New("you have already had this asset"))
-	}
+		if err := db.Where("user_id = ? AND project_id = ?", cTmp.UserID, cTmp.ProjectID).First(&domain.Asset{}).Error; err == nil {
+			return xerror.EConflict(errors.New("you have already had this asset"))
+		}
 
-	return s.baseStore.CreateC(ctx, c)
+		return s.baseStore.CreateC(ctx, c)
+	})
 }
@@ -10,9 +10,11 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 )
 
 type AuthConfig struct {
+	ApplicationName           string // iss claim
 	JWTSecret                 string
 	AccessTokenDuration       time.Duration
 	RefreshTokenDuration      time.Duration
@@ -21,40 +23,195 @@ type AuthConfig struct {
 	ResendOTPMaxAttempts      int
 	VerifyOTPMaxAttempts      int
 	ReturnOTP                 bool
+	OTPDuration               time.Duration
+	OTPLockDuration           time.Duration
 	DemoUser                  struct {
 		Email string
 		Tel   string
 		Pin   string
 	}
 	LenTempPwd int
+	// password policy
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordDenylist      []string
+	PasswordHistory       int
+	PasswordMaxAge        time.Duration
+	// RS256 / EdDSA pem files, the first private key sign new tokens and the rest only verify by kid
+	SigningKeys []struct {
+		KID  string
+		File string
+	}
+	// lifetime of the access token issued by impersonation, default 15m
+	ImpersonationTokenDuration time.Duration
+
+	// progressive lockout, the email is locked for Duration when its failed logins reach Strikes,
+	// empty lock 5m at AccountLockoutMaxAttempts and 1h at twice of it
+	LockoutSteps []struct {
+		Strikes  int
+		Duration time.Duration
+	}
+	// failed logins of the email are forgotten after the window, default 24h
+	LockoutStrikeWindow time.Duration
+	// failed logins of one ip during IPLockoutWindow, 0 disable
+	IPLockoutMaxAttempts int
+	IPLockoutWindow      time.Duration
 }
 
-func issueToken(tokenType domain.TokenType, secret, userID string, duration time.Duration, now time.Time) (*domain.TokenExpires, error) {
+// PasswordPolicy policy of the audience
+func (cfg *AuthConfig) PasswordPolicy() domain.PasswordPolicy {
+	return domain.PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		Denylist:      cfg.PasswordDenylist,
+		History:       cfg.PasswordHistory,
+		MaxAge:        cfg.PasswordMaxAge,
+	}
+}
+
+// RefreshTokenStore keeps issued refresh tokens so they can be rotated and revoked as a family.
+type RefreshTokenStore interface {
+	FindRefreshToken(ctx echo.Context, token string) (*domain.TokenExpires, error)
+	RotateRefreshToken(ctx echo.Context, rt *domain.TokenExpires) (bool, error)
+	RevokeTokenFamily(ctx echo.Context, rt *domain.TokenExpires) error
+	WithTx(ctx echo.Context, fn func(ctx echo.Context) error) error
+}
+
+func issueToken(tokenType domain.TokenType, keys *domain.KeySet, userID, sessionID string, actor *domain.ActorClaim, duration time.Duration, now time.Time) (*domain.TokenExpires, error) {
+	jti := uuid.New()
 	claims := domain.AuthClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti.String(),
+			Issuer:    keys.Issuer(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 		},
 		TokenType: tokenType,
 		UserID:    userID,
+		SessionID: sessionID,
+		Actor:     actor,
+	}
+
+	if aud := keys.Audience(); aud != "" {
+		claims.Audience = jwt.ClaimStrings{aud}
 	}
 
-	token, err := domain.GenerateAccessToken(claims, secret)
+	token, err := keys.Sign(claims)
 	if err != nil {
 		return nil, xerror.E(err)
 	}
 
-	return &domain.TokenExpires{Token: token, ExpireAt: now.Add(duration)}, nil
+	return &domain.TokenExpires{BaseModel: domain.BaseModel{ID: jti}, Token: token, ExpireAt: now.Add(duration)}, nil
 }
 
-func IssueAccessRefreshToken(ctx echo.Context, userID uuid.UUID, cfg *AuthConfig,
+/*
+RefreshAccessRefreshToken exchange a refresh token for a new pair of token
+
+	every refresh token can be used only once, the used one is marked as rotated in the transaction issuing the new pair
+	if a rotated token is presented again the whole family is revoked and the access token is removed from whitelist
+	verifyFunc check the owner and the session of the token are still allowed to login, before the token is rotated
+*/
+func RefreshAccessRefreshToken(ctx echo.Context, refreshToken string, cfg *AuthConfig, keys *domain.KeySet, store RefreshTokenStore,
+	verifyFunc func(ctx echo.Context, rt *domain.TokenExpires) error,
 	findFunc func(ctx echo.Context, userID string) (*domain.Auth, error),
 	updateFunc func(ctx echo.Context, userID string, update domain.Auth) error,
 	createFunc func(ctx echo.Context, auth *domain.Auth) error,
 	cacheFunc func(ctx context.Context, key string, value any, exp time.Duration) error,
+	clearCacheFunc func(ctx context.Context, key string) error,
 ) (*domain.AuthResult, error) {
+	var claims domain.AuthClaims
+	if _, err := keys.Parse(refreshToken, &claims); err != nil {
+		return nil, errInvalidRefreshToken()
+	}
+	if claims.TokenType != domain.TokenTypeRefresh {
+		return nil, errInvalidRefreshToken()
+	}
+
+	rt, err := store.FindRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil, errInvalidRefreshToken()
+		}
+		return nil, xerror.E(err)
+	}
+	// token issued before sessions has no family and can't be refreshed
+	if rt.Revoked() || rt.Expired() || rt.UserID.String() != claims.UserID || rt.FamilyID == uuid.Nil {
+		return nil, errInvalidRefreshToken()
+	}
+
+	if err := verifyFunc(ctx, rt); err != nil {
+		return nil, xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrInvalidRefreshToken).
+			SetStatusCode(xerror.ErrCodeUnauthorized).SetDebugInfo("verify", err)
+	}
+
+	// a failed issue roll back the rotation, the client can retry with the same token
+	var result *domain.AuthResult
+	reused := false
+	if err := store.WithTx(ctx, func(ctx echo.Context) error {
+		rotated, err := store.RotateRefreshToken(ctx, rt)
+		if err != nil {
+			return xerror.E(err)
+		}
+		if !rotated {
+			reused = true
+			return nil
+		}
+		result, err = IssueAccessRefreshToken(ctx, rt.UserID, rt.FamilyID, cfg, keys, findFunc, updateFunc, createFunc, cacheFunc)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if reused {
+		// token reuse, someone else already exchanged this token
+		if err := store.RevokeTokenFamily(ctx, rt); err != nil {
+			return nil, xerror.E(err)
+		}
+		if err := clearCacheFunc(ctx.Request().Context(), getWhitelistKey(claims.UserID, rt.FamilyID.String())); err != nil {
+			return nil, xerror.E(err)
+		}
+		return nil, xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrRefreshTokenReused).
+			SetStatusCode(xerror.ErrCodeUnauthorized)
+	}
+	return result, nil
+}
+
+// IssueImpersonationToken access token of the user session with act claim of the staff,
+// there is no refresh token so the impersonation end when the token expire
+func IssueImpersonationToken(ctx echo.Context, userID, sessionID, actorID uuid.UUID, cfg *AuthConfig, keys *domain.KeySet,
+	cacheFunc func(ctx context.Context, key string, value any, exp time.Duration) error,
+) (*domain.ImpersonationToken, error) {
 	id := userID.String()
+	sid := sessionID.String()
+	duration := cfg.ImpersonationTokenDuration
+	if duration <= 0 {
+		duration = domain.DefaultImpersonationDuration
+	}
+	at, err := issueToken(domain.TokenTypeAccess, keys, id, sid, &domain.ActorClaim{Subject: actorID.String()}, duration, time.Now())
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	if err := cacheFunc(ctx.Request().Context(), getWhitelistKey(id, sid), at.Token, at.ExpireAt.Sub(time.Now())); err != nil {
+		return nil, xerror.E(err)
+	}
+	return &domain.ImpersonationToken{AccessToken: at.Token, ExpiresAt: at.ExpireAt}, nil
+}
+
+// IssueAccessRefreshToken issue a new pair of token for the session, the session id is the refresh token family
+func IssueAccessRefreshToken(ctx echo.Context, userID, sessionID uuid.UUID, cfg *AuthConfig, keys *domain.KeySet,
+	findFunc func(ctx echo.Context, userID string) (*domain.Auth, error),
+	updateFunc func(ctx echo.Context, userID string, update domain.Auth) error,
+	createFunc func(ctx echo.Context, auth *domain.Auth) error,
+	cacheFunc func(ctx context.Context, key string, value any, exp time.Duration) error,
+) (*domain.AuthResult, error) {
+	id := userID.String()
+	sid := sessionID.String()
 	exists := true
 	auth, err := findFunc(ctx, id)
 	if err != nil {
@@ -66,29 +223,33 @@ func IssueAccessRefreshToken(ctx echo.Context, userID uuid.UUID, cfg *AuthConfig
 	}
 
 	now := time.Now()
-	at, err := issueToken(domain.TokenTypeAccess, cfg.JWTSecret, id, cfg.AccessTokenDuration, now)
+	at, err := issueToken(domain.TokenTypeAccess, keys, id, sid, nil, cfg.AccessTokenDuration, now)
 	if err != nil {
 		return nil, xerror.E(err)
 	}
 
-	if err := cacheFunc(ctx.Request().Context(), getWhitelistKey(id), at.Token, at.ExpireAt.Sub(time.Now())); err != nil {
+	if err := cacheFunc(ctx.Request().Context(), getWhitelistKey(id, sid), at.Token, at.ExpireAt.Sub(time.Now())); err != nil {
 		return nil, xerror.E(err)
 	}
 
-	rt, err := issueToken(domain.TokenTypeRefresh, cfg.JWTSecret, id, cfg.RefreshTokenDuration, now)
+	rt, err := issueToken(domain.TokenTypeRefresh, keys, id, sid, nil, cfg.RefreshTokenDuration, now)
 	if err != nil {
 		return nil, xerror.E(err)
 	}
+	rt.UserID = userID
+	rt.FamilyID = sessionID
 
 	if exists {
 		auth.RefreshToken = rt
+		auth.TokenExpiresID = lo.ToPtr(rt.ID.String())
 		if err := updateFunc(ctx, id, *auth); err != nil {
 			return nil, xerror.E(err)
 		}
 	} else {
 		auth := &domain.Auth{
-			UserID:       userID,
-			RefreshToken: rt,
+			UserID:         userID,
+			RefreshToken:   rt,
+			TokenExpiresID: lo.ToPtr(rt.ID.String()),
 			BaseModel: domain.BaseModel{
 				CreatedAt: now,
 				UpdatedAt: now,
@@ -105,6 +266,11 @@ func IssueAccessRefreshToken(ctx echo.Context, userID uuid.UUID, cfg *AuthConfig
 	return result, nil
 }
 
-func getWhitelistKey(userID string) string {
-	return fmt.Sprintf(domain.WhitelistAccessTokenCacheKey, userID)
+func errInvalidRefreshToken() *xerror.Xerror {
+	return xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrInvalidRefreshToken).
+		SetStatusCode(xerror.ErrCodeUnauthorized)
+}
+
+func getWhitelistKey(userID, sessionID string) string {
+	return fmt.Sprintf(domain.WhitelistAccessTokenCacheKey, userID, sessionID)
 }
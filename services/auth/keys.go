@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"fmt"
+	"go_base/domain"
+	"os"
+)
+
+// LoadKeySet signing keys of the audience from the pem files, jwtsecret keep HS256 tokens valid during the rotation,
+// tokens are issued by applicationname for the audience
+func LoadKeySet(cfg *AuthConfig, audience string) (*domain.KeySet, error) {
+	keys := make([]*domain.SigningKey, 0, len(cfg.SigningKeys))
+	for _, k := range cfg.SigningKeys {
+		data, err := os.ReadFile(k.File)
+		if err != nil {
+			return nil, fmt.Errorf("read signing key %s: %v", k.KID, err)
+		}
+		key, err := domain.ParseSigningKeyPEM(k.KID, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	ks, err := domain.NewKeySet(cfg.JWTSecret, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return ks.WithAudience(cfg.ApplicationName, audience), nil
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"fmt"
+	"go_base/logger"
+	"go_base/storage"
+	"go_base/xerror"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultLockoutStrikeWindow = 24 * time.Hour
+	defaultIPLockoutWindow     = 15 * time.Minute
+)
+
+// LockoutStep lock the email for Duration when its failed logins reach Strikes
+type LockoutStep struct {
+	Strikes  int
+	Duration time.Duration
+}
+
+// LockoutKeys redis keys of the lockout, staff and user use different prefix
+type LockoutKeys struct {
+	Strikes string // failed logins of the email
+	Locked  string // the email is locked until the key expire
+	IP      string // failed logins of the ip
+}
+
+// LockoutNotifyFunc tell the owner of the email the account is locked until
+type LockoutNotifyFunc func(ctx echo.Context, email string, strikes int, until time.Time) error
+
+/*
+Lockout progressive lockout of failed logins shared by staff and user
+
+	every failed login increase the strikes of the email and of the ip,
+	when the strikes reach a step the email is locked for the step duration and the owner is notified,
+	after the last step every failed login lock again with the last duration,
+	the lock expire by itself, a successful login or an unlock by admin clear the strikes
+*/
+type Lockout struct {
+	cache        *storage.Cache
+	keys         LockoutKeys
+	steps        []LockoutStep
+	strikeWindow time.Duration
+	ipMax        int
+	ipWindow     time.Duration
+	notify       LockoutNotifyFunc
+}
+
+func NewLockout(cache *storage.Cache, keys LockoutKeys, cfg *AuthConfig, notify LockoutNotifyFunc) *Lockout {
+	steps := make([]LockoutStep, 0, len(cfg.LockoutSteps))
+	for _, step := range cfg.LockoutSteps {
+		steps = append(steps, LockoutStep{Strikes: step.Strikes, Duration: step.Duration})
+	}
+	if len(steps) == 0 {
+		steps = DefaultLockoutSteps(cfg.AccountLockoutMaxAttempts)
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].Strikes < steps[j].Strikes })
+	l := &Lockout{
+		cache:        cache,
+		keys:         keys,
+		steps:        steps,
+		strikeWindow: cfg.LockoutStrikeWindow,
+		ipMax:        cfg.IPLockoutMaxAttempts,
+		ipWindow:     cfg.IPLockoutWindow,
+		notify:       notify,
+	}
+	if l.strikeWindow <= 0 {
+		l.strikeWindow = defaultLockoutStrikeWindow
+	}
+	if l.ipWindow <= 0 {
+		l.ipWindow = defaultIPLockoutWindow
+	}
+	return l
+}
+
+// DefaultLockoutSteps 5m at max attempts and 1h at twice of it
+func DefaultLockoutSteps(maxAttempts int) []LockoutStep {
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	return []LockoutStep{
+		{Strikes: maxAttempts, Duration: 5 * time.Minute},
+		{Strikes: maxAttempts * 2, Duration: time.Hour},
+	}
+}
+
+// Step duration of the lock when the email reach strikes, 0 does not lock
+func (l *Lockout) Step(strikes int) time.Duration {
+	last := l.steps[len(l.steps)-1]
+	if strikes > last.Strikes {
+		return last.Duration
+	}
+	for _, step := range l.steps {
+		if step.Strikes == strikes {
+			return step.Duration
+		}
+	}
+	return 0
+}
+
+// Check reject the login while the ip is throttled or the email is locked, the error carry retry_after seconds
+func (l *Lockout) Check(ctx echo.Context, email string) error {
+	c := ctx.Request().Context()
+	if ip := ctx.RealIP(); l.ipMax > 0 && ip != "" {
+		attempts, err := l.cache.GetStrikes(c, fmt.Sprintf(l.keys.IP, ip))
+		if err != nil {
+			return xerror.E(err)
+		}
+		if attempts >= l.ipMax {
+			return l.errLocked(ctx, fmt.Sprintf(l.keys.IP, ip), xerror.ETooManyRequests(), xerror.ErrLoginIPThrottled)
+		}
+	}
+	return l.errLocked(ctx, fmt.Sprintf(l.keys.Locked, email), xerror.EForbidden(), xerror.ErrCodeTooManyLoginAttempts)
+}
+
+// Fail count the failed login, lock the email when the strikes reach a step and return invalid_input with the counter
+func (l *Lockout) Fail(ctx echo.Context, email string) error {
+	c := ctx.Request().Context()
+	if ip := ctx.RealIP(); l.ipMax > 0 && ip != "" {
+		if _, err := l.cache.IncreaseStrikeWindow(c, fmt.Sprintf(l.keys.IP, ip), l.ipWindow); err != nil {
+			return xerror.E(err)
+		}
+	}
+	strikes, err := l.cache.IncreaseStrikeWindow(c, fmt.Sprintf(l.keys.Strikes, email), l.strikeWindow)
+	if err != nil {
+		return xerror.E(err)
+	}
+	if duration := l.Step(strikes); duration > 0 {
+		until := time.Now().Add(duration)
+		if err := l.cache.SetCache(c, fmt.Sprintf(l.keys.Locked, email), until.Unix(), duration); err != nil {
+			return xerror.E(err)
+		}
+		if l.notify != nil {
+			if err := l.notify(ctx, email, strikes, until); err != nil {
+				logger.Ctx(c).Warnw("notify lockout failed", "error", err)
+			}
+		}
+	}
+	return xerror.EInvalidInput(nil).SetExtraInfo("counter", strikes)
+}
+
+// Reset clear the strikes and the lock of the email, the ip counter is kept
+func (l *Lockout) Reset(ctx echo.Context, email string) error {
+	c := ctx.Request().Context()
+	if err := l.cache.DeleteStrikes(c, fmt.Sprintf(l.keys.Strikes, email)); err != nil {
+		return err
+	}
+	return l.cache.ClearCache(c, fmt.Sprintf(l.keys.Locked, email))
+}
+
+// errLocked xerr with reason and retry_after while the key is alive
+func (l *Lockout) errLocked(ctx echo.Context, key string, xerr *xerror.Xerror, reason string) error {
+	ttl, err := l.cache.TTL(ctx.Request().Context(), key)
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil
+		}
+		return xerror.E(err)
+	}
+	retryAfter := int(math.Ceil(ttl.Seconds()))
+	if retryAfter <= 0 {
+		retryAfter = 1
+	}
+	return xerr.
+		SetExtraInfo("reason", reason).
+		SetExtraInfo(xerror.RetryAfterKey, retryAfter)
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout_Step(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AuthConfig
+		strikes int
+		want    time.Duration
+	}{
+		{name: "below first step", cfg: AuthConfig{AccountLockoutMaxAttempts: 3}, strikes: 2},
+		{name: "default first step", cfg: AuthConfig{AccountLockoutMaxAttempts: 3}, strikes: 3, want: 5 * time.Minute},
+		{name: "between steps", cfg: AuthConfig{AccountLockoutMaxAttempts: 3}, strikes: 4},
+		{name: "default second step", cfg: AuthConfig{AccountLockoutMaxAttempts: 3}, strikes: 6, want: time.Hour},
+		{name: "after last step", cfg: AuthConfig{AccountLockoutMaxAttempts: 3}, strikes: 7, want: time.Hour},
+		{name: "default without max attempts", strikes: 3, want: 5 * time.Minute},
+		{name: "configured steps are sorted", cfg: AuthConfig{LockoutSteps: []struct {
+			Strikes  int
+			Duration time.Duration
+		}{{Strikes: 10, Duration: 24 * time.Hour}, {Strikes: 5, Duration: time.Minute}}}, strikes: 5, want: time.Minute},
+		{name: "configured last step", cfg: AuthConfig{LockoutSteps: []struct {
+			Strikes  int
+			Duration time.Duration
+		}{{Strikes: 10, Duration: 24 * time.Hour}, {Strikes: 5, Duration: time.Minute}}}, strikes: 12, want: 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLockout(nil, LockoutKeys{}, &tt.cfg, nil)
+			if got := l.Step(tt.strikes); got != tt.want {
+				t.Errorf("Step(%d) = %v, want %v", tt.strikes, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_base/domain"
+	"go_base/hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const oidcHTTPTimeout = 10 * time.Second
+
+var (
+	ErrOIDCDiscovery = errors.New("oidc discovery failed")
+	ErrOIDCExchange  = errors.New("oidc code exchange failed")
+	ErrOIDCIDToken   = errors.New("invalid oidc id token")
+)
+
+// OIDCConfig provider of authorization code + PKCE flow, the endpoints are read from the discovery url
+type OIDCConfig struct {
+	Name           string // path of /staffs/oidc/:provider
+	DiscoveryURL   string // e.g. https://accounts.google.com/.well-known/openid-configuration
+	ClientID       string
+	ClientSecret   string
+	RedirectURL    string
+	Scopes         []string // openid is always requested, default email profile
+	AllowedDomains []string // email domains which can login, empty allow every domain
+	AutoProvision  bool     // create staff of unknown email with DefaultRoleID
+	DefaultRoleID  string
+}
+
+// AllowEmail email domain is in AllowedDomains
+func (cfg *OIDCConfig) AllowEmail(email string) bool {
+	if len(cfg.AllowedDomains) == 0 {
+		return true
+	}
+	_, domainName, ok := strings.Cut(email, "@")
+	if !ok {
+		return false
+	}
+	for _, allowed := range cfg.AllowedDomains {
+		if strings.EqualFold(domainName, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcIDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+}
+
+// OIDCProvider client of one provider, discovery and jwks are fetched on first use and cached
+type OIDCProvider struct {
+	Config OIDCConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *oidcMetadata
+	keys     *domain.KeySet
+}
+
+func NewOIDCProvider(cfg OIDCConfig, client *http.Client) *OIDCProvider {
+	if client == nil {
+		client = &http.Client{Timeout: oidcHTTPTimeout}
+	}
+	return &OIDCProvider{Config: cfg, client: client}
+}
+
+// NewPKCE code verifier and its S256 challenge of RFC 7636, 32 bytes give the minimum 43 chars verifier
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = hash.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL authorization url of the provider with state, nonce and S256 code challenge
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := p.Config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.Config.ClientID},
+		"redirect_uri":          {p.Config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {PKCEChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return metadata.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// Exchange the code for tokens and verify the id token signature, iss, aud, exp and nonce
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*domain.OIDCIdentity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.Config.RedirectURL},
+		"client_id":     {p.Config.ClientID},
+		"client_secret": {p.Config.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: id_token is missing", ErrOIDCExchange)
+	}
+	return p.verifyIDToken(ctx, metadata, token.IDToken, nonce)
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, metadata *oidcMetadata, idToken, nonce string) (*domain.OIDCIdentity, error) {
+	keys, err := p.jwks(ctx, metadata, idToken)
+	if err != nil {
+		return nil, err
+	}
+	var claims oidcIDTokenClaims
+	if _, err := keys.Parse(idToken, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCIDToken, err)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCIDToken)
+	}
+	return &domain.OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+	}, nil
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Config.DiscoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata oidcMetadata
+	if err := p.do(req, &metadata); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCDiscovery, err)
+	}
+	if metadata.Issuer == "" || metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete metadata of %s", ErrOIDCDiscovery, p.Config.DiscoveryURL)
+	}
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// jwks keys of the provider, refetched when the kid of the token is unknown (key rotation)
+func (p *OIDCProvider) jwks(ctx context.Context, metadata *oidcMetadata, idToken string) (*domain.KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token, _, err := jwt.NewParser().ParseUnverified(idToken, &jwt.RegisteredClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCIDToken, err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	// id token comes from the token endpoint only, an unknown kid means the provider rotated its keys
+	if p.keys != nil && p.keys.HasKey(kid) {
+		return p.keys, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jwks domain.JWKS
+	if err := p.do(req, &jwks); err != nil {
+		return nil, fmt.Errorf("%w: jwks: %v", ErrOIDCDiscovery, err)
+	}
+	keys := make([]*domain.SigningKey, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// keys of other types (e.g. EC) are skipped, tokens signed with them are rejected
+		if key, err := domain.ParseJWK(jwk); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	p.keys = domain.NewVerifyKeySet(keys...).WithAudience(metadata.Issuer, p.Config.ClientID)
+	return p.keys, nil
+}
+
+func (p *OIDCProvider) do(req *http.Request, v any) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %d %s", req.Method, req.URL.Redacted(), res.StatusCode, body)
+	}
+	return json.Unmarshal(body, v)
+}
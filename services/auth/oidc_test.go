@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"go_base/domain"
+	"go_base/services/auth/oidctest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestOIDCProvider(idp *oidctest.Provider) *OIDCProvider {
+	return NewOIDCProvider(OIDCConfig{
+		Name:         "test",
+		DiscoveryURL: idp.DiscoveryURL(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "https://admin.example.com/oidc/callback",
+	}, nil)
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	claims := oidctest.Claims{Subject: "1", Email: "staff@example.com", EmailVerified: true, Name: "Staff"}
+
+	tests := []struct {
+		name     string
+		override func(claims jwt.MapClaims)
+		verifier func(verifier string) string
+		nonce    func(nonce string) string
+		wantErr  error
+	}{
+		{name: "success"},
+		{name: "wrong code verifier", verifier: func(string) string { return "wrong" }, wantErr: ErrOIDCExchange},
+		{name: "nonce mismatch", nonce: func(string) string { return "other" }, wantErr: ErrOIDCIDToken},
+		{name: "wrong audience", override: func(c jwt.MapClaims) { c["aud"] = "other-client" }, wantErr: ErrOIDCIDToken},
+		{name: "wrong issuer", override: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, wantErr: ErrOIDCIDToken},
+		{name: "expired", override: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: ErrOIDCIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := oidctest.NewProvider("client", "secret")
+			defer idp.Close()
+			idp.Override = tt.override
+			p := newTestOIDCProvider(idp)
+
+			verifier, _, err := NewPKCE()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, state, err := idp.Consent(authURL, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != "state" {
+				t.Errorf("state = %s", state)
+			}
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+			nonce := "nonce"
+			if tt.nonce != nil {
+				nonce = tt.nonce(nonce)
+			}
+			identity, err := p.Exchange(context.Background(), code, verifier, nonce)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *identity != (domain.OIDCIdentity{Subject: claims.Subject, Email: claims.Email, EmailVerified: claims.EmailVerified, Name: claims.Name}) {
+				t.Errorf("identity = %+v", identity)
+			}
+		})
+	}
+}
+
+func TestOIDCProvider_AuthCodeURL(t *testing.T) {
+	idp := oidctest.NewProvider("client", "secret")
+	defer idp.Close()
+	p := newTestOIDCProvider(idp)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"scope":                 "openid email profile",
+		"code_challenge":        PKCEChallenge("verifier"),
+		"code_challenge_method": "S256",
+		"nonce":                 "nonce",
+		"state":                 "state",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %s, want %s", k, q.Get(k), v)
+		}
+	}
+}
+
+func TestOIDCProvider_KeyRotation(t *testing.T) {
+	idp := oidctest.NewProvider("client", "secret")
+	defer idp.Close()
+	p := newTestOIDCProvider(idp)
+
+	login := func() error {
+		verifier, _, _ := NewPKCE()
+		authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+		if err != nil {
+			return err
+		}
+		code, _, err := idp.Consent(authURL, oidctest.Claims{Subject: "1", Email: "staff@example.com", EmailVerified: true})
+		if err != nil {
+			return err
+		}
+		_, err = p.Exchange(context.Background(), code, verifier, "nonce")
+		return err
+	}
+	if err := login(); err != nil {
+		t.Fatal(err)
+	}
+	// the cached jwks does not have the new kid and is fetched again
+	idp.Rotate(true)
+	if err := login(); err != nil {
+		t.Fatalf("login after rotation: %v", err)
+	}
+}
+
+func TestOIDCConfig_AllowEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		domains []string
+		email   string
+		want    bool
+	}{
+		{name: "any domain", email: "a@gmail.com", want: true},
+		{name: "allowed", domains: []string{"example.com"}, email: "a@Example.com", want: true},
+		{name: "not allowed", domains: []string{"example.com"}, email: "a@gmail.com"},
+		{name: "subdomain", domains: []string{"example.com"}, email: "a@evil.example.com"},
+		{name: "no domain", domains: []string{"example.com"}, email: "example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := OIDCConfig{AllowedDomains: tt.domains}
+			if got := cfg.AllowEmail(tt.email); got != tt.want {
+				t.Errorf("AllowEmail(%s) = %v, want %v", tt.email, got, tt.want)
+			}
+		})
+	}
+}
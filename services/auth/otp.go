@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"go_base/domain"
+	"go_base/hash"
+	"go_base/storage"
+	"go_base/xerror"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	otpLength              = 6
+	defaultOTPDuration     = 5 * time.Minute
+	defaultOTPLockDuration = 15 * time.Minute
+)
+
+// OTPKeys redis keys of the email otp flow, staff and user use different prefix
+type OTPKeys struct {
+	Login        func(email string) string // pending reference code of the email
+	Resend       func(email string) string // resend counter
+	ResendLocked func(email string) string
+	Verify       func(ref string) string   // otp of the reference code
+	VerifyLocked func(email string) string // failed verify counter
+}
+
+var (
+	AdminOTPKeys = OTPKeys{
+		Login:        getAdminLoginKey,
+		Resend:       getAdminResendOTPKey,
+		ResendLocked: getAdminResendOTPLockedKey,
+		Verify:       getAdminVerifyOTPKey,
+		VerifyLocked: getAdminVerifyOTPLockedKey,
+	}
+	UserOTPKeys = OTPKeys{
+		Login:        getUserLoginKey,
+		Resend:       getUserResendOTPKey,
+		ResendLocked: getUserResendOTPLockedKey,
+		Verify:       getUserVerifyOTPKey,
+		VerifyLocked: getUserVerifyOTPLockedKey,
+	}
+)
+
+// OTPSendFunc deliver the otp to the email
+type OTPSendFunc func(ctx echo.Context, email string, code *domain.ReferenceCode, otp string) error
+
+// otpSession is kept under the reference code until it is verified or expired
+type otpSession struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	OTP    string `json:"otp"`
+}
+
+/*
+OTP two step login
+
+	Issue    credentials are valid, send otp and return reference code
+	Verify   check otp of the reference code, return user id to issue tokens
+	Resend   send a new otp for the pending login of the email
+
+	failed verify and resend are limited by VerifyOTPMaxAttempts and ResendOTPMaxAttempts, locked for OTPLockDuration
+*/
+type OTP struct {
+	keys  OTPKeys
+	cfg   *AuthConfig
+	cache *storage.Cache
+	send  OTPSendFunc
+}
+
+func NewOTP(keys OTPKeys, cfg *AuthConfig, cache *storage.Cache, send OTPSendFunc) *OTP {
+	return &OTP{keys: keys, cfg: cfg, cache: cache, send: send}
+}
+
+// Issue generate otp for the user and send it to the email, the previous pending otp is discarded
+func (o *OTP) Issue(ctx echo.Context, userID, email string) (*domain.ReferenceCode, error) {
+	c := ctx.Request().Context()
+	if ref, err := o.cache.GetStringValue(c, o.keys.Login(email)); err == nil {
+		if err := o.cache.ClearCache(c, o.keys.Verify(ref)); err != nil {
+			return nil, xerror.E(err)
+		}
+	}
+
+	otp := hash.GenerateRandomString(otpLength, hash.CharsetNumeric)
+	ref := uuid.NewString()
+	duration := o.duration()
+	data, err := json.Marshal(otpSession{UserID: userID, Email: email, OTP: otp})
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	if err := o.cache.SetCache(c, o.keys.Verify(ref), data, duration); err != nil {
+		return nil, xerror.E(err)
+	}
+	if err := o.cache.SetCache(c, o.keys.Login(email), ref, duration); err != nil {
+		return nil, xerror.E(err)
+	}
+
+	code := &domain.ReferenceCode{ReferenceCode: ref, ExpireAt: time.Now().Add(duration)}
+	if err := o.send(ctx, email, code, otp); err != nil {
+		return nil, xerror.E(err)
+	}
+	if o.cfg.ReturnOTP {
+		code.OTP = otp
+	}
+	return code, nil
+}
+
+// Verify check the otp of the reference code, return the user id of the login
+func (o *OTP) Verify(ctx echo.Context, req domain.VerifyEmailOTP) (string, error) {
+	c := ctx.Request().Context()
+	email := req.Email.String()
+	attempts, err := o.cache.GetStrikes(c, o.keys.VerifyLocked(email))
+	if err != nil {
+		return "", xerror.E(err)
+	}
+	if o.cfg.VerifyOTPMaxAttempts > 0 && attempts >= o.cfg.VerifyOTPMaxAttempts {
+		return "", errOTPVerifyReachLimit()
+	}
+
+	session, err := o.get(ctx, req.ReferenceCode)
+	if err != nil {
+		return "", err
+	}
+	if session.Email != email {
+		return "", errInvalidOTP()
+	}
+	if subtle.ConstantTimeCompare([]byte(session.OTP), []byte(req.OTP)) != 1 {
+		attempts, err := o.cache.IncreaseStrike(c, o.keys.VerifyLocked(email))
+		if err != nil {
+			return "", xerror.E(err)
+		}
+		if err := o.cache.SetExpire(c, o.keys.VerifyLocked(email), o.lockDuration()); err != nil {
+			return "", xerror.E(err)
+		}
+		if o.cfg.VerifyOTPMaxAttempts > 0 && attempts >= o.cfg.VerifyOTPMaxAttempts {
+			// too many wrong otp, the pending login must start again
+			o.clear(ctx, req.ReferenceCode, email)
+			return "", errOTPVerifyReachLimit()
+		}
+		return "", errInvalidOTP().SetExtraInfo("counter", attempts)
+	}
+
+	o.clear(ctx, req.ReferenceCode, email)
+	for _, key := range []string{o.keys.VerifyLocked(email), o.keys.Resend(email), o.keys.ResendLocked(email)} {
+		if err := o.cache.ClearCache(c, key); err != nil {
+			return "", xerror.E(err)
+		}
+	}
+	return session.UserID, nil
+}
+
+// Resend send a new otp for the pending login of the email
+func (o *OTP) Resend(ctx echo.Context, email string) (*domain.ReferenceCode, error) {
+	c := ctx.Request().Context()
+	locked, err := o.cache.GetBooleanValue(c, o.keys.ResendLocked(email))
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	if *locked {
+		return nil, errOTPResendReachLimit()
+	}
+
+	ref, err := o.cache.GetStringValue(c, o.keys.Login(email))
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil, errInvalidOTP()
+		}
+		return nil, xerror.E(err)
+	}
+	session, err := o.get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := o.cache.IncreaseStrike(c, o.keys.Resend(email))
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	if err := o.cache.SetExpire(c, o.keys.Resend(email), o.lockDuration()); err != nil {
+		return nil, xerror.E(err)
+	}
+	if o.cfg.ResendOTPMaxAttempts > 0 && count > o.cfg.ResendOTPMaxAttempts {
+		if err := o.cache.SetCache(c, o.keys.ResendLocked(email), true, o.lockDuration()); err != nil {
+			return nil, xerror.E(err)
+		}
+		return nil, errOTPResendReachLimit()
+	}
+
+	return o.Issue(ctx, session.UserID, email)
+}
+
+func (o *OTP) get(ctx echo.Context, ref string) (*otpSession, error) {
+	raw, err := o.cache.GetCache(ctx.Request().Context(), o.keys.Verify(ref))
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil, errInvalidOTP()
+		}
+		return nil, xerror.E(err)
+	}
+	var session otpSession
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return nil, xerror.E(err)
+	}
+	return &session, nil
+}
+
+func (o *OTP) clear(ctx echo.Context, ref, email string) {
+	_ = o.cache.ClearCache(ctx.Request().Context(), o.keys.Verify(ref))
+	_ = o.cache.ClearCache(ctx.Request().Context(), o.keys.Login(email))
+}
+
+func (o *OTP) duration() time.Duration {
+	if o.cfg.OTPDuration > 0 {
+		return o.cfg.OTPDuration
+	}
+	return defaultOTPDuration
+}
+
+func (o *OTP) lockDuration() time.Duration {
+	if o.cfg.OTPLockDuration > 0 {
+		return o.cfg.OTPLockDuration
+	}
+	return defaultOTPLockDuration
+}
+
+func errInvalidOTP() *xerror.Xerror {
+	return xerror.EInvalidInput(errors.New("invalid otp")).SetErrorCode(xerror.ErrInvalidOTP)
+}
+
+func errOTPVerifyReachLimit() *xerror.Xerror {
+	return xerror.EForbidden().SetErrorCode(xerror.ErrOTPVerifyReachLimit)
+}
+
+func errOTPResendReachLimit() *xerror.Xerror {
+	return xerror.EForbidden().SetErrorCode(xerror.ErrOTPResendReachLimit)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"fmt"
+	"go_base/domain"
+	"go_base/hash"
+	"go_base/storage"
+	"go_base/xerror"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IssuePasswordResetToken signed token for the reset link, keyFormat is the redis key of the random part (staff or user)
+func IssuePasswordResetToken(ctx echo.Context, cfg *AuthConfig, cache *storage.Cache, keyFormat string, userID string) (string, error) {
+	token := hash.GenerateToken()
+	signed, err := domain.GenerateVerifyToken(token, cfg.JWTSecret, cfg.VerifyTokenDuration)
+	if err != nil {
+		return "", xerror.E(err)
+	}
+	if err := cache.SetCache(ctx.Request().Context(), fmt.Sprintf(keyFormat, token), userID, cfg.VerifyTokenDuration); err != nil {
+		return "", xerror.E(err)
+	}
+	return signed, nil
+}
+
+// ConsumePasswordResetToken verify the token and remove it, return user id of the token
+func ConsumePasswordResetToken(ctx echo.Context, cfg *AuthConfig, cache *storage.Cache, keyFormat string, signed string) (string, error) {
+	claims, err := domain.ParseVerifyToken(signed, cfg.JWTSecret)
+	if err != nil {
+		return "", errInvalidPasswordResetToken()
+	}
+	userID, err := cache.PopStringValue(ctx.Request().Context(), fmt.Sprintf(keyFormat, claims.Token))
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return "", errInvalidPasswordResetToken()
+		}
+		return "", xerror.E(err)
+	}
+	return userID, nil
+}
+
+func errInvalidPasswordResetToken() *xerror.Xerror {
+	return xerror.EInvalidInput(nil).SetErrorCode(xerror.ErrInvalidPasswordResetToken)
+}
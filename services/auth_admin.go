@@ -3,17 +3,26 @@ package services
 import (
 	"go_base/database"
 	"go_base/domain"
+	"go_base/services/auth"
+	"go_base/storage"
+	"go_base/xerror"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 type AuthAdminService struct {
 	store    *database.AuthStore
 	services *domain.AllServices
+	cache    *storage.Cache
+	cfg      *auth.AuthConfig
+	otp      *auth.OTP
 }
 
-func NewAuthAdminService(store *database.AuthStore, services *domain.AllServices) *AuthAdminService {
-	return &AuthAdminService{store: store, services: services}
+func NewAuthAdminService(store *database.AuthStore, services *domain.AllServices, cache *storage.Cache, cfg *auth.AuthConfig) *AuthAdminService {
+	s := &AuthAdminService{store: store, services: services, cache: cache, cfg: cfg}
+	s.otp = auth.NewOTP(auth.AdminOTPKeys, cfg, cache, s.sendOTP)
+	return s
 }
 
 // CreateAuth
@@ -40,3 +49,91 @@ func (s *AuthAdminService) FindAuth(ctx echo.Context, userID string) (*domain.Au
 	}
 	return auth, nil
 }
+
+// Refresh /staffs/token/refresh
+func (s *AuthAdminService) Refresh(ctx echo.Context, refreshToken string) (*domain.AuthResult, error) {
+	verify := func(ctx echo.Context, rt *domain.TokenExpires) error {
+		staff, err := s.services.Staff.Get(ctx, rt.UserID.String())
+		if err != nil {
+			return err
+		}
+		if !staff.IsVerified || staff.Status == domain.StaffInactive {
+			return xerror.EForbidden()
+		}
+		return s.services.Session.Verify(ctx, rt.UserID, rt.FamilyID)
+	}
+	return auth.RefreshAccessRefreshToken(ctx, refreshToken, s.cfg, s.services.TokenKey.Admin(), s.store, verify, s.FindAuth, s.UpdateAuth, s.CreateAuth, s.cache.SetCache, s.cache.ClearCache)
+}
+
+// Logout /staffs/logout revoke the current session
+func (s *AuthAdminService) Logout(ctx echo.Context) error {
+	_, userID := domain.GetUUID(domain.UserID(ctx))
+	_, sessionID := domain.GetUUID(domain.SessionID(ctx))
+	if userID == uuid.Nil || sessionID == uuid.Nil {
+		return xerror.EUnAuthorized()
+	}
+	if err := s.services.Session.Revoke(ctx, userID, sessionID.String()); err != nil {
+		return err
+	}
+	return s.store.ClearRefreshToken(ctx, userID, sessionID)
+}
+
+// LogoutAll /staffs/logout/all revoke every session of the current staff
+func (s *AuthAdminService) LogoutAll(ctx echo.Context) error {
+	_, userID := domain.GetUUID(domain.UserID(ctx))
+	if userID == uuid.Nil {
+		return xerror.EUnAuthorized()
+	}
+	return s.logoutAll(ctx, userID)
+}
+
+// ForceLogout /staffs/:id/logout revoke every session of another staff
+func (s *AuthAdminService) ForceLogout(ctx echo.Context, staffID string) error {
+	staff, err := s.services.Staff.Get(ctx, staffID)
+	if err != nil {
+		return err
+	}
+	return s.logoutAll(ctx, staff.ID)
+}
+
+func (s *AuthAdminService) logoutAll(ctx echo.Context, userID uuid.UUID) error {
+	if err := s.services.Session.RevokeAll(ctx, userID); err != nil {
+		return err
+	}
+	return s.store.ClearRefreshToken(ctx, userID, uuid.Nil)
+}
+
+// SecureLogin /staffs/login/secure check credentials and send otp to email
+func (s *AuthAdminService) SecureLogin(ctx echo.Context, creds domain.LoginCredentials) (*domain.ReferenceCode, error) {
+	staff, err := s.services.Staff.Authenticate(ctx, domain.StaffLogin{Email: creds.Email, Password: creds.Password})
+	if err != nil {
+		return nil, err
+	}
+	return s.otp.Issue(ctx, staff.ID.String(), staff.Email.String())
+}
+
+// VerifyOTP /staffs/login/verify verify otp of the reference code and issue tokens
+func (s *AuthAdminService) VerifyOTP(ctx echo.Context, req domain.VerifyEmailOTP) (*domain.AuthResult, error) {
+	userID, err := s.otp.Verify(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	staff, err := s.services.Staff.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.services.Staff.CompleteLogin(ctx, staff, req.DeviceID, req.TOTPCode)
+}
+
+// ResendOTP /staffs/login/resend send a new otp of the pending login
+func (s *AuthAdminService) ResendOTP(ctx echo.Context, email domain.UsersEmail) (*domain.ReferenceCode, error) {
+	return s.otp.Resend(ctx, email.Email.String())
+}
+
+func (s *AuthAdminService) sendOTP(ctx echo.Context, email string, code *domain.ReferenceCode, otp string) error {
+	return s.services.Mail.SendOTP(ctx, email, domain.MailOTP{
+		OTP:           otp,
+		ReferenceCode: code.ReferenceCode,
+		ExpireAt:      code.ExpireAt,
+	})
+}
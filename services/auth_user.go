@@ -3,17 +3,26 @@ package services
 import (
 	"go_base/database"
 	"go_base/domain"
+	"go_base/services/auth"
+	"go_base/storage"
+	"go_base/xerror"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 type AuthUserService struct {
 	store    *database.AuthStore
 	services *domain.AllServices
+	cache    *storage.Cache
+	cfg      *auth.AuthConfig
+	otp      *auth.OTP
 }
 
-func NewAuthUserService(store *database.AuthStore, services *domain.AllServices) *AuthUserService {
-	return &AuthUserService{store: store, services: services}
+func NewAuthUserService(store *database.AuthStore, services *domain.AllServices, cache *storage.Cache, cfg *auth.AuthConfig) *AuthUserService {
+	s := &AuthUserService{store: store, services: services, cache: cache, cfg: cfg}
+	s.otp = auth.NewOTP(auth.UserOTPKeys, cfg, cache, s.sendOTP)
+	return s
 }
 
 // CreateAuth
@@ -40,3 +49,82 @@ func (s *AuthUserService) FindAuth(ctx echo.Context, userID string) (*domain.Aut
 	}
 	return auth, nil
 }
+
+// Refresh /users/token/refresh
+func (s *AuthUserService) Refresh(ctx echo.Context, refreshToken string) (*domain.AuthResult, error) {
+	verify := func(ctx echo.Context, rt *domain.TokenExpires) error {
+		user, err := s.services.User.Get(ctx, rt.UserID.String())
+		if err != nil {
+			return err
+		}
+		if !user.IsVerified {
+			return xerror.EForbidden()
+		}
+		return s.services.Session.Verify(ctx, rt.UserID, rt.FamilyID)
+	}
+	return auth.RefreshAccessRefreshToken(ctx, refreshToken, s.cfg, s.services.TokenKey.User(), s.store, verify, s.FindAuth, s.UpdateAuth, s.CreateAuth, s.cache.SetCache, s.cache.ClearCache)
+}
+
+// Logout /users/logout revoke the current session
+func (s *AuthUserService) Logout(ctx echo.Context) error {
+	_, userID := domain.GetUUID(domain.UserID(ctx))
+	_, sessionID := domain.GetUUID(domain.SessionID(ctx))
+	if userID == uuid.Nil || sessionID == uuid.Nil {
+		return xerror.EUnAuthorized()
+	}
+	if err := s.services.Session.Revoke(ctx, userID, sessionID.String()); err != nil {
+		return err
+	}
+	return s.store.ClearRefreshToken(ctx, userID, sessionID)
+}
+
+// LogoutAll /users/logout/all revoke every session of the current user
+func (s *AuthUserService) LogoutAll(ctx echo.Context) error {
+	_, userID := domain.GetUUID(domain.UserID(ctx))
+	if userID == uuid.Nil {
+		return xerror.EUnAuthorized()
+	}
+	return s.logoutAll(ctx, userID)
+}
+
+func (s *AuthUserService) logoutAll(ctx echo.Context, userID uuid.UUID) error {
+	if err := s.services.Session.RevokeAll(ctx, userID); err != nil {
+		return err
+	}
+	return s.store.ClearRefreshToken(ctx, userID, uuid.Nil)
+}
+
+// SecureLogin /users/login/secure check credentials and send otp to email
+func (s *AuthUserService) SecureLogin(ctx echo.Context, creds domain.LoginCredentials) (*domain.ReferenceCode, error) {
+	user, err := s.services.User.Authenticate(ctx, domain.UserLogin{Email: creds.Email, Password: creds.Password})
+	if err != nil {
+		return nil, err
+	}
+	return s.otp.Issue(ctx, user.ID.String(), user.Email.String())
+}
+
+// VerifyOTP /users/login/verify verify otp of the reference code and issue tokens
+func (s *AuthUserService) VerifyOTP(ctx echo.Context, req domain.VerifyEmailOTP) (*domain.AuthResult, error) {
+	userID, err := s.otp.Verify(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.services.User.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.services.User.CompleteLogin(ctx, user, req.DeviceID)
+}
+
+// ResendOTP /users/login/resend send a new otp of the pending login
+func (s *AuthUserService) ResendOTP(ctx echo.Context, email domain.UsersEmail) (*domain.ReferenceCode, error) {
+	return s.otp.Resend(ctx, email.Email.String())
+}
+
+func (s *AuthUserService) sendOTP(ctx echo.Context, email string, code *domain.ReferenceCode, otp string) error {
+	return s.services.Mail.SendOTP(ctx, email, domain.MailOTP{
+		OTP:           otp,
+		ReferenceCode: code.ReferenceCode,
+		ExpireAt:      code.ExpireAt,
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// BaseService generic CRUD, the fields tagged perm are redacted from reads and rejected on writes without the permission
 type BaseService[T, U, C any] struct {
 	store     *database.Store
 	services  *domain.AllServices
@@ -26,21 +27,38 @@ func NewBaseService[T, U, C any](store *database.Store, base *database.BaseStore
 }
 
 func (s *BaseService[T, U, C]) GET(ctx echo.Context, id string) (*T, error) {
-	return s.baseStore.GetByID(ctx, id)
+	m, err := s.baseStore.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	redactFields(ctx, s.services, m)
+	return m, nil
 }
 
 func (s *BaseService[T, U, C]) Create(ctx echo.Context, m *T) error {
+	if err := checkFieldWrites(ctx, s.services, m); err != nil {
+		return err
+	}
 	return s.baseStore.Create(ctx, m)
 }
 
 func (s *BaseService[T, U, C]) CreateC(ctx echo.Context, m *C) error {
+	if err := checkFieldWrites(ctx, s.services, m); err != nil {
+		return err
+	}
 	return s.baseStore.CreateC(ctx, m)
 }
 
 func (s *BaseService[T, U, C]) Update(ctx echo.Context, m *T) error {
+	if err := checkFieldWrites(ctx, s.services, m); err != nil {
+		return err
+	}
 	return s.baseStore.Update(ctx, m)
 }
 func (s *BaseService[T, U, C]) UpdateU(ctx echo.Context, m *U) error {
+	if err := checkFieldWrites(ctx, s.services, m); err != nil {
+		return err
+	}
 	return s.baseStore.UpdateU(ctx, m)
 }
 
@@ -49,22 +67,48 @@ func (s *BaseService[T, U, C]) Delete(ctx echo.Context, id uuid.UUID) error {
 }
 
 func (s *BaseService[T, U, C]) Find(ctx echo.Context, pagination domain.Pagination[T]) (*domain.Pagination[T], error) {
-	return s.baseStore.Find(ctx, pagination)
+	pg, err := s.baseStore.Find(ctx, pagination)
+	if err != nil {
+		return nil, err
+	}
+	redactFields(ctx, s.services, pg)
+	return pg, nil
 }
 
 // for user_id
 func (s *BaseService[T, U, C]) FindWithUserID(ctx echo.Context, pagination domain.Pagination[T], ignoreRelations ...string) (*domain.Pagination[T], error) {
-	return s.baseStore.FindWithUserID(ctx, pagination, ignoreRelations...)
+	pg, err := s.baseStore.FindWithUserID(ctx, pagination, ignoreRelations...)
+	if err != nil {
+		return nil, err
+	}
+	redactFields(ctx, s.services, pg)
+	return pg, nil
 }
 
 func (s *BaseService[T, U, C]) GetWithUserID(ctx echo.Context, idStr string) (*T, error) {
-	return s.baseStore.GetWithUserID(ctx, idStr)
+	m, err := s.baseStore.GetWithUserID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	redactFields(ctx, s.services, m)
+	return m, nil
 }
 
 func (s *BaseService[T, U, C]) UpdateWithUserID(ctx echo.Context, model *U, typeLog ...string) error {
+	if err := checkFieldWrites(ctx, s.services, model); err != nil {
+		return err
+	}
 	return s.baseStore.UpdateWithUserID(ctx, model, typeLog...)
 }
 
 func (s *BaseService[T, U, C]) DeleteWithUserID(ctx echo.Context, id uuid.UUID) error {
 	return s.baseStore.DeleteWithUserID(ctx, id)
 }
+
+func (s *BaseService[T, U, C]) History(ctx echo.Context, id uuid.UUID) (*domain.Pagination[domain.LogHistory], error) {
+	logs, err := s.baseStore.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return logHistory(ctx, s.services, logs)
+}
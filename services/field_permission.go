@@ -0,0 +1,56 @@
+package services
+
+import (
+	"go_base/domain"
+	"go_base/domain/permission"
+	"go_base/xerror"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+/*
+fieldAllow perm tags allowed to the caller of the request
+
+	staff: the role has {perm}.true,
+	service account: the scopes have {perm}.true,
+	user (own data) and system: every field
+*/
+func fieldAllow(ctx echo.Context, services *domain.AllServices) domain.FieldAllowFunc {
+	allowAll := func(string) bool { return true }
+	var has func(name string) bool
+	if account := domain.ServiceAccountFromContext(ctx); account != nil {
+		has = func(name string) bool { return account.HasScope(name) }
+	} else if isUser, _ := ctx.Get(string(domain.IsUserKey)).(bool); isUser {
+		return allowAll
+	} else if staff := domain.StaffFromContext(ctx); staff != nil {
+		has = func(name string) bool { return services.Role.HasPermission(ctx, staff.RoleID, name) }
+	} else {
+		return allowAll
+	}
+	allowed := map[string]bool{}
+	return func(perm string) bool {
+		ok, found := allowed[perm]
+		if !found {
+			ok = has(perm + "." + permission.ScopeAll)
+			allowed[perm] = ok
+		}
+		return ok
+	}
+}
+
+// redactFields zero the fields of v the caller has no permission to see
+func redactFields(ctx echo.Context, services *domain.AllServices, v any) {
+	domain.RedactFields(v, fieldAllow(ctx, services))
+}
+
+// checkFieldWrites forbidden with the fields of v the caller has no permission to change
+func checkFieldWrites(ctx echo.Context, services *domain.AllServices, v any) error {
+	denied := domain.DeniedFields(v, fieldAllow(ctx, services))
+	if len(denied) == 0 {
+		return nil
+	}
+	return xerror.EForbidden().
+		SetMessage("no permission to change %s", strings.Join(denied, ", ")).
+		SetExtraInfo("fields", denied)
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"go_base/domain"
+	"reflect"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+)
+
+// logHistory field by field timeline of the logs, the changes of the perm fields are removed like in the responses
+func logHistory[T any](ctx echo.Context, services *domain.AllServices, logs *domain.Pagination[*domain.Logs[T]]) (*domain.Pagination[domain.LogHistory], error) {
+	var model T
+	perms := domain.ColumnPermissions(reflect.TypeOf(model))
+	allow := fieldAllow(ctx, services)
+
+	history := &domain.Pagination[domain.LogHistory]{
+		PaginationSwagger: logs.PaginationSwagger,
+		TotalCount:        logs.TotalCount,
+		TotalPage:         logs.TotalPage,
+		Items:             make([]domain.LogHistory, 0, len(logs.Items)),
+	}
+	for _, log := range logs.Items {
+		entry, err := log.History()
+		if err != nil {
+			return nil, err
+		}
+		entry.Changes = lo.Filter(entry.Changes, func(change domain.FieldChange, _ int) bool {
+			perm, ok := perms[change.Field]
+			return !ok || allow(perm)
+		})
+		history.Items = append(history.Items, entry)
+	}
+	return history, nil
+}
@@ -0,0 +1,111 @@
+package services_test
+
+import (
+	"encoding/json"
+	"go_base/controller"
+	"go_base/services/auth"
+	"go_base/xerror"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (uts *UnitTestSuite) newLockout(cfg auth.AuthConfig, notify auth.LockoutNotifyFunc) *auth.Lockout {
+	prefix := "test:lockout:" + uuid.NewString()[:8]
+	return auth.NewLockout(uts.server.Redis, auth.LockoutKeys{
+		Strikes: prefix + ":strikes:%s",
+		Locked:  prefix + ":locked:%s",
+		IP:      prefix + ":ip:%s",
+	}, &cfg, notify)
+}
+
+// lockoutContext request of the ip, the lockout read the ip of the request
+func lockoutContext(ip string) echo.Context {
+	req := httptest.NewRequest(http.MethodPost, "/staffs/login", nil)
+	req.RemoteAddr = ip + ":1234"
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+// errorResponse status, Retry-After header and body of err rendered by the error handler
+func errorResponse(err error) (int, string, controller.ErrorResponse) {
+	rec := httptest.NewRecorder()
+	controller.ErrorHandler(false)(err, echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec))
+	var res controller.ErrorResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec.Code, rec.Header().Get(echo.HeaderRetryAfter), res
+}
+
+func (uts *UnitTestSuite) TestLockout_LockAndExpire() {
+	type notified struct {
+		email   string
+		strikes int
+		until   time.Time
+	}
+	var notifications []notified
+	l := uts.newLockout(auth.AuthConfig{LockoutSteps: []struct {
+		Strikes  int
+		Duration time.Duration
+	}{{Strikes: 3, Duration: 2 * time.Second}}}, func(ctx echo.Context, email string, strikes int, until time.Time) error {
+		notifications = append(notifications, notified{email: email, strikes: strikes, until: until})
+		return nil
+	})
+	ctx := lockoutContext("10.0.0.1")
+	email := uuid.NewString() + "@example.com"
+
+	// failed logins below the step only count
+	for i := 1; i < 3; i++ {
+		err := l.Fail(ctx, email)
+		if uts.Error(err) {
+			uts.Equal(xerror.ErrCodeInvalidInput, err.(*xerror.Xerror).StatusCode)
+			uts.Equal(i, err.(*xerror.Xerror).ExtraInfo["counter"])
+		}
+		uts.NoError(l.Check(ctx, email))
+	}
+	uts.Empty(notifications)
+
+	// the step lock the email and notify its owner once
+	_ = l.Fail(ctx, email)
+	if uts.Len(notifications, 1) {
+		uts.Equal(email, notifications[0].email)
+		uts.Equal(3, notifications[0].strikes)
+		uts.WithinDuration(time.Now().Add(2*time.Second), notifications[0].until, time.Second)
+	}
+	err := l.Check(ctx, email)
+	if uts.Error(err) {
+		status, retryAfter, res := errorResponse(err)
+		uts.Equal(http.StatusForbidden, status)
+		uts.Equal(xerror.ErrCodeTooManyLoginAttempts, res.Data["reason"])
+		seconds, _ := strconv.Atoi(retryAfter)
+		uts.InDelta(2, seconds, 1)
+		uts.EqualValues(seconds, res.Data[xerror.RetryAfterKey])
+	}
+
+	// the lock expire by itself
+	time.Sleep(2100 * time.Millisecond)
+	uts.NoError(l.Check(ctx, email))
+}
+
+func (uts *UnitTestSuite) TestLockout_IPThrottle() {
+	l := uts.newLockout(auth.AuthConfig{AccountLockoutMaxAttempts: 10, IPLockoutMaxAttempts: 3, IPLockoutWindow: time.Minute}, nil)
+	ctx := lockoutContext("10.0.0.2")
+
+	// every email fail once from the same ip
+	for i := 0; i < 3; i++ {
+		uts.NoError(l.Check(ctx, uuid.NewString()+"@example.com"))
+		_ = l.Fail(ctx, uuid.NewString()+"@example.com")
+	}
+	err := l.Check(ctx, uuid.NewString()+"@example.com")
+	if uts.Error(err) {
+		status, retryAfter, res := errorResponse(err)
+		uts.Equal(http.StatusTooManyRequests, status)
+		uts.Equal(xerror.ErrLoginIPThrottled, res.Data["reason"])
+		uts.NotEmpty(retryAfter)
+	}
+
+	// another ip is not throttled
+	uts.NoError(l.Check(lockoutContext("10.0.0.3"), uuid.NewString()+"@example.com"))
+}
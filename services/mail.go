@@ -0,0 +1,88 @@
+package services
+
+import (
+	"go_base/domain"
+	"go_base/mail"
+	"go_base/xerror"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type MailService struct {
+	transport mail.Transport
+	from      string
+	appName   string
+	baseUrl   string
+}
+
+func NewMailService(transport mail.Transport, from, appName, baseUrl string) *MailService {
+	return &MailService{transport: transport, from: from, appName: appName, baseUrl: strings.TrimRight(baseUrl, "/")}
+}
+
+// mailData every template get the same data, unused fields are empty
+type mailData struct {
+	AppName       string
+	Name          string
+	TmpPassword   string
+	URL           string
+	OTP           string
+	ReferenceCode string
+	ExpireAt      time.Time
+	Attempts      int
+}
+
+func (s *MailService) SendInvitation(ctx echo.Context, to string, data domain.MailToken) error {
+	return s.send(ctx, mail.TemplateInvitation, to, s.tokenData(data))
+}
+
+func (s *MailService) SendVerification(ctx echo.Context, to string, data domain.MailToken) error {
+	return s.send(ctx, mail.TemplateVerification, to, s.tokenData(data))
+}
+
+func (s *MailService) SendOTP(ctx echo.Context, to string, data domain.MailOTP) error {
+	return s.send(ctx, mail.TemplateOTP, to, mailData{
+		AppName:       s.appName,
+		OTP:           data.OTP,
+		ReferenceCode: data.ReferenceCode,
+		ExpireAt:      data.ExpireAt,
+	})
+}
+
+func (s *MailService) SendPasswordReset(ctx echo.Context, to string, data domain.MailToken) error {
+	return s.send(ctx, mail.TemplatePasswordReset, to, s.tokenData(data))
+}
+
+func (s *MailService) SendAccountLocked(ctx echo.Context, to string, data domain.MailLockout) error {
+	return s.send(ctx, mail.TemplateAccountLocked, to, mailData{
+		AppName:  s.appName,
+		Name:     data.Name,
+		Attempts: data.Attempts,
+		ExpireAt: data.LockedUntil,
+	})
+}
+
+func (s *MailService) tokenData(data domain.MailToken) mailData {
+	return mailData{
+		AppName:     s.appName,
+		Name:        data.Name,
+		TmpPassword: data.TmpPassword,
+		URL:         s.baseUrl + data.Path + "?token=" + url.QueryEscape(data.Token),
+		ExpireAt:    data.ExpireAt,
+	}
+}
+
+func (s *MailService) send(ctx echo.Context, template, to string, data mailData) error {
+	msg, err := mail.Render(template, data)
+	if err != nil {
+		return xerror.E(err)
+	}
+	msg.From = s.from
+	msg.To = []string{to}
+	if err := s.transport.Send(ctx.Request().Context(), msg); err != nil {
+		return xerror.E(err)
+	}
+	return nil
+}
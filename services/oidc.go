@@ -0,0 +1,171 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_base/database"
+	"go_base/domain"
+	"go_base/hash"
+	"go_base/services/auth"
+	"go_base/storage"
+	"go_base/xerror"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+)
+
+type OIDCService struct {
+	store      *database.Store
+	services   *domain.AllServices
+	staffStore database.StaffStore
+	cache      *storage.Cache
+	cfg        *auth.AuthConfig
+	providers  map[string]*auth.OIDCProvider
+}
+
+func NewOIDCService(store *database.Store, staff *database.StaffStore, services *domain.AllServices, cache *storage.Cache, cfg *auth.AuthConfig, providers ...*auth.OIDCProvider) *OIDCService {
+	s := &OIDCService{store: store, services: services, staffStore: *staff, cache: cache, cfg: cfg, providers: map[string]*auth.OIDCProvider{}}
+	for _, p := range providers {
+		s.providers[p.Config.Name] = p
+	}
+	return s
+}
+
+// GET /staffs/oidc/providers
+func (s *OIDCService) Providers() []string {
+	names := lo.Keys(s.providers)
+	sort.Strings(names)
+	return names
+}
+
+// GET /staffs/oidc/:provider/authorize
+func (s *OIDCService) Authorize(ctx echo.Context, provider string, req domain.OIDCAuthorize) (*domain.OIDCAuthorization, error) {
+	p, err := s.provider(provider)
+	if err != nil {
+		return nil, err
+	}
+	verifier, _, err := auth.NewPKCE()
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	stateID, err := hash.RandomToken(hash.TokenBytes)
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	nonce, err := hash.RandomToken(hash.TokenBytes)
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	url, err := p.AuthCodeURL(ctx.Request().Context(), stateID, nonce, verifier)
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	state, err := json.Marshal(domain.OIDCState{Provider: provider, CodeVerifier: verifier, Nonce: nonce, DeviceID: req.DeviceID})
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	if err := s.cache.SetCache(ctx.Request().Context(), fmt.Sprintf(domain.OIDCStateCache, stateID), string(state), domain.OIDCStateDuration); err != nil {
+		return nil, err
+	}
+	return &domain.OIDCAuthorization{URL: url, State: stateID}, nil
+}
+
+// POST /staffs/oidc/:provider/callback
+func (s *OIDCService) Callback(ctx echo.Context, provider string, req domain.OIDCCallback) (*domain.AuthResult, error) {
+	p, err := s.provider(provider)
+	if err != nil {
+		return nil, err
+	}
+	// the state can be used once, a replayed callback fail here
+	value, err := s.cache.PopStringValue(ctx.Request().Context(), fmt.Sprintf(domain.OIDCStateCache, req.State))
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil, errInvalidOIDCState()
+		}
+		return nil, err
+	}
+	var state domain.OIDCState
+	if err := json.Unmarshal([]byte(value), &state); err != nil || state.Provider != provider {
+		return nil, errInvalidOIDCState()
+	}
+	identity, err := p.Exchange(ctx.Request().Context(), req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		return nil, xerror.EUnAuthorized().SetErrorCode(xerror.ErrOIDCLoginFailed).SetDebugInfo("oidc_exchange", err)
+	}
+	email := strings.ToLower(identity.Email)
+	if email == "" || !identity.EmailVerified || !p.Config.AllowEmail(email) {
+		return nil, xerror.EForbidden().SetErrorCode(xerror.ErrOIDCEmailNotAllowed)
+	}
+	staff, err := s.staffStore.GetByEmail(ctx, domain.SensitiveString(email))
+	if err != nil {
+		if !xerror.IsNotFoundError(err) {
+			return nil, err
+		}
+		if !p.Config.AutoProvision {
+			return nil, xerror.EForbidden().SetErrorCode(xerror.ErrOIDCAccountNotFound)
+		}
+		if staff, err = s.provision(ctx, &p.Config, email, identity); err != nil {
+			return nil, err
+		}
+	}
+	if staff.Status == domain.StaffInactive {
+		return nil, xerror.EForbidden().SetErrorCode(xerror.ErrOIDCAccountNotFound)
+	}
+	// the provider verified the email, an invited staff does not need the verify link anymore
+	if !staff.IsVerified || staff.Status != domain.StaffActive {
+		if err := s.staffStore.Update(ctx, &domain.Staff{
+			BaseModel:  domain.BaseModel{ID: staff.ID},
+			IsVerified: true,
+			Status:     domain.StaffActive,
+		}, domain.OIDCLoginLog); err != nil {
+			return nil, err
+		}
+		staff.IsVerified = true
+		staff.Status = domain.StaffActive
+	}
+	return s.services.Staff.CompleteLogin(ctx, staff, state.DeviceID, req.TOTPCode)
+}
+
+// provision staff of the verified email with the default role of the provider, the random password is never sent
+func (s *OIDCService) provision(ctx echo.Context, cfg *auth.OIDCConfig, email string, identity *domain.OIDCIdentity) (*domain.Staff, error) {
+	firstName, lastName := identity.GivenName, identity.FamilyName
+	if firstName == "" {
+		firstName = lo.Ternary(identity.Name != "", identity.Name, strings.Split(email, "@")[0])
+	}
+	password := s.cfg.PasswordPolicy().Generate(s.cfg.LenTempPwd)
+	staff := domain.Staff{
+		Email:             domain.SensitiveString(email),
+		FirstName:         firstName,
+		LastName:          lastName,
+		Password:          domain.Password(password),
+		IsVerified:        true,
+		Status:            domain.StaffActive,
+		PasswordChangedAt: domain.TimeNowPtr(),
+	}
+	if cfg.DefaultRoleID != "" {
+		roleID, err := uuid.Parse(cfg.DefaultRoleID)
+		if err != nil {
+			return nil, xerror.E(err)
+		}
+		staff.RoleID = &roleID
+	}
+	if err := s.staffStore.Create(ctx, &staff, domain.OIDCProvisionLog); err != nil {
+		return nil, err
+	}
+	return &staff, nil
+}
+
+func (s *OIDCService) provider(name string) (*auth.OIDCProvider, error) {
+	p, ok := s.providers[name]
+	if !ok {
+		return nil, xerror.ENotFoundResource("oidc provider")
+	}
+	return p, nil
+}
+
+func errInvalidOIDCState() *xerror.Xerror {
+	return xerror.EInvalidInput().SetErrorCode(xerror.ErrInvalidOIDCState)
+}
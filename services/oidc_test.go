@@ -0,0 +1,171 @@
+package services_test
+
+import (
+	"go_base/domain"
+	"go_base/hash"
+	"go_base/services"
+	"go_base/services/auth"
+	"go_base/services/auth/oidctest"
+	"go_base/storage"
+	"go_base/xerror"
+	"strings"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+)
+
+func (uts *UnitTestSuite) newOIDCService(idp *oidctest.Provider, autoProvision bool, allowedDomains ...string) *services.OIDCService {
+	cfg := auth.AuthConfig(uts.server.Cfg.AdminAuth)
+	provider := auth.NewOIDCProvider(auth.OIDCConfig{
+		Name:           "test",
+		DiscoveryURL:   idp.DiscoveryURL(),
+		ClientID:       idp.ClientID,
+		ClientSecret:   idp.ClientSecret,
+		RedirectURL:    "https://admin.example.com/oidc/test/callback",
+		AllowedDomains: allowedDomains,
+		AutoProvision:  autoProvision,
+	}, nil)
+	return services.NewOIDCService(uts.server.Stores.Base, uts.server.Stores.Staff, uts.service, uts.server.Redis, &cfg, provider)
+}
+
+func (uts *UnitTestSuite) oidcLogin(s *services.OIDCService, idp *oidctest.Provider, claims oidctest.Claims) (*domain.AuthResult, error) {
+	authorization, err := s.Authorize(uts.ctx, "test", domain.OIDCAuthorize{})
+	if err != nil {
+		return nil, err
+	}
+	code, state, err := idp.Consent(authorization.URL, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.Callback(uts.ctx, "test", domain.OIDCCallback{Code: code, State: state})
+}
+
+func (uts *UnitTestSuite) TestOIDCService_Callback() {
+	idp := oidctest.NewProvider("client", "secret")
+	defer idp.Close()
+	email := strings.ToLower(faker.Username()) + "@example.com"
+
+	// unknown email without auto provisioning
+	_, err := uts.oidcLogin(uts.newOIDCService(idp, false), idp, oidctest.Claims{Subject: "1", Email: email, EmailVerified: true})
+	uts.Equal(xerror.ErrOIDCAccountNotFound, err.Error())
+
+	s := uts.newOIDCService(idp, true, "example.com")
+
+	// the provider did not verify the email
+	_, err = uts.oidcLogin(s, idp, oidctest.Claims{Subject: "1", Email: email})
+	uts.Equal(xerror.ErrOIDCEmailNotAllowed, err.Error())
+
+	// email of another domain
+	_, err = uts.oidcLogin(s, idp, oidctest.Claims{Subject: "1", Email: "staff@gmail.com", EmailVerified: true})
+	uts.Equal(xerror.ErrOIDCEmailNotAllowed, err.Error())
+
+	// auto provisioned on first login, mapped by email on the next
+	result, err := uts.oidcLogin(s, idp, oidctest.Claims{Subject: "1", Email: email, EmailVerified: true, GivenName: "Oidc", FamilyName: "Staff"})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEmpty(result.AccessToken)
+	staff, err := uts.service.Staff.GetByEmail(uts.ctx, domain.SensitiveString(email))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.True(staff.IsVerified)
+	uts.Equal(domain.StaffActive, staff.Status)
+	uts.Equal("Oidc", staff.FirstName)
+
+	_, err = uts.oidcLogin(s, idp, oidctest.Claims{Subject: "1", Email: email, EmailVerified: true})
+	uts.NoError(err)
+}
+
+func (uts *UnitTestSuite) TestOIDCService_Callback_TwoFactor() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	idp := oidctest.NewProvider("client", "secret")
+	defer idp.Close()
+	s := uts.newOIDCService(idp, false)
+	login := domain.StaffLogin{Email: "unlocktest1@admin.com", Password: successPass}
+	claims := oidctest.Claims{Subject: "1", Email: login.Email.String(), EmailVerified: true}
+	if err := uts.service.Staff.Unlock(uts.ctx, domain.StaffUnlock{Email: login.Email.String()}); err != nil {
+		uts.T().Fatal(err)
+	}
+	staff, err := uts.service.Staff.GetByEmail(uts.ctx, login.Email)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	enrollment, err := uts.service.TwoFactor.Enroll(uts.ctx, login)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	code, err := hash.TOTPCode(enrollment.Secret, time.Now())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.service.TwoFactor.Enable(uts.ctx, domain.TwoFactorEnable{Email: login.Email, Password: login.Password, Code: code}); err != nil {
+		uts.T().Fatal(err)
+	}
+	defer uts.server.Stores.TwoFactor.DeleteByUserID(uts.ctx, &domain.TwoFactor{UserID: staff.ID})
+
+	// the provider login does not replace the totp
+	_, err = uts.oidcLogin(s, idp, claims)
+	uts.Equal(xerror.ErrTwoFactorRequired, err.Error())
+
+	authorization, err := s.Authorize(uts.ctx, "test", domain.OIDCAuthorize{})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	callback, state, err := idp.Consent(authorization.URL, claims)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	result, err := s.Callback(uts.ctx, "test", domain.OIDCCallback{Code: callback, State: state, TOTPCode: enrollment.RecoveryCodes[0]})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEmpty(result.AccessToken)
+
+	// locked account can't login with the provider
+	login.Password = failedPass
+	defer uts.service.Staff.Unlock(uts.ctx, domain.StaffUnlock{Email: login.Email.String()})
+	for i := 0; i < 50; i++ {
+		if _, err = uts.service.Staff.LoginWithEmailPassword(uts.ctx, login); err.(*xerror.Xerror).ExtraInfo["reason"] == xerror.ErrCodeTooManyLoginAttempts {
+			break
+		}
+	}
+	_, err = uts.oidcLogin(s, idp, claims)
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrCodeTooManyLoginAttempts, err.(*xerror.Xerror).ExtraInfo["reason"])
+	}
+}
+
+func (uts *UnitTestSuite) TestOIDCService_State() {
+	idp := oidctest.NewProvider("client", "secret")
+	defer idp.Close()
+	s := uts.newOIDCService(idp, true)
+	claims := oidctest.Claims{Subject: "1", Email: strings.ToLower(faker.Username()) + "@example.com", EmailVerified: true}
+
+	// unknown state
+	_, err := s.Callback(uts.ctx, "test", domain.OIDCCallback{Code: "code", State: "unknown"})
+	uts.Equal(xerror.ErrInvalidOIDCState, err.Error())
+
+	authorization, err := s.Authorize(uts.ctx, "test", domain.OIDCAuthorize{})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	code, state, err := idp.Consent(authorization.URL, claims)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	_, err = s.Callback(uts.ctx, "test", domain.OIDCCallback{Code: code, State: state})
+	uts.NoError(err)
+
+	// the state is used once
+	_, err = s.Callback(uts.ctx, "test", domain.OIDCCallback{Code: code, State: state})
+	uts.Equal(xerror.ErrInvalidOIDCState, err.Error())
+
+	// unknown provider
+	_, err = s.Authorize(uts.ctx, "other", domain.OIDCAuthorize{})
+	uts.Error(err)
+}
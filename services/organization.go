@@ -0,0 +1,71 @@
+package services
+
+import (
+	"go_base/database"
+	"go_base/domain"
+	"go_base/storage"
+	"go_base/xerror"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type OrganizationService struct {
+	store             *database.Store
+	services          *domain.AllServices
+	cache             *storage.Cache
+	organizationStore *database.OrganizationStore
+}
+
+func NewOrganizationService(store *database.Store, organization *database.OrganizationStore, services *domain.AllServices, cache *storage.Cache) *OrganizationService {
+	return &OrganizationService{store: store, services: services, organizationStore: organization, cache: cache}
+}
+
+// POST /organizations
+func (s *OrganizationService) Create(ctx echo.Context, create *domain.OrganizationCreate) (*domain.Organization, error) {
+	organization := domain.Organization{
+		BaseModel:   domain.BaseModel{ID: uuid.New()},
+		Name:        create.Name,
+		Description: create.Description,
+	}
+	if err := s.organizationStore.Create(ctx, &organization); err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// PUT /organizations/:id
+func (s *OrganizationService) Update(ctx echo.Context, update *domain.OrganizationUpdate) error {
+	return s.organizationStore.UpdateU(ctx, update)
+}
+
+// GET /organizations/:id
+func (s *OrganizationService) GetByID(ctx echo.Context, id string) (*domain.Organization, error) {
+	return s.organizationStore.GetByID(ctx, id)
+}
+
+// GET /organizations
+func (s *OrganizationService) Find(ctx echo.Context, pagination domain.Pagination[domain.Organization]) (*domain.Pagination[domain.Organization], error) {
+	return s.organizationStore.Find(ctx, pagination)
+}
+
+// GET /organizations/:id/history
+func (s *OrganizationService) History(ctx echo.Context, id uuid.UUID) (*domain.Pagination[domain.LogHistory], error) {
+	logs, err := s.organizationStore.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return logHistory(ctx, s.services, logs)
+}
+
+// DELETE /organizations/:id, the staff must be moved to another organization first
+func (s *OrganizationService) Delete(ctx echo.Context, id uuid.UUID) error {
+	count, err := s.organizationStore.CountStaff(ctx, id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return xerror.EConflict(nil).SetMessage("organization has %d staff", count)
+	}
+	return s.organizationStore.Delete(ctx, id)
+}
@@ -0,0 +1,95 @@
+package services_test
+
+import (
+	"go_base/domain"
+	"go_base/xerror"
+	"strings"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+func (uts *UnitTestSuite) TestOrganizationService_Scope() {
+	branchA, err := uts.service.Organization.Create(uts.ctx, &domain.OrganizationCreate{Name: "Branch " + faker.Username()})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	branchB, err := uts.service.Organization.Create(uts.ctx, &domain.OrganizationCreate{Name: "Branch " + faker.Username()})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	manager := &domain.Staff{Email: domain.SensitiveString(faker.Email()), FirstName: "Manager", IsVerified: true, Status: domain.StaffActive, OrganizationID: &branchA.ID}
+	other := &domain.Staff{Email: domain.SensitiveString(faker.Email()), FirstName: "Other", IsVerified: true, Status: domain.StaffActive, OrganizationID: &branchB.ID}
+	for _, staff := range []*domain.Staff{manager, other} {
+		if err := uts.server.Stores.Staff.Create(uts.ctx, staff); err != nil {
+			uts.T().Fatal(err)
+		}
+	}
+	leads := map[uuid.UUID]*domain.Staff{}
+	for _, staff := range []*domain.Staff{manager, other} {
+		user := &domain.User{Email: domain.SensitiveString(strings.ToLower(faker.Username()) + "@example.com"), FirstName: faker.FirstName(), LastName: faker.LastName(), StaffID: &staff.ID}
+		if err := uts.server.Stores.User.Create(uts.ctx, user); err != nil {
+			uts.T().Fatal(err)
+		}
+		leads[user.ID] = staff
+	}
+
+	// the organization with staff can't be deleted
+	err = uts.service.Organization.Delete(uts.ctx, branchA.ID)
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrCodeConflict, err.(*xerror.Xerror).StatusCode)
+	}
+
+	uts.ctx.Set(domain.StaffCtx, manager)
+	uts.ctx.Set(string(domain.PermissionScopeKey), &domain.ScopeGrant{Resource: "user", Scope: domain.PermissionScopeOrg})
+	defer uts.ctx.Set(string(domain.PermissionScopeKey), nil)
+	defer uts.ctx.Set(domain.StaffCtx, nil)
+
+	users, err := uts.server.Stores.User.Find(uts.ctx, domain.Pagination[domain.User]{})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	for _, user := range users.Items {
+		uts.Equal(manager.ID, *user.StaffID)
+	}
+	for id, staff := range leads {
+		_, err := uts.server.Stores.User.GetByID(uts.ctx, id.String())
+		if staff == manager {
+			uts.NoError(err)
+			continue
+		}
+		uts.Error(err)
+		// out of the scope is not found
+		err = uts.server.Stores.User.UpdateU(uts.ctx, &domain.UserUpdate{ID: id, FirstName: lo.ToPtr("Moved")})
+		uts.True(xerror.IsNotFoundError(err))
+	}
+}
+
+func (uts *UnitTestSuite) TestOrganizationService_History() {
+	org, err := uts.service.Organization.Create(uts.ctx, &domain.OrganizationCreate{Name: "History " + uuid.NewString()[:8], Description: "first"})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.service.Organization.Update(uts.ctx, &domain.OrganizationUpdate{ID: org.ID, Description: lo.ToPtr("second")}); err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.service.Organization.Delete(uts.ctx, org.ID); err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// newest first, the deleted row keep its timeline
+	history, err := uts.service.Organization.History(uts.ctx, org.ID)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if uts.Len(history.Items, 3) {
+		uts.Equal("delete", history.Items[0].Action)
+		uts.Contains(history.Items[0].Changes, domain.FieldChange{Field: "description", Old: "second", New: nil})
+		uts.Equal([]domain.FieldChange{{Field: "description", Old: "first", New: "second"}}, history.Items[1].Changes)
+		uts.Contains(history.Items[2].Changes, domain.FieldChange{Field: "name", Old: nil, New: org.Name})
+	}
+
+	_, err = uts.service.Organization.History(uts.ctx, uuid.New())
+	uts.True(xerror.IsNotFoundError(err))
+}
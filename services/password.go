@@ -0,0 +1,58 @@
+package services
+
+import (
+	"go_base/database"
+	"go_base/domain"
+	"go_base/storage"
+	"go_base/validate"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type PasswordService struct {
+	store                *database.Store
+	services             *domain.AllServices
+	cache                *storage.Cache
+	passwordHistoryStore *database.PasswordHistoryStore
+}
+
+func NewPasswordService(store *database.Store, passwordHistory *database.PasswordHistoryStore, services *domain.AllServices, cache *storage.Cache) *PasswordService {
+	return &PasswordService{store: store, services: services, passwordHistoryStore: passwordHistory, cache: cache}
+}
+
+// Validate policy first, then reuse of the last policy.History passwords
+func (s *PasswordService) Validate(ctx echo.Context, policy domain.PasswordPolicy, userID uuid.UUID, current domain.Password, field, password string) error {
+	if err := validate.Password(policy, field, password); err != nil {
+		return err
+	}
+	if policy.History <= 0 || userID == uuid.Nil {
+		return nil
+	}
+	histories, err := s.passwordHistoryStore.FindRecent(ctx, userID, policy.History)
+	if err != nil {
+		return err
+	}
+	// account created before the history has only the current password
+	histories = append(histories, domain.PasswordHistory{Password: current})
+	for _, history := range histories {
+		if history.Password != "" && history.Password.CompareBcrypt(password) {
+			return validate.Fields(domain.FieldErrors{{
+				Field: field,
+				Err:   field + " must not be one of the last passwords",
+			}})
+		}
+	}
+	return nil
+}
+
+// Record keep the new hash, older than policy.History are removed
+func (s *PasswordService) Record(ctx echo.Context, policy domain.PasswordPolicy, userID uuid.UUID, hashed domain.Password) error {
+	if policy.History <= 0 {
+		return nil
+	}
+	if err := s.passwordHistoryStore.Create(ctx, &domain.PasswordHistory{UserID: userID, Password: hashed}); err != nil {
+		return err
+	}
+	return s.passwordHistoryStore.Prune(ctx, userID, policy.History)
+}
@@ -2,15 +2,20 @@ package services
 
 import (
 	"encoding/json"
+	"fmt"
 	"go_base/database"
 	"go_base/domain"
 	"go_base/logger"
 	"go_base/storage"
-	"strings"
+	"go_base/validate"
+	"go_base/xerror"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"gorm.io/datatypes"
+	"github.com/samber/lo"
 )
 
 type RoleService struct {
@@ -18,64 +23,137 @@ type RoleService struct {
 	services  *domain.AllServices
 	cache     *storage.Cache
 	roleStore *database.RoleStore
+
+	// compiled permission sets, in-process in front of redis
+	permissionTTL   time.Duration
+	permissionCheck time.Duration
+	permissionMu    sync.RWMutex
+	permissions     map[uuid.UUID]cachedPermissionSet
+}
+
+// cachedPermissionSet compiled permissions of a version of the role, in-process and in redis
+type cachedPermissionSet struct {
+	Set       *domain.PermissionSet `json:"set"`
+	Version   int                   `json:"version"`
+	expiresAt time.Time
+	// the in-process set is used without reading the version until checkAt
+	checkAt time.Time
 }
 
-func NewRoleService(store *database.Store, role *database.RoleStore, services *domain.AllServices, cache *storage.Cache) *RoleService {
-	return &RoleService{store: store, services: services, roleStore: role, cache: cache}
+func NewRoleService(store *database.Store, role *database.RoleStore, services *domain.AllServices, cache *storage.Cache, permissionTTL, permissionCheck time.Duration) *RoleService {
+	if permissionTTL <= 0 {
+		permissionTTL = domain.RolePermissionCacheDuration
+	}
+	if permissionCheck <= 0 {
+		permissionCheck = domain.RolePermissionVersionCheck
+	}
+	return &RoleService{
+		store:           store,
+		services:        services,
+		roleStore:       role,
+		cache:           cache,
+		permissionTTL:   permissionTTL,
+		permissionCheck: permissionCheck,
+		permissions:     map[uuid.UUID]cachedPermissionSet{},
+	}
 }
 
+// HasPermission one of the required permissions is granted by the role, the role without permissions or not found has none
 func (s *RoleService) HasPermission(ctx echo.Context, roleID *uuid.UUID, requiredPermissions ...string) bool {
-	role, err := s.roleStore.GetByID(ctx, roleID.String())
-	if err != nil {
-		logger.L().Errorf("error while getting role: %v\n", err)
+	if roleID == nil {
+		return false
 	}
-	// if permission is empty, then return false
-	if role.Permissions == nil {
+	set, err := s.PermissionSet(ctx, *roleID)
+	if err != nil {
+		logger.Ctx(ctx.Request().Context()).Errorw("error while getting role permissions", "role_id", roleID, "error", err)
 		return false
 	}
-
 	for _, requiredPermission := range requiredPermissions {
-		if hasPermission(role.Permissions, requiredPermission) {
+		if set.Allows(requiredPermission) {
 			return true
 		}
 	}
-
 	return false
 }
 
 /*
-hasPermission checks if the user has the required permission to access the resource
+PermissionSet compiled permissions of the role
 
-	permission from the role of the user
-	allowedPermission is the permission required to access the resource in controllers
+	read from the in-process cache, then redis, then the role in postgres,
+	both caches expire after permissionTTL and a cached set is used only while it has
+	the current version of the role, every change of the role bump the version in redis.
+	the in-process set is trusted for permissionCheck before the version is read again,
+	so the other instances reload a changed role within permissionCheck
 */
-func hasPermission(permission datatypes.JSON, allowedPermission string) bool {
-	args := strings.Split(allowedPermission, ".")
-
-	if len(args) < 4 {
-		logger.L().Infof("invalid permission format: %s\n", allowedPermission)
-
-		return false
+func (s *RoleService) PermissionSet(ctx echo.Context, roleID uuid.UUID) (*domain.PermissionSet, error) {
+	now := time.Now()
+	s.permissionMu.RLock()
+	cached, ok := s.permissions[roleID]
+	s.permissionMu.RUnlock()
+	ok = ok && now.Before(cached.expiresAt)
+	if ok && now.Before(cached.checkAt) {
+		return cached.Set, nil
 	}
 
-	system, resource, action, scope := args[0], args[1], args[2], args[3]
-
-	var pt domain.PermissionTree
-	if err := json.Unmarshal(permission, &pt); err != nil {
-		logger.L().Errorf("error while unmarshalling permission: %v\n", err)
+	c := ctx.Request().Context()
+	version, err := s.cache.GetStrikes(c, fmt.Sprintf(domain.RolePermissionVersionCache, roleID))
+	if err != nil {
+		return nil, xerror.E(err)
 	}
-	// if found map[*:map[*:map[*:]]] in the permission tree, then return true
-	// map[*:map[*:map[*:]]]
-	if pt["*"]["*"]["*"] == "*" {
-		return true
+	if ok && cached.Version == version {
+		cached.checkAt = now.Add(s.permissionCheck)
+		s.permissionMu.Lock()
+		s.permissions[roleID] = cached
+		s.permissionMu.Unlock()
+		return cached.Set, nil
 	}
 
-	// case permission view is disabled return false
-	if pt[system][resource]["view"] == "false" {
-		return false
+	key := fmt.Sprintf(domain.RolePermissionCache, roleID)
+	cached = cachedPermissionSet{}
+	if raw, err := s.cache.GetCache(c, key); err == nil {
+		if err := json.Unmarshal(raw, &cached); err != nil {
+			logger.Ctx(c).Warnw("invalid cached role permissions", "role_id", roleID, "error", err)
+			cached = cachedPermissionSet{}
+		}
+	} else if !xerror.IsNotFoundError(err) {
+		logger.Ctx(c).Warnw("error while getting cached role permissions", "role_id", roleID, "error", err)
+	}
+
+	if cached.Set == nil || cached.Version != version {
+		role, err := s.roleStore.GetByID(ctx, roleID.String())
+		if err != nil {
+			return nil, err
+		}
+		set, err := domain.CompilePermissions(role.Permissions)
+		if err != nil {
+			return nil, xerror.E(err)
+		}
+		cached = cachedPermissionSet{Set: set, Version: version}
+		if raw, err := json.Marshal(cached); err == nil {
+			if err := s.cache.SetCache(c, key, raw, s.permissionTTL); err != nil {
+				logger.Ctx(c).Warnw("error while caching role permissions", "role_id", roleID, "error", err)
+			}
+		}
 	}
 
-	return pt[system][resource][action] == scope
+	cached.expiresAt = now.Add(s.permissionTTL)
+	cached.checkAt = now.Add(s.permissionCheck)
+	s.permissionMu.Lock()
+	s.permissions[roleID] = cached
+	s.permissionMu.Unlock()
+	return cached.Set, nil
+}
+
+// invalidatePermissions bump the version of the role, the cached permissions of every instance are stale
+func (s *RoleService) invalidatePermissions(ctx echo.Context, roleID uuid.UUID) error {
+	c := ctx.Request().Context()
+	if _, err := s.cache.IncreaseStrike(c, fmt.Sprintf(domain.RolePermissionVersionCache, roleID)); err != nil {
+		return xerror.E(err)
+	}
+	s.permissionMu.Lock()
+	delete(s.permissions, roleID)
+	s.permissionMu.Unlock()
+	return s.cache.ClearCache(c, fmt.Sprintf(domain.RolePermissionCache, roleID))
 }
 
 func (s *RoleService) Create(ctx echo.Context, role *domain.Role) error {
@@ -83,15 +161,69 @@ func (s *RoleService) Create(ctx echo.Context, role *domain.Role) error {
 }
 
 func (s *RoleService) Update(ctx echo.Context, role *domain.RoleUpdate) error {
-	return s.roleStore.UpdateU(ctx, role)
+	if err := s.roleStore.UpdateU(ctx, role); err != nil {
+		return err
+	}
+	return s.invalidatePermissions(ctx, role.ID)
 }
 
 func (s *RoleService) GetByID(ctx echo.Context, id string) (*domain.Role, error) {
 	return s.roleStore.GetByID(ctx, id)
 }
 
-func (s *RoleService) UpdateUserPermissions(ctx echo.Context, userID uuid.UUID, permissionIDs []uuid.UUID) error {
-	return s.roleStore.UpdateStaffPermissions(ctx, userID, permissionIDs)
+// DELETE /roles/:id
+func (s *RoleService) Delete(ctx echo.Context, id uuid.UUID, reassignTo *uuid.UUID) error {
+	if reassignTo != nil && *reassignTo == id {
+		return xerror.EInvalidInputField("reassign_to")
+	}
+	if err := s.roleStore.DeleteReassign(ctx, id, reassignTo); err != nil {
+		if xerror.IsNotFoundError(err) {
+			return xerror.ENotFoundResource("role")
+		}
+		return err
+	}
+	return s.invalidatePermissions(ctx, id)
+}
+
+// POST /roles/:id/clone, the wildcard permissions of super admin can't be cloned
+func (s *RoleService) Clone(ctx echo.Context, id uuid.UUID, clone *domain.RoleClone) (*domain.Role, error) {
+	source, err := s.roleStore.GetByID(ctx, id.String())
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil, xerror.ENotFoundResource("role")
+		}
+		return nil, err
+	}
+	if err := validate.ValidPermissions(reflect.ValueOf(source.Permissions)); err != nil {
+		return nil, xerror.EInvalidInput(fmt.Errorf("permissions of the role can't be cloned: %v", err))
+	}
+	role := domain.NewRole(source.Type, clone.Name, lo.FromPtrOr(clone.Description, source.Description), source.Permissions)
+	role.ID = uuid.New()
+	role.RequireTwoFactor = source.RequireTwoFactor
+	if err := s.roleStore.Create(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// PUT /roles/:id/staffs
+func (s *RoleService) AssignStaffs(ctx echo.Context, id uuid.UUID, staffIDs []uuid.UUID) error {
+	if _, err := s.roleStore.GetByID(ctx, id.String()); err != nil {
+		if xerror.IsNotFoundError(err) {
+			return xerror.ENotFoundResource("role")
+		}
+		return err
+	}
+	return s.roleStore.AssignStaffs(ctx, id, staffIDs)
+}
+
+// GET /roles/:id/history
+func (s *RoleService) History(ctx echo.Context, id uuid.UUID) (*domain.Pagination[domain.LogHistory], error) {
+	logs, err := s.roleStore.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return logHistory(ctx, s.services, logs)
 }
 
 // GET /roles
@@ -0,0 +1,125 @@
+package services_test
+
+import (
+	"context"
+	"go_base/domain"
+	"go_base/server"
+	"go_base/services"
+	"go_base/xerror"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gorm.io/datatypes"
+)
+
+func (uts *UnitTestSuite) TestRoleService_Lifecycle() {
+	source := domain.NewRole(domain.RoleTypeAdmin, "Src "+uuid.NewString()[:8], "source", datatypes.JSON(`{"admin":{"user":{"view":"true"}}}`))
+	if err := uts.service.Role.Create(uts.ctx, source); err != nil {
+		uts.T().Fatal(err)
+	}
+	clone, err := uts.service.Role.Clone(uts.ctx, source.ID, &domain.RoleClone{Name: "Cln " + uuid.NewString()[:8]})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(source.Description, clone.Description)
+	uts.JSONEq(string(source.Permissions), string(clone.Permissions))
+
+	staff := &domain.Staff{Email: domain.SensitiveString(faker.Email()), FirstName: "Role", IsVerified: true, Status: domain.StaffActive}
+	if err := uts.server.Stores.Staff.Create(uts.ctx, staff); err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// unknown staff are reported
+	err = uts.service.Role.AssignStaffs(uts.ctx, source.ID, []uuid.UUID{staff.ID, uuid.New()})
+	uts.True(xerror.IsNotFoundError(err))
+
+	if err := uts.service.Role.AssignStaffs(uts.ctx, source.ID, []uuid.UUID{staff.ID}); err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// the role with staff can't be deleted
+	err = uts.service.Role.Delete(uts.ctx, source.ID, nil)
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrCodeConflict, err.(*xerror.Xerror).StatusCode)
+	}
+
+	if err := uts.service.Role.Delete(uts.ctx, source.ID, &clone.ID); err != nil {
+		uts.T().Fatal(err)
+	}
+	moved, err := uts.server.Stores.Staff.GetByID(uts.ctx, staff.ID.String())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(clone.ID, *moved.RoleID)
+	_, err = uts.service.Role.GetByID(uts.ctx, source.ID.String())
+	uts.Error(err)
+
+	// the staff log every move of its role
+	history, err := uts.service.Staff.History(uts.ctx, staff.ID)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	var moves []domain.FieldChange
+	for _, entry := range history.Items {
+		moves = append(moves, lo.Filter(entry.Changes, func(change domain.FieldChange, _ int) bool { return change.Field == "role_id" })...)
+	}
+	uts.ElementsMatch([]domain.FieldChange{
+		{Field: "role_id", Old: nil, New: source.ID.String()},
+		{Field: "role_id", Old: source.ID.String(), New: clone.ID.String()},
+	}, moves)
+}
+
+func (uts *UnitTestSuite) TestRoleService_PermissionsAcrossInstances() {
+	role := domain.NewRole(domain.RoleTypeAdmin, "Perm "+uuid.NewString()[:8], "instances", datatypes.JSON(`{"admin":{"user":{"view":"true"}}}`))
+	if err := uts.service.Role.Create(uts.ctx, role); err != nil {
+		uts.T().Fatal(err)
+	}
+	// another instance with its own in-process cache on the same redis
+	other := services.NewRoleService(uts.server.Stores.Base, uts.server.Stores.Role, uts.service, uts.server.Redis, time.Hour, 500*time.Millisecond)
+	uts.True(other.HasPermission(uts.ctx, &role.ID, "admin.user.view.true"))
+	uts.False(other.HasPermission(uts.ctx, &role.ID, "admin.user.delete.true"))
+
+	if err := uts.service.Role.Update(uts.ctx, &domain.RoleUpdate{ID: role.ID, Permissions: lo.ToPtr(datatypes.JSON(`{"admin":{"user":{"delete":"true"}}}`))}); err != nil {
+		uts.T().Fatal(err)
+	}
+	// the instance changing the role drop its set at once, the other one trust its set until the version check
+	uts.False(uts.service.Role.HasPermission(uts.ctx, &role.ID, "admin.user.view.true"))
+	uts.True(other.HasPermission(uts.ctx, &role.ID, "admin.user.view.true"))
+	time.Sleep(600 * time.Millisecond)
+	uts.False(other.HasPermission(uts.ctx, &role.ID, "admin.user.view.true"))
+	uts.True(other.HasPermission(uts.ctx, &role.ID, "admin.user.delete.true"))
+}
+
+// BenchmarkRoleService_HasPermission lookup of a cached role, with and without the version read from redis
+func BenchmarkRoleService_HasPermission(b *testing.B) {
+	app, err := server.CreateAppForTest(context.Background())
+	if err != nil {
+		b.Skip(err)
+	}
+	ctx := *MockEchoContext()
+	role := domain.NewRole(domain.RoleTypeAdmin, "Bench "+uuid.NewString()[:8], "benchmark", datatypes.JSON(`{"admin":{"user":{"view":"true"}}}`))
+	if err := app.Services.Role.Create(ctx, role); err != nil {
+		b.Fatal(err)
+	}
+	defer app.Stores.Role.Delete(ctx, role.ID)
+
+	for _, bc := range []struct {
+		name  string
+		check time.Duration
+	}{
+		{name: "version read every call", check: time.Nanosecond},
+		{name: "version read every 5s", check: domain.RolePermissionVersionCheck},
+	} {
+		s := services.NewRoleService(app.Stores.Base, app.Stores.Role, app.Services, app.Redis, time.Hour, bc.check)
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if !s.HasPermission(ctx, &role.ID, "admin.user.view.true") {
+					b.Fatal("should have permission")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"go_base/database"
+	"go_base/domain"
+	"go_base/hash"
+	"go_base/logger"
+	"go_base/storage"
+	"go_base/xerror"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+)
+
+type ServiceAccountService struct {
+	store        *database.Store
+	services     *domain.AllServices
+	cache        *storage.Cache
+	accountStore *database.ServiceAccountStore
+}
+
+func NewServiceAccountService(store *database.Store, account *database.ServiceAccountStore, services *domain.AllServices, cache *storage.Cache) *ServiceAccountService {
+	return &ServiceAccountService{store: store, services: services, accountStore: account, cache: cache}
+}
+
+// POST /service-accounts
+func (s *ServiceAccountService) Create(ctx echo.Context, create *domain.ServiceAccountCreate) (*domain.ServiceAccountAPIKey, error) {
+	if err := s.checkScopes(ctx, create.Scopes); err != nil {
+		return nil, err
+	}
+	account := domain.ServiceAccount{
+		Name:        create.Name,
+		Description: create.Description,
+		Scopes:      datatypes.NewJSONSlice(create.Scopes),
+		ExpiresAt:   create.ExpiresAt,
+	}
+	if staff := domain.StaffFromContext(ctx); staff != nil {
+		account.CreatedByID = &staff.ID
+	}
+	apiKey, err := setAPIKey(&account)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.accountStore.Create(ctx, &account); err != nil {
+		return nil, err
+	}
+	return &domain.ServiceAccountAPIKey{ServiceAccount: account, APIKey: apiKey}, nil
+}
+
+// PUT /service-accounts/:id
+func (s *ServiceAccountService) Update(ctx echo.Context, update *domain.ServiceAccountUpdate) error {
+	if update.Scopes != nil {
+		if err := s.checkScopes(ctx, *update.Scopes); err != nil {
+			return err
+		}
+	}
+	return s.accountStore.UpdateU(ctx, update)
+}
+
+// GET /service-accounts/:id
+func (s *ServiceAccountService) GetByID(ctx echo.Context, id string) (*domain.ServiceAccount, error) {
+	return s.accountStore.GetByID(ctx, id)
+}
+
+// GET /service-accounts
+func (s *ServiceAccountService) Find(ctx echo.Context, pagination domain.Pagination[domain.ServiceAccount]) (*domain.Pagination[domain.ServiceAccount], error) {
+	return s.accountStore.Find(ctx, pagination)
+}
+
+// DELETE /service-accounts/:id
+func (s *ServiceAccountService) Delete(ctx echo.Context, id uuid.UUID) error {
+	return s.accountStore.Delete(ctx, id)
+}
+
+// POST /service-accounts/:id/rotate
+func (s *ServiceAccountService) RotateKey(ctx echo.Context, id string) (*domain.ServiceAccountAPIKey, error) {
+	account, err := s.accountStore.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := setAPIKey(account)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.accountStore.Update(ctx, account, domain.RotateAPIKeyLog); err != nil {
+		return nil, err
+	}
+	return &domain.ServiceAccountAPIKey{ServiceAccount: *account, APIKey: apiKey}, nil
+}
+
+// Authenticate find the account by the prefix of the key and compare the hash,
+// last_used_at is updated at most once per APIKeyLastUsedWindow
+func (s *ServiceAccountService) Authenticate(ctx echo.Context, apiKey string) (*domain.ServiceAccount, error) {
+	prefix, _, ok := domain.SplitAPIKey(apiKey)
+	if !ok {
+		return nil, errInvalidAPIKey()
+	}
+	account, err := s.accountStore.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil, errInvalidAPIKey()
+		}
+		return nil, xerror.E(err)
+	}
+	if !hash.CompareToken(account.KeyHash, apiKey) || account.Expired() {
+		return nil, errInvalidAPIKey()
+	}
+	if err := s.accountStore.Touch(ctx, account.ID, domain.APIKeyLastUsedWindow); err != nil {
+		logger.Ctx(ctx.Request().Context()).Warnw("update service account last used failed", "id", account.ID, "error", err)
+	}
+	return account, nil
+}
+
+// checkScopes the staff can only grant the permissions of its own role to the account
+func (s *ServiceAccountService) checkScopes(ctx echo.Context, scopes []string) error {
+	staff := domain.StaffFromContext(ctx)
+	if staff == nil {
+		return xerror.EForbidden().SetErrorCode(xerror.ErrServiceAccountScopeNotGranted)
+	}
+	for _, scope := range scopes {
+		if !s.services.Role.HasPermission(ctx, staff.RoleID, scope) {
+			return xerror.EForbidden().SetErrorCode(xerror.ErrServiceAccountScopeNotGranted).SetExtraInfo("scope", scope)
+		}
+	}
+	return nil
+}
+
+// setAPIKey generate <prefix>.<secret> and keep only the prefix and the hash on the account
+func setAPIKey(account *domain.ServiceAccount) (string, error) {
+	prefix, err := hash.RandomString(domain.APIKeyPrefixLength, hash.CharsetV1)
+	if err != nil {
+		return "", xerror.E(err)
+	}
+	secret, err := hash.RandomToken(hash.TokenBytes)
+	if err != nil {
+		return "", xerror.E(err)
+	}
+	apiKey := prefix + "." + secret
+	account.KeyPrefix = prefix
+	account.KeyHash = hash.HashToken(apiKey)
+	return apiKey, nil
+}
+
+func errInvalidAPIKey() *xerror.Xerror {
+	return xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrInvalidAPIKey).SetStatusCode(xerror.ErrCodeUnauthorized)
+}
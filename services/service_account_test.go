@@ -0,0 +1,109 @@
+package services_test
+
+import (
+	"go_base/domain"
+	"go_base/storage"
+	"go_base/xerror"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gorm.io/datatypes"
+)
+
+var superAdminRoleID = uuid.MustParse("11a1111a-1111-1a11-11a1-1ff111111112")
+
+func (uts *UnitTestSuite) TestServiceAccountService_Authenticate() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	uts.ctx.Set(domain.StaffCtx, &domain.Staff{RoleID: &superAdminRoleID})
+	defer uts.ctx.Set(domain.StaffCtx, nil)
+
+	created, err := uts.service.ServiceAccount.Create(uts.ctx, &domain.ServiceAccountCreate{
+		Name:   faker.Username(),
+		Scopes: []string{"admin.developer.view.true"},
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEmpty(created.APIKey)
+	uts.NotContains(created.KeyHash, created.KeyPrefix)
+
+	account, err := uts.service.ServiceAccount.Authenticate(uts.ctx, created.APIKey)
+	uts.NoError(err)
+	uts.Equal(created.ID, account.ID)
+	uts.True(account.HasScope("admin.developer.view.true"))
+
+	// wrong secret of a known prefix
+	_, err = uts.service.ServiceAccount.Authenticate(uts.ctx, created.KeyPrefix+".wrong")
+	uts.Equal(xerror.ErrInvalidAPIKey, err.Error())
+
+	// the old key stop working after rotation
+	rotated, err := uts.service.ServiceAccount.RotateKey(uts.ctx, created.ID.String())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	_, err = uts.service.ServiceAccount.Authenticate(uts.ctx, created.APIKey)
+	uts.Error(err)
+	_, err = uts.service.ServiceAccount.Authenticate(uts.ctx, rotated.APIKey)
+	uts.NoError(err)
+
+	// expired
+	err = uts.service.ServiceAccount.Update(uts.ctx, &domain.ServiceAccountUpdate{ID: created.ID, ExpiresAt: lo.ToPtr(time.Now().Add(-time.Minute))})
+	uts.NoError(err)
+	_, err = uts.service.ServiceAccount.Authenticate(uts.ctx, rotated.APIKey)
+	uts.Error(err)
+
+	// deleted
+	uts.NoError(uts.service.ServiceAccount.Delete(uts.ctx, created.ID))
+	_, err = uts.service.ServiceAccount.Authenticate(uts.ctx, rotated.APIKey)
+	uts.Error(err)
+}
+
+func (uts *UnitTestSuite) TestServiceAccountService_Scopes() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	marketing := uuid.MustParse("02b78806-3e14-5570-a619-1f3b4a655df7")
+	uts.ctx.Set(domain.StaffCtx, &domain.Staff{RoleID: &marketing})
+	defer uts.ctx.Set(domain.StaffCtx, nil)
+
+	// the scopes must be registered in the permission catalog, even for a super admin
+	for _, scope := range []string{"admin.user.view.true", "admin.unknown.view.true", "admin.user.fly.true", "admin.user.view.any", "admin.user.view.false", "admin.user.view"} {
+		err := uts.ctx.Validate(&domain.ServiceAccountCreate{Name: faker.Username(), Scopes: []string{scope}})
+		uts.Equal(scope == "admin.user.view.true", err == nil, scope)
+	}
+
+	// the role of the staff does not grant the scope
+	_, err := uts.service.ServiceAccount.Create(uts.ctx, &domain.ServiceAccountCreate{
+		Name:   faker.Username(),
+		Scopes: []string{"admin.user.view.true", "admin.staff.delete.true"},
+	})
+	uts.Equal(xerror.ErrServiceAccountScopeNotGranted, err.Error())
+
+	created, err := uts.service.ServiceAccount.Create(uts.ctx, &domain.ServiceAccountCreate{
+		Name:   faker.Username(),
+		Scopes: []string{"admin.user.view.true"},
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	defer uts.service.ServiceAccount.Delete(uts.ctx, created.ID)
+
+	err = uts.service.ServiceAccount.Update(uts.ctx, &domain.ServiceAccountUpdate{
+		ID:     created.ID,
+		Scopes: lo.ToPtr(datatypes.NewJSONSlice([]string{"admin.role.update.true"})),
+	})
+	uts.Equal(xerror.ErrServiceAccountScopeNotGranted, err.Error())
+	account, err := uts.service.ServiceAccount.GetByID(uts.ctx, created.ID.String())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.False(account.HasScope("admin.role.update.true"))
+
+	// the account can't be managed without a staff
+	uts.ctx.Set(domain.StaffCtx, nil)
+	_, err = uts.service.ServiceAccount.Create(uts.ctx, &domain.ServiceAccountCreate{
+		Name:   faker.Username(),
+		Scopes: []string{"admin.user.view.true"},
+	})
+	uts.Error(err)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"go_base/database"
+	"go_base/domain"
+	"go_base/storage"
+	"go_base/xerror"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type SessionService struct {
+	store        *database.Store
+	services     *domain.AllServices
+	cache        *storage.Cache
+	sessionStore *database.SessionStore
+}
+
+func NewSessionService(store *database.Store, session *database.SessionStore, services *domain.AllServices, cache *storage.Cache) *SessionService {
+	return &SessionService{store: store, services: services, sessionStore: session, cache: cache}
+}
+
+// Create session on login, login again on the same device replace the old session
+func (s *SessionService) Create(ctx echo.Context, userID uuid.UUID, deviceID string) (*domain.Session, error) {
+	if deviceID == "" {
+		deviceID = uuid.NewString()
+	}
+	sessions, err := s.sessionStore.FindActiveByDevice(ctx, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if err := s.revoke(ctx, userID, session.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	session := domain.Session{
+		UserID:     userID,
+		DeviceID:   deviceID,
+		UserAgent:  ctx.Request().UserAgent(),
+		IP:         ctx.RealIP(),
+		LastSeenAt: domain.TimeNow(),
+	}
+	if err := s.sessionStore.Create(ctx, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Verify session is still active and update last seen
+func (s *SessionService) Verify(ctx echo.Context, userID, sessionID uuid.UUID) error {
+	session, err := s.sessionStore.GetActive(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	return s.Touch(ctx, userID, session.ID)
+}
+
+// Touch update last seen of the session, at most once per SessionLastSeenWindow
+func (s *SessionService) Touch(ctx echo.Context, userID, sessionID uuid.UUID) error {
+	return s.sessionStore.Touch(ctx, userID, sessionID, ctx.RealIP(), domain.SessionLastSeenWindow)
+}
+
+// Revoke session of the user
+func (s *SessionService) Revoke(ctx echo.Context, userID uuid.UUID, sessionID string) error {
+	_, sid := domain.GetUUID(sessionID)
+	if sid == uuid.Nil {
+		return xerror.EInvalidParameter(nil)
+	}
+	return s.revoke(ctx, userID, sid)
+}
+
+// RevokeAll revoke every session of the user
+func (s *SessionService) RevokeAll(ctx echo.Context, userID uuid.UUID) error {
+	ids, err := s.sessionStore.RevokeAll(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.cache.ClearCache(ctx.Request().Context(), fmt.Sprintf(domain.WhitelistAccessTokenCacheKey, userID, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GET /me/sessions
+func (s *SessionService) FindMe(ctx echo.Context) ([]domain.Session, error) {
+	_, userID := domain.GetUUID(domain.UserID(ctx))
+	if userID == uuid.Nil {
+		return nil, xerror.EUnAuthorized()
+	}
+	sessions, err := s.sessionStore.FindActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	current := domain.SessionID(ctx)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.String() == current
+	}
+	return sessions, nil
+}
+
+// DELETE /me/sessions/:id
+func (s *SessionService) RevokeMe(ctx echo.Context, sessionID string) error {
+	_, userID := domain.GetUUID(domain.UserID(ctx))
+	if userID == uuid.Nil {
+		return xerror.EUnAuthorized()
+	}
+	return s.Revoke(ctx, userID, sessionID)
+}
+
+func (s *SessionService) revoke(ctx echo.Context, userID, sessionID uuid.UUID) error {
+	if err := s.sessionStore.Revoke(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	return s.cache.ClearCache(ctx.Request().Context(), fmt.Sprintf(domain.WhitelistAccessTokenCacheKey, userID, sessionID))
+}
@@ -2,13 +2,15 @@ package services
 
 import (
 	"errors"
-	"fmt"
 	"go_base/database"
 	"go_base/domain"
 	"go_base/hash"
+	"go_base/logger"
 	"go_base/services/auth"
 	"go_base/storage"
+	"go_base/validate"
 	"go_base/xerror"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -22,10 +24,17 @@ type StaffService struct {
 	staffStore database.StaffStore
 	cache      *storage.Cache
 	cfg        *auth.AuthConfig
+	lockout    *auth.Lockout
 }
 
 func NewStaffService(store *database.Store, staff *database.StaffStore, services *domain.AllServices, cache *storage.Cache, cfg *auth.AuthConfig) *StaffService {
-	return &StaffService{store: store, services: services, staffStore: *staff, cache: cache, cfg: cfg}
+	s := &StaffService{store: store, services: services, staffStore: *staff, cache: cache, cfg: cfg}
+	s.lockout = auth.NewLockout(cache, auth.LockoutKeys{
+		Strikes: domain.StaffAuthCache,
+		Locked:  domain.StaffLockedCache,
+		IP:      domain.StaffIPCache,
+	}, cfg, s.notifyLockout)
+	return s
 }
 
 // GET /staff/:id
@@ -59,7 +68,7 @@ func (s *StaffService) Create(ctx echo.Context, staffCreate domain.StaffCreate)
 	if err != nil {
 		return nil, err
 	}
-	ran := hash.GenerateRandomString(s.cfg.LenTempPwd)
+	ran := s.cfg.PasswordPolicy().Generate(s.cfg.LenTempPwd)
 
 	staff := domain.Staff{
 		Email:       domain.SensitiveString(staffCreate.Email),
@@ -74,67 +83,171 @@ func (s *StaffService) Create(ctx echo.Context, staffCreate domain.StaffCreate)
 	if staffCreate.RoleID != nil {
 		staff.RoleID = lo.ToPtr(uuid.MustParse(*staffCreate.RoleID))
 	}
+	if staffCreate.OrganizationID != nil {
+		staff.OrganizationID = lo.ToPtr(uuid.MustParse(*staffCreate.OrganizationID))
+	}
 
-	if err := s.staffStore.Create(ctx, &staff); err != nil {
+	// the soft deleted staff of the email is replaced, it is only deleted when the new staff is created
+	if err := s.store.WithTx(ctx, func(ctx echo.Context) error {
+		// savepoint, the failed insert must not abort the transaction
+		err := s.store.WithTx(ctx, func(ctx echo.Context) error {
+			return s.staffStore.Create(ctx, &staff)
+		})
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			if err := s.staffStore.DeleteExistData(ctx, &staff); err != nil {
-				return nil, err
-			} else {
-				if err := s.staffStore.Create(ctx, &staff); err != nil {
-					return nil, err
-				}
-				return nil, nil
+				return err
 			}
+			// BeforeCreate of the failed insert already hashed the password
+			staff.Password = domain.Password(ran)
+			err = s.staffStore.Create(ctx, &staff)
+		}
+		if err != nil {
+			return err
 		}
+		return s.services.Password.Record(ctx, s.cfg.PasswordPolicy(), staff.ID, staff.Password)
+	}); err != nil {
 		return nil, err
 	}
 
-	// update role default
-
+	if err := s.sendInvitation(ctx, &staff); err != nil {
+		return nil, err
+	}
 	return &staff, nil
 }
 
+// sendInvitation email the temporary password and the verify link
+func (s *StaffService) sendInvitation(ctx echo.Context, staff *domain.Staff) error {
+	claims, err := domain.ParseVerifyToken(staff.VerifyToken, s.cfg.JWTSecret)
+	if err != nil {
+		return err
+	}
+	return s.services.Mail.SendInvitation(ctx, staff.Email.String(), domain.MailToken{
+		Name:        staff.FirstName,
+		TmpPassword: staff.TmpPassword,
+		Token:       staff.VerifyToken,
+		Path:        domain.StaffVerifyPath,
+		ExpireAt:    claims.ExpiresAt.Time,
+	})
+}
+
 // POST /staff/login
 func (s *StaffService) LoginWithEmailPassword(ctx echo.Context, login domain.StaffLogin) (*domain.AuthResult, error) {
-	if i, e := s.cache.GetStrikes(ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, login.Email)); e == nil {
-		if i >= s.cfg.AccountLockoutMaxAttempts {
-			s.staffStore.WriteLog(ctx, &domain.Staff{Email: domain.SensitiveString(login.Email)}, string(xerror.ErrCodeTooManyLoginAttempts))
-			return nil, xerror.EForbidden().SetExtraInfo("reason", xerror.ErrCodeTooManyLoginAttempts)
+	staff, err := s.Authenticate(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	return s.CompleteLogin(ctx, staff, login.DeviceID, login.TOTPCode)
+}
+
+// verifyTwoFactor staff who enabled totp or whose role require it must send the code
+func (s *StaffService) verifyTwoFactor(ctx echo.Context, staff *domain.Staff, totpCode string) error {
+	enabled, err := s.services.TwoFactor.IsEnabled(ctx, staff.ID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if staff.RoleID == nil {
+			return nil
 		}
+		role, err := s.services.Role.GetByID(ctx, staff.RoleID.String())
+		if err != nil {
+			return err
+		}
+		if role.RequireTwoFactor {
+			return xerror.EForbidden().SetErrorCode(xerror.ErrTwoFactorEnrollRequired)
+		}
+		return nil
+	}
+	if totpCode == "" {
+		return xerror.EUnAuthorized().SetErrorCode(xerror.ErrTwoFactorRequired)
+	}
+	if err := s.services.TwoFactor.Verify(ctx, staff.ID, totpCode); err != nil {
+		s.staffIncrementStrike(ctx, domain.StaffLogin{Email: staff.Email})
+		return err
+	}
+	return nil
+}
+
+// Authenticate check email and password, wrong credentials increase the lockout strike
+// and the expired password must be changed with /password/change before login
+func (s *StaffService) Authenticate(ctx echo.Context, login domain.StaffLogin) (*domain.Staff, error) {
+	staff, err := s.verifyCredentials(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.PasswordPolicy().Expired(lo.FromPtrOr(staff.PasswordChangedAt, staff.CreatedAt)) {
+		return nil, xerror.EForbidden().SetErrorCode(xerror.ErrPasswordExpired)
+	}
+	return staff, nil
+}
+
+func (s *StaffService) verifyCredentials(ctx echo.Context, login domain.StaffLogin) (*domain.Staff, error) {
+	if err := s.lockout.Check(ctx, login.Email.String()); err != nil {
+		s.staffStore.WriteLog(ctx, &domain.Staff{Email: login.Email}, string(xerror.ErrCodeTooManyLoginAttempts))
+		return nil, err
 	}
 	staff, err := s.staffStore.GetByEmail(ctx, login.Email)
 	if err != nil {
-		return s.staffIncrementStrike(ctx, login)
+		return nil, s.staffIncrementStrike(ctx, login)
 	}
 	password := string(staff.Password)
 	if !staff.IsVerified {
-		return s.staffIncrementStrike(ctx, login)
+		return nil, s.staffIncrementStrike(ctx, login)
+	}
+	if ok := hash.ComparePassword(password, login.Password); !ok {
+		return nil, s.staffIncrementStrike(ctx, login)
+	}
+	if err := s.lockout.Reset(ctx, login.Email.String()); err != nil {
+		return nil, err
 	}
-	if ok := hash.CompareBcrypt(password, login.Password); !ok {
-		return s.staffIncrementStrike(ctx, login)
+	s.rehashPassword(ctx, staff, login.Password)
+	return staff, nil
+}
+
+// rehashPassword legacy hash (bcrypt) is replaced by the configured hasher after login, failure only log
+func (s *StaffService) rehashPassword(ctx echo.Context, staff *domain.Staff, password string) {
+	if !staff.Password.NeedsRehash() {
+		return
+	}
+	if err := s.staffStore.Update(ctx, &domain.Staff{
+		BaseModel: domain.BaseModel{ID: staff.ID},
+		Password:  domain.Password(password).Hash(),
+	}, domain.RehashPasswordLog); err != nil {
+		logger.Ctx(ctx.Request().Context()).Warnw("rehash password failed", "error", err)
+	}
+}
+
+// CompleteLogin every staff login path must end here, the lockout and two factor are checked
+// before the session of the device is created and tokens are issued
+func (s *StaffService) CompleteLogin(ctx echo.Context, staff *domain.Staff, deviceID, totpCode string) (*domain.AuthResult, error) {
+	if err := s.lockout.Check(ctx, staff.Email.String()); err != nil {
+		return nil, err
+	}
+	if err := s.verifyTwoFactor(ctx, staff, totpCode); err != nil {
+		return nil, err
 	}
-	// login success
 	if err := s.staffStore.Update(ctx, &domain.Staff{
 		BaseModel: domain.BaseModel{ID: staff.ID},
 		LastLogin: domain.TimeNowPtr(),
 	}, domain.LoginLog); err != nil {
 		return nil, err
 	}
-	if err := s.cache.DeleteStrikes(ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, login.Email)); err != nil {
+	session, err := s.services.Session.Create(ctx, staff.ID, deviceID)
+	if err != nil {
 		return nil, err
 	}
-
-	auth, err := auth.IssueAccessRefreshToken(ctx, staff.ID, s.cfg, s.services.AuthAdmin.FindAuth, s.services.AuthAdmin.UpdateAuth, s.services.AuthAdmin.CreateAuth, s.cache.SetCache)
+	auth, err := auth.IssueAccessRefreshToken(ctx, staff.ID, session.ID, s.cfg, s.services.TokenKey.Admin(), s.services.AuthAdmin.FindAuth, s.services.AuthAdmin.UpdateAuth, s.services.AuthAdmin.CreateAuth, s.cache.SetCache)
 	if err != nil {
 		return nil, err
 	}
+	auth.DeviceID = session.DeviceID
 	return auth, nil
 }
 
 // POST /staff/unlock
 func (s *StaffService) Unlock(ctx echo.Context, unlock domain.StaffUnlock) error {
 
-	err := s.cache.DeleteStrikes(ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, unlock.Email))
+	err := s.lockout.Reset(ctx, unlock.Email)
 	if err != nil {
 		return err
 	}
@@ -174,6 +287,10 @@ func (s *StaffService) GetByEmail(ctx echo.Context, email domain.SensitiveString
 
 // UPDATE /staff/:id
 func (s *StaffService) Update(ctx echo.Context, staffUpdate domain.StaffUpdate) (*domain.StaffUpdate, error) {
+	// staff granted on the organization can't move a member out of it
+	if grant := domain.ScopeFromContext(ctx); grant != nil && grant.Scope == domain.PermissionScopeOrg && staffUpdate.OrganizationID != nil {
+		return nil, xerror.EForbidden().SetDebugInfo("msg", "organization scope")
+	}
 	if err := s.staffStore.UpdateU(ctx, &staffUpdate); err != nil {
 		return nil, err
 	}
@@ -201,10 +318,19 @@ func (s *StaffService) GetLog(ctx echo.Context, staff domain.StaffGetLog) (*doma
 	return result, nil
 }
 
+// GET /staffs/:id/history
+func (s *StaffService) History(ctx echo.Context, id uuid.UUID) (*domain.Pagination[domain.LogHistory], error) {
+	logs, err := s.staffStore.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return logHistory(ctx, s.services, logs)
+}
+
 // Mock IsVerified /staff/verify
 func (s *StaffService) Verify(ctx echo.Context, staff domain.StaffVerifyToken) (*domain.StaffVerifyTokenResponse, error) {
 	if _staff, err := s.staffStore.GetByKey(ctx, string(domain.StaffVerifyTokenType), staff.Token); err == nil {
-		if err := domain.ParseVerifyToken(staff.Token); err == nil {
+		if _, err := domain.ParseVerifyToken(staff.Token, s.cfg.JWTSecret); err == nil {
 
 			if err := s.staffStore.UpdateTokenVerify(ctx, _staff.ID); err != nil {
 				return nil, err
@@ -224,7 +350,7 @@ func (s *StaffService) GetToken(ctx echo.Context, staff domain.StaffGetToken) (*
 		if _staff.IsVerified {
 			return nil, xerror.EInvalidInputOk()
 		}
-		if ok := hash.CompareBcrypt(string(_staff.Password), staff.TmpPassword); ok {
+		if ok := hash.ComparePassword(string(_staff.Password), staff.TmpPassword); ok {
 			return &domain.StaffGetTokenResponse{
 				Token: _staff.VerifyToken,
 			}, nil
@@ -237,14 +363,8 @@ func (s *StaffService) GetToken(ctx echo.Context, staff domain.StaffGetToken) (*
 func (s *StaffService) UpdatePassword(ctx echo.Context, staff domain.StaffUpdatePassword) error {
 	if _staff, err := s.staffStore.GetByEmail(ctx, staff.Email); err == nil {
 		if _staff.IsVerified {
-			if ok := hash.CompareBcrypt(string(_staff.Password), staff.OldPassword); ok {
-				if err := s.staffStore.Update(ctx, &domain.Staff{
-					BaseModel: domain.BaseModel{ID: _staff.ID},
-					Password:  domain.Password(staff.Password).Hash(),
-				}, domain.ChangePasswordLog); err != nil {
-					return err
-				}
-				return nil
+			if ok := hash.ComparePassword(string(_staff.Password), staff.OldPassword); ok {
+				return s.setPassword(ctx, _staff, staff.Password, domain.ChangePasswordLog)
 			}
 		}
 	}
@@ -252,13 +372,102 @@ func (s *StaffService) UpdatePassword(ctx echo.Context, staff domain.StaffUpdate
 	return xerror.EInvalidInputOk()
 }
 
-func (s *StaffService) staffIncrementStrike(ctx echo.Context, login domain.StaffLogin) (*domain.AuthResult, error) {
-	s.staffStore.WriteLog(ctx, &domain.Staff{Email: domain.SensitiveString(login.Email)}, domain.LoginFail)
-	increaseStrike, e := s.cache.IncreaseStrike(ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, login.Email))
-	if e != nil {
-		return nil, e
+// POST /staffs/password/forgot, always success so the email can't be enumerated
+func (s *StaffService) ForgotPassword(ctx echo.Context, req domain.PasswordForgot) error {
+	staff, err := s.staffStore.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	if !staff.IsVerified {
+		return nil
+	}
+	token, err := auth.IssuePasswordResetToken(ctx, s.cfg, s.cache, domain.StaffPasswordResetCache, staff.ID.String())
+	if err != nil {
+		return err
+	}
+	if err := s.sendPasswordReset(ctx, staff, token); err != nil {
+		return err
+	}
+	return s.staffStore.WriteLog(ctx, &domain.Staff{BaseModel: domain.BaseModel{ID: staff.ID}, Email: staff.Email}, domain.ForgotPasswordLog)
+}
+
+// POST /staffs/password/reset, the token can be used once and every session is revoked
+func (s *StaffService) ResetPassword(ctx echo.Context, req domain.PasswordReset) error {
+	// check the policy before the token is consumed
+	if err := validate.Password(s.cfg.PasswordPolicy(), "password", req.Password); err != nil {
+		return err
+	}
+	userID, err := auth.ConsumePasswordResetToken(ctx, s.cfg, s.cache, domain.StaffPasswordResetCache, req.Token)
+	if err != nil {
+		return err
+	}
+	staff, err := s.staffStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, staff, req.Password, domain.ResetPasswordLog); err != nil {
+		return err
+	}
+	if err := s.lockout.Reset(ctx, staff.Email.String()); err != nil {
+		return err
+	}
+	return s.services.Session.RevokeAll(ctx, staff.ID)
+}
+
+// POST /staffs/password/change, the expired password can't login so the credentials are sent instead of the token
+func (s *StaffService) ChangePassword(ctx echo.Context, req domain.PasswordChange) error {
+	staff, err := s.verifyCredentials(ctx, domain.StaffLogin{Email: req.Email, Password: req.OldPassword})
+	if err != nil {
+		return err
+	}
+	return s.setPassword(ctx, staff, req.Password, domain.ChangePasswordLog)
+}
+
+// setPassword validate the new password with the policy and keep it in the history
+func (s *StaffService) setPassword(ctx echo.Context, staff *domain.Staff, password, typeLog string) error {
+	policy := s.cfg.PasswordPolicy()
+	if err := s.services.Password.Validate(ctx, policy, staff.ID, staff.Password, "password", password); err != nil {
+		return err
+	}
+	hashed := domain.Password(password).Hash()
+	if err := s.staffStore.Update(ctx, &domain.Staff{
+		BaseModel:         domain.BaseModel{ID: staff.ID},
+		Password:          hashed,
+		PasswordChangedAt: domain.TimeNowPtr(),
+	}, typeLog); err != nil {
+		return err
+	}
+	return s.services.Password.Record(ctx, policy, staff.ID, hashed)
+}
+
+func (s *StaffService) sendPasswordReset(ctx echo.Context, staff *domain.Staff, token string) error {
+	return s.services.Mail.SendPasswordReset(ctx, staff.Email.String(), domain.MailToken{
+		Name:     staff.FirstName,
+		Token:    token,
+		Path:     domain.StaffPasswordResetPath,
+		ExpireAt: time.Now().Add(s.cfg.VerifyTokenDuration),
+	})
+}
+
+func (s *StaffService) staffIncrementStrike(ctx echo.Context, login domain.StaffLogin) error {
+	s.staffStore.WriteLog(ctx, &domain.Staff{Email: login.Email}, domain.LoginFail)
+	return s.lockout.Fail(ctx, login.Email.String())
+}
+
+// notifyLockout mail the staff of the email, unknown email is not notified
+func (s *StaffService) notifyLockout(ctx echo.Context, email string, strikes int, until time.Time) error {
+	staff, err := s.staffStore.GetByEmail(ctx, domain.SensitiveString(email))
+	if err != nil {
+		return nil
 	}
-	return nil, xerror.EInvalidInput(nil).SetExtraInfo("counter", increaseStrike)
+	return s.services.Mail.SendAccountLocked(ctx, staff.Email.String(), domain.MailLockout{
+		Name:        staff.FirstName,
+		Attempts:    strikes,
+		LockedUntil: until,
+	})
 }
 
 // GetMe /staff/me
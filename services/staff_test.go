@@ -1,11 +1,20 @@
 package services_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"go_base/domain"
+	"go_base/hash"
+	"go_base/services/auth"
 	"go_base/storage"
+	"go_base/xerror"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-faker/faker/v4"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
 )
@@ -13,6 +22,7 @@ import (
 var (
 	failedPass  = "failed pass"
 	successPass = "Passw0rd!"
+	newPass     = "N3w-Passw0rd!2024"
 )
 
 func (uts *UnitTestSuite) TestStaffService_Unlock_IsVerified() {
@@ -338,6 +348,43 @@ func (uts *UnitTestSuite) TestStaffService_CreateDub() {
 	uts.Equal(gorm.ErrDuplicatedKey.Error(), err.Error())
 }
 
+func (uts *UnitTestSuite) TestStaffService_CreateReplaceDeleted() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	email := domain.SensitiveString("replace-" + uuid.NewString()[:8] + "@email.com")
+	old, err := uts.service.Staff.Create(uts.ctx, domain.StaffCreate{Email: email, FirstName: "Old", LastName: "LastName"})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.server.Stores.Staff.Delete(uts.ctx, old.ID); err != nil {
+		uts.T().Fatal(err)
+	}
+	exists := func(id uuid.UUID) bool {
+		var count int64
+		if err := uts.server.DB.Unscoped().Model(&domain.Staff{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			uts.T().Fatal(err)
+		}
+		return count == 1
+	}
+
+	// the insert of the new staff fail on the organization fk, the deleted staff is kept
+	_, err = uts.service.Staff.Create(uts.ctx, domain.StaffCreate{
+		Email:          email,
+		FirstName:      "New",
+		LastName:       "LastName",
+		OrganizationID: lo.ToPtr(uuid.NewString()),
+	})
+	uts.Error(err)
+	uts.True(exists(old.ID))
+
+	staff, err := uts.service.Staff.Create(uts.ctx, domain.StaffCreate{Email: email, FirstName: "New", LastName: "LastName"})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal("New", staff.FirstName)
+	uts.False(exists(old.ID))
+	uts.True(exists(staff.ID))
+}
+
 func (uts *UnitTestSuite) TestStaffService_Delete() {
 	storage.Migration[domain.Role](uts.server.DB, "roles.json")
 	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs.json")
@@ -376,3 +423,578 @@ func (uts *UnitTestSuite) TestStaffService_Delete() {
 	}
 	uts.Equal(1, len(staffs.Items))
 }
+
+func (uts *UnitTestSuite) TestStaffService_RefreshToken_Rotation() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	err = uts.server.Storages.Cache.DeleteStrikes(uts.ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, "unlocktest1@admin.com"))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	login, err := uts.service.Staff.LoginWithEmailPassword(uts.ctx, domain.StaffLogin{
+		Email:    "unlocktest1@admin.com",
+		Password: successPass,
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// first use rotate the refresh token
+	refreshed, err := uts.service.AuthAdmin.Refresh(uts.ctx, login.RefreshToken)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEqual(login.RefreshToken, refreshed.RefreshToken)
+
+	// reuse the rotated token revoke the whole family
+	_, err = uts.service.AuthAdmin.Refresh(uts.ctx, login.RefreshToken)
+	if err == nil {
+		uts.T().Fatal("should be refresh token reused")
+	}
+	uts.Equal("unauthorized", err.Error())
+
+	_, err = uts.service.AuthAdmin.Refresh(uts.ctx, refreshed.RefreshToken)
+	if err == nil {
+		uts.T().Fatal("should be revoked")
+	}
+	uts.Equal("unauthorized", err.Error())
+}
+
+func (uts *UnitTestSuite) TestStaffService_RefreshToken_FailedRefreshKeepToken() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	err = uts.server.Storages.Cache.DeleteStrikes(uts.ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, "unlocktest1@admin.com"))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	login, err := uts.service.Staff.LoginWithEmailPassword(uts.ctx, domain.StaffLogin{
+		Email:    "unlocktest1@admin.com",
+		Password: successPass,
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	cfg := auth.AuthConfig(uts.server.Cfg.AdminAuth)
+	refresh := func(verify func(ctx echo.Context, rt *domain.TokenExpires) error, update func(ctx echo.Context, userID string, update domain.Auth) error) error {
+		_, err := auth.RefreshAccessRefreshToken(uts.ctx, login.RefreshToken, &cfg, uts.service.TokenKey.Admin(), uts.server.Stores.Auth, verify,
+			uts.service.AuthAdmin.FindAuth, update, uts.service.AuthAdmin.CreateAuth, uts.server.Redis.SetCache, uts.server.Redis.ClearCache)
+		return err
+	}
+	verified := func(ctx echo.Context, rt *domain.TokenExpires) error { return nil }
+
+	// a failed verify does not rotate the token
+	uts.Error(refresh(func(ctx echo.Context, rt *domain.TokenExpires) error { return xerror.EForbidden() }, uts.service.AuthAdmin.UpdateAuth))
+
+	// a failed issue roll back the rotation
+	uts.Error(refresh(verified, func(ctx echo.Context, userID string, update domain.Auth) error { return xerror.EInternalError() }))
+
+	// the token is still the current one, not a reuse
+	refreshed, err := uts.service.AuthAdmin.Refresh(uts.ctx, login.RefreshToken)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEqual(login.RefreshToken, refreshed.RefreshToken)
+}
+
+func (uts *UnitTestSuite) TestStaffService_Sessions_MultiDevice() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	err = uts.server.Storages.Cache.DeleteStrikes(uts.ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, "unlocktest1@admin.com"))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	deviceA := uuid.NewString()
+	deviceB := uuid.NewString()
+	loginA, err := uts.service.Staff.LoginWithEmailPassword(uts.ctx, domain.StaffLogin{
+		Email:    "unlocktest1@admin.com",
+		Password: successPass,
+		DeviceID: deviceA,
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	loginB, err := uts.service.Staff.LoginWithEmailPassword(uts.ctx, domain.StaffLogin{
+		Email:    "unlocktest1@admin.com",
+		Password: successPass,
+		DeviceID: deviceB,
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(deviceA, loginA.DeviceID)
+	uts.Equal(deviceB, loginB.DeviceID)
+
+	// login on second device keep the first session alive
+	var claims domain.AuthClaims
+	if _, err := domain.ParseToken(loginA.AccessToken, &claims, uts.server.Cfg.AdminAuth.JWTSecret); err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.ctx.Set(string(domain.UserIDKey), claims.UserID)
+	uts.ctx.Set(string(domain.SessionIDKey), claims.SessionID)
+	sessions, err := uts.service.Session.FindMe(uts.ctx)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Len(sessions, 2)
+
+	// a request touch the session once per window
+	_, userID := domain.GetUUID(claims.UserID)
+	_, sessionID := domain.GetUUID(claims.SessionID)
+	lastSeen := func() time.Time {
+		var session domain.Session
+		if err := uts.server.DB.First(&session, "id = ?", sessionID).Error; err != nil {
+			uts.T().Fatal(err)
+		}
+		return session.LastSeenAt
+	}
+	stale := time.Now().Add(-2 * domain.SessionLastSeenWindow)
+	if err := uts.server.DB.Model(&domain.Session{}).Where("id = ?", sessionID).UpdateColumn("last_seen_at", stale).Error; err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NoError(uts.service.Session.Touch(uts.ctx, userID, sessionID))
+	touched := lastSeen()
+	uts.WithinDuration(time.Now(), touched, 5*time.Second)
+	uts.NoError(uts.service.Session.Touch(uts.ctx, userID, sessionID))
+	uts.Equal(touched, lastSeen())
+
+	// revoke device A, device B can still refresh
+	if err := uts.service.Session.RevokeMe(uts.ctx, claims.SessionID); err != nil {
+		uts.T().Fatal(err)
+	}
+	_, err = uts.service.AuthAdmin.Refresh(uts.ctx, loginA.RefreshToken)
+	uts.Error(err)
+	countRefreshLogs := func() int64 {
+		var count int64
+		if err := uts.server.DB.Model(&domain.Logs[domain.Auth]{}).Where("action = ? AND model->>'user_id' = ?", domain.RefreshTokenLog, claims.UserID).Count(&count).Error; err != nil {
+			uts.T().Fatal(err)
+		}
+		return count
+	}
+	refreshLogs := countRefreshLogs()
+	_, err = uts.service.AuthAdmin.Refresh(uts.ctx, loginB.RefreshToken)
+	uts.NoError(err)
+	uts.Equal(refreshLogs+1, countRefreshLogs())
+
+	// the revoke is logged on the session
+	var revokeLogs int64
+	if err := uts.server.DB.Model(&domain.Logs[domain.Session]{}).Where("action = ? AND record_id = ?", domain.RevokeSessionLog, claims.SessionID).Count(&revokeLogs).Error; err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(int64(1), revokeLogs)
+}
+
+func (uts *UnitTestSuite) TestStaffService_Logout() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	err = uts.server.Storages.Cache.DeleteStrikes(uts.ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, "unlocktest1@admin.com"))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	login := func() (*domain.AuthResult, domain.AuthClaims) {
+		result, err := uts.service.Staff.LoginWithEmailPassword(uts.ctx, domain.StaffLogin{
+			Email:    "unlocktest1@admin.com",
+			Password: successPass,
+			DeviceID: uuid.NewString(),
+		})
+		if err != nil {
+			uts.T().Fatal(err)
+		}
+		var claims domain.AuthClaims
+		if _, err := domain.ParseToken(result.AccessToken, &claims, uts.server.Cfg.AdminAuth.JWTSecret); err != nil {
+			uts.T().Fatal(err)
+		}
+		return result, claims
+	}
+
+	// logout current session only
+	first, claims := login()
+	second, _ := login()
+	uts.ctx.Set(string(domain.UserIDKey), claims.UserID)
+	uts.ctx.Set(string(domain.SessionIDKey), claims.SessionID)
+	if err := uts.service.AuthAdmin.Logout(uts.ctx); err != nil {
+		uts.T().Fatal(err)
+	}
+	_, err = uts.service.AuthAdmin.Refresh(uts.ctx, first.RefreshToken)
+	uts.Error(err)
+	second, err = uts.service.AuthAdmin.Refresh(uts.ctx, second.RefreshToken)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// force logout revoke every session
+	if err := uts.service.AuthAdmin.ForceLogout(uts.ctx, claims.UserID); err != nil {
+		uts.T().Fatal(err)
+	}
+	_, err = uts.service.AuthAdmin.Refresh(uts.ctx, second.RefreshToken)
+	uts.Error(err)
+}
+
+func (uts *UnitTestSuite) TestStaffService_SecureLogin_OTP() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	err = uts.server.Storages.Cache.DeleteStrikes(uts.ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, "unlocktest1@admin.com"))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	_, err = uts.service.AuthAdmin.SecureLogin(uts.ctx, domain.LoginCredentials{
+		Email:    "unlocktest1@admin.com",
+		Password: failedPass,
+	})
+	uts.Error(err)
+
+	ref, err := uts.service.AuthAdmin.SecureLogin(uts.ctx, domain.LoginCredentials{
+		Email:    "unlocktest1@admin.com",
+		Password: successPass,
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEmpty(ref.ReferenceCode)
+
+	_, err = uts.service.AuthAdmin.VerifyOTP(uts.ctx, domain.VerifyEmailOTP{
+		Email:         "unlocktest1@admin.com",
+		ReferenceCode: ref.ReferenceCode,
+		OTP:           "000000x",
+	})
+	uts.Error(err)
+
+	otp := uts.pendingOTP(ref.ReferenceCode)
+	result, err := uts.service.AuthAdmin.VerifyOTP(uts.ctx, domain.VerifyEmailOTP{
+		Email:         "unlocktest1@admin.com",
+		ReferenceCode: ref.ReferenceCode,
+		OTP:           otp,
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEmpty(result.AccessToken)
+
+	// reference code can be used only once
+	_, err = uts.service.AuthAdmin.VerifyOTP(uts.ctx, domain.VerifyEmailOTP{
+		Email:         "unlocktest1@admin.com",
+		ReferenceCode: ref.ReferenceCode,
+		OTP:           otp,
+	})
+	uts.Error(err)
+}
+
+func (uts *UnitTestSuite) TestStaffService_SecureLogin_OTPLimits() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	cfg := uts.server.Cfg.AdminAuth
+	uts.Require().Positive(cfg.VerifyOTPMaxAttempts)
+	uts.Require().Positive(cfg.ResendOTPMaxAttempts)
+	creds := domain.LoginCredentials{Email: "unlocktest1@admin.com", Password: successPass}
+	email := creds.Email.String()
+	resetLimits := func() {
+		for _, key := range []string{auth.AdminOTPKeys.VerifyLocked(email), auth.AdminOTPKeys.Resend(email), auth.AdminOTPKeys.ResendLocked(email)} {
+			if err := uts.server.Redis.ClearCache(uts.ctx.Request().Context(), key); err != nil {
+				uts.T().Fatal(err)
+			}
+		}
+	}
+	resetLimits()
+	defer resetLimits()
+	if err := uts.service.Staff.Unlock(uts.ctx, domain.StaffUnlock{Email: email}); err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// wrong otp until the limit, then the right otp is refused too
+	ref, err := uts.service.AuthAdmin.SecureLogin(uts.ctx, creds)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	otp := uts.pendingOTP(ref.ReferenceCode)
+	verify := domain.VerifyEmailOTP{Email: creds.Email, ReferenceCode: ref.ReferenceCode, OTP: "000000"}
+	if otp == verify.OTP {
+		verify.OTP = "111111"
+	}
+	for i := 1; i < cfg.VerifyOTPMaxAttempts; i++ {
+		_, err = uts.service.AuthAdmin.VerifyOTP(uts.ctx, verify)
+		if uts.Error(err) {
+			uts.Equal(xerror.ErrInvalidOTP, err.(*xerror.Xerror).ErrCode)
+		}
+	}
+	_, err = uts.service.AuthAdmin.VerifyOTP(uts.ctx, verify)
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrOTPVerifyReachLimit, err.(*xerror.Xerror).ErrCode)
+	}
+	verify.OTP = otp
+	_, err = uts.service.AuthAdmin.VerifyOTP(uts.ctx, verify)
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrOTPVerifyReachLimit, err.(*xerror.Xerror).ErrCode)
+	}
+	resetLimits()
+
+	// resend until the limit, every resend replace the otp
+	ref, err = uts.service.AuthAdmin.SecureLogin(uts.ctx, creds)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	for i := 0; i < cfg.ResendOTPMaxAttempts; i++ {
+		resent, err := uts.service.AuthAdmin.ResendOTP(uts.ctx, domain.UsersEmail{Email: creds.Email})
+		if err != nil {
+			uts.T().Fatal(err)
+		}
+		uts.NotEqual(ref.ReferenceCode, resent.ReferenceCode)
+		ref = resent
+	}
+	_, err = uts.service.AuthAdmin.ResendOTP(uts.ctx, domain.UsersEmail{Email: creds.Email})
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrOTPResendReachLimit, err.(*xerror.Xerror).ErrCode)
+	}
+	_, err = uts.service.AuthAdmin.ResendOTP(uts.ctx, domain.UsersEmail{Email: creds.Email})
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrOTPResendReachLimit, err.(*xerror.Xerror).ErrCode)
+	}
+
+	// the pending otp of the last resend still works
+	result, err := uts.service.AuthAdmin.VerifyOTP(uts.ctx, domain.VerifyEmailOTP{Email: creds.Email, ReferenceCode: ref.ReferenceCode, OTP: uts.pendingOTP(ref.ReferenceCode)})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEmpty(result.AccessToken)
+}
+
+func (uts *UnitTestSuite) TestStaffService_TwoFactor() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	err = uts.server.Storages.Cache.DeleteStrikes(uts.ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, "unlocktest1@admin.com"))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	login := domain.StaffLogin{Email: "unlocktest1@admin.com", Password: successPass}
+	staff, err := uts.service.Staff.GetByEmail(uts.ctx, login.Email)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	enrollment, err := uts.service.TwoFactor.Enroll(uts.ctx, login)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Len(enrollment.RecoveryCodes, domain.TwoFactorRecoveryCodeCount)
+	code, err := hash.TOTPCode(enrollment.Secret, time.Now())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.service.TwoFactor.Enable(uts.ctx, domain.TwoFactorEnable{Email: login.Email, Password: login.Password, Code: code}); err != nil {
+		uts.T().Fatal(err)
+	}
+	defer uts.server.Stores.TwoFactor.DeleteByUserID(uts.ctx, &domain.TwoFactor{UserID: staff.ID})
+
+	// password only is not enough
+	_, err = uts.service.Staff.LoginWithEmailPassword(uts.ctx, login)
+	uts.Error(err)
+
+	login.TOTPCode = code
+	_, err = uts.service.Staff.LoginWithEmailPassword(uts.ctx, login)
+	uts.NoError(err)
+
+	// recovery code can be used only once
+	login.TOTPCode = enrollment.RecoveryCodes[0]
+	_, err = uts.service.Staff.LoginWithEmailPassword(uts.ctx, login)
+	uts.NoError(err)
+	_, err = uts.service.Staff.LoginWithEmailPassword(uts.ctx, login)
+	uts.Error(err)
+}
+
+func (uts *UnitTestSuite) TestTwoFactorService_VerifyConcurrent() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	login := domain.StaffLogin{Email: "unlocktest1@admin.com", Password: successPass}
+	staff, err := uts.service.Staff.GetByEmail(uts.ctx, login.Email)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	enrollment, err := uts.service.TwoFactor.Enroll(uts.ctx, login)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	code, err := hash.TOTPCode(enrollment.Secret, time.Now())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.service.TwoFactor.Enable(uts.ctx, domain.TwoFactorEnable{Email: login.Email, Password: login.Password, Code: code}); err != nil {
+		uts.T().Fatal(err)
+	}
+	defer uts.server.Stores.TwoFactor.DeleteByUserID(uts.ctx, &domain.TwoFactor{UserID: staff.ID})
+
+	// the same code sent by concurrent logins is accepted only once
+	const total = 10
+	var (
+		wg       sync.WaitGroup
+		accepted atomic.Int32
+	)
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := uts.service.TwoFactor.Verify(*MockEchoContext(), staff.ID, code); err == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	uts.EqualValues(1, accepted.Load())
+}
+
+func (uts *UnitTestSuite) TestStaffService_SecureLogin_TwoFactor() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	err = uts.server.Storages.Cache.DeleteStrikes(uts.ctx.Request().Context(), fmt.Sprintf(domain.StaffAuthCache, "unlocktest1@admin.com"))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	login := domain.StaffLogin{Email: "unlocktest1@admin.com", Password: successPass}
+	staff, err := uts.service.Staff.GetByEmail(uts.ctx, login.Email)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	enrollment, err := uts.service.TwoFactor.Enroll(uts.ctx, login)
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	code, err := hash.TOTPCode(enrollment.Secret, time.Now())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.service.TwoFactor.Enable(uts.ctx, domain.TwoFactorEnable{Email: login.Email, Password: login.Password, Code: code}); err != nil {
+		uts.T().Fatal(err)
+	}
+	defer uts.server.Stores.TwoFactor.DeleteByUserID(uts.ctx, &domain.TwoFactor{UserID: staff.ID})
+
+	// the email otp does not replace the totp of the enrolled staff
+	ref, err := uts.service.AuthAdmin.SecureLogin(uts.ctx, domain.LoginCredentials{Email: login.Email, Password: login.Password})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	_, err = uts.service.AuthAdmin.VerifyOTP(uts.ctx, domain.VerifyEmailOTP{
+		Email:         login.Email,
+		ReferenceCode: ref.ReferenceCode,
+		OTP:           uts.pendingOTP(ref.ReferenceCode),
+	})
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrTwoFactorRequired, err.(*xerror.Xerror).ErrCode)
+	}
+
+	ref, err = uts.service.AuthAdmin.SecureLogin(uts.ctx, domain.LoginCredentials{Email: login.Email, Password: login.Password})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	result, err := uts.service.AuthAdmin.VerifyOTP(uts.ctx, domain.VerifyEmailOTP{
+		Email:         login.Email,
+		ReferenceCode: ref.ReferenceCode,
+		OTP:           uts.pendingOTP(ref.ReferenceCode),
+		TOTPCode:      enrollment.RecoveryCodes[0],
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NotEmpty(result.AccessToken)
+}
+
+// pendingOTP read the otp of the reference code from redis, ReturnOTP may be off
+func (uts *UnitTestSuite) pendingOTP(ref string) string {
+	raw, err := uts.server.Redis.GetCache(uts.ctx.Request().Context(), auth.AdminOTPKeys.Verify(ref))
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	var session struct {
+		OTP string `json:"otp"`
+	}
+	if err := json.Unmarshal(raw, &session); err != nil {
+		uts.T().Fatal(err)
+	}
+	return session.OTP
+}
+
+func (uts *UnitTestSuite) TestStaffService_ResetPassword() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	staff, err := uts.service.Staff.GetByEmail(uts.ctx, "unlocktest1@admin.com")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// unknown email is not reported
+	uts.NoError(uts.service.Staff.ForgotPassword(uts.ctx, domain.PasswordForgot{Email: "unknown@admin.com"}))
+	uts.NoError(uts.service.Staff.ForgotPassword(uts.ctx, domain.PasswordForgot{Email: staff.Email}))
+
+	cfg := auth.AuthConfig(uts.server.Cfg.AdminAuth)
+	token, err := auth.IssuePasswordResetToken(uts.ctx, &cfg, uts.server.Redis, domain.StaffPasswordResetCache, staff.ID.String())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.NoError(uts.service.Staff.ResetPassword(uts.ctx, domain.PasswordReset{Token: token, Password: newPass}))
+
+	// token can be used once
+	uts.Error(uts.service.Staff.ResetPassword(uts.ctx, domain.PasswordReset{Token: token, Password: newPass}))
+	uts.Error(uts.service.Staff.ResetPassword(uts.ctx, domain.PasswordReset{Token: "invalid", Password: newPass}))
+
+	_, err = uts.service.Staff.LoginWithEmailPassword(uts.ctx, domain.StaffLogin{Email: staff.Email, Password: newPass})
+	uts.NoError(err)
+}
+
+func (uts *UnitTestSuite) TestStaffService_PasswordPolicy() {
+	storage.Migration[domain.Role](uts.server.DB, "roles.json")
+	err := storage.Seed[domain.StaffMigration](uts.server.DB, "staffs_unlock.json")
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	email := domain.SensitiveString("unlocktest1@admin.com")
+
+	// common and space padded passwords are field errors
+	for _, password := range []string{"password", " " + newPass} {
+		err := uts.service.Staff.ChangePassword(uts.ctx, domain.PasswordChange{Email: email, OldPassword: successPass, Password: password})
+		uts.Error(err)
+		uts.Contains(err.Error(), "password")
+	}
+	// wrong old password
+	uts.Error(uts.service.Staff.ChangePassword(uts.ctx, domain.PasswordChange{Email: email, OldPassword: failedPass, Password: newPass}))
+
+	uts.NoError(uts.service.Staff.ChangePassword(uts.ctx, domain.PasswordChange{Email: email, OldPassword: successPass, Password: newPass}))
+	_, err = uts.service.Staff.LoginWithEmailPassword(uts.ctx, domain.StaffLogin{Email: email, Password: newPass})
+	uts.NoError(err)
+
+	// the current password can't be reused when history is enabled
+	cfg := auth.AuthConfig(uts.server.Cfg.AdminAuth)
+	if cfg.PasswordHistory > 0 {
+		uts.Error(uts.service.Staff.ChangePassword(uts.ctx, domain.PasswordChange{Email: email, OldPassword: newPass, Password: newPass}))
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"go_base/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TokenKeyService struct {
+	admin *domain.KeySet
+	user  *domain.KeySet
+}
+
+func NewTokenKeyService(admin, user *domain.KeySet) *TokenKeyService {
+	return &TokenKeyService{admin: admin, user: user}
+}
+
+func (s *TokenKeyService) Admin() *domain.KeySet {
+	return s.admin
+}
+
+func (s *TokenKeyService) User() *domain.KeySet {
+	return s.user
+}
+
+// GET /.well-known/jwks.json
+func (s *TokenKeyService) JWKS(ctx echo.Context) (*domain.JWKS, error) {
+	return &domain.JWKS{Keys: append(s.admin.JWKs(), s.user.JWKs()...)}, nil
+}
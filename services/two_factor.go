@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_base/database"
+	"go_base/domain"
+	"go_base/hash"
+	"go_base/storage"
+	"go_base/xerror"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// totp code can be used only once in the valid window
+	twoFactorUsedCacheKey = "two_factor:used:%s:%s" // user id, code
+)
+
+type TwoFactorService struct {
+	store          *database.Store
+	services       *domain.AllServices
+	cache          *storage.Cache
+	twoFactorStore *database.TwoFactorStore
+}
+
+func NewTwoFactorService(store *database.Store, twoFactor *database.TwoFactorStore, services *domain.AllServices, cache *storage.Cache) *TwoFactorService {
+	return &TwoFactorService{store: store, services: services, twoFactorStore: twoFactor, cache: cache}
+}
+
+// POST /staffs/2fa/enroll
+func (s *TwoFactorService) Enroll(ctx echo.Context, login domain.StaffLogin) (*domain.TwoFactorEnrollment, error) {
+	staff, err := s.services.Staff.Authenticate(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if enabled, err := s.IsEnabled(ctx, staff.ID); err != nil {
+		return nil, err
+	} else if enabled {
+		return nil, xerror.EConflict(nil).SetMessage("two factor already enabled")
+	}
+
+	secret, err := hash.GenerateTOTPSecret()
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+	codes := make([]string, domain.TwoFactorRecoveryCodeCount)
+	hashed := make([]string, domain.TwoFactorRecoveryCodeCount)
+	for i := range codes {
+		codes[i] = hash.GenerateRandomString(domain.TwoFactorRecoveryCodeLen, hash.CharsetV1)
+		if hashed[i], err = hash.HashBcrypt(codes[i]); err != nil {
+			return nil, xerror.E(err)
+		}
+	}
+	recoveryCodes, err := json.Marshal(hashed)
+	if err != nil {
+		return nil, xerror.E(err)
+	}
+
+	if err := s.twoFactorStore.Replace(ctx, &domain.TwoFactor{
+		UserID:        staff.ID,
+		Secret:        secret,
+		RecoveryCodes: recoveryCodes,
+	}); err != nil {
+		return nil, err
+	}
+	return &domain.TwoFactorEnrollment{
+		Secret:        secret,
+		URI:           hash.TOTPURI(domain.TwoFactorIssuer, staff.Email.String(), secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// POST /staffs/2fa/enable
+func (s *TwoFactorService) Enable(ctx echo.Context, req domain.TwoFactorEnable) error {
+	staff, err := s.services.Staff.Authenticate(ctx, domain.StaffLogin{Email: req.Email, Password: req.Password})
+	if err != nil {
+		return err
+	}
+	twoFactor, err := s.twoFactorStore.GetByUserID(ctx, staff.ID)
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return errInvalidTwoFactorCode()
+		}
+		return err
+	}
+	if twoFactor.Enabled() {
+		return xerror.EConflict(nil).SetMessage("two factor already enabled")
+	}
+	if !hash.ValidateTOTP(twoFactor.Secret, req.Code, time.Now()) {
+		return errInvalidTwoFactorCode()
+	}
+	return s.twoFactorStore.Enable(ctx, twoFactor)
+}
+
+// DELETE /me/2fa
+func (s *TwoFactorService) Disable(ctx echo.Context, req domain.TwoFactorCode) error {
+	staff := domain.StaffFromContext(ctx)
+	if staff == nil {
+		return xerror.EUnAuthorized()
+	}
+	if err := s.Verify(ctx, staff.ID, req.Code); err != nil {
+		return err
+	}
+	return s.twoFactorStore.DeleteByUserID(ctx, &domain.TwoFactor{UserID: staff.ID})
+}
+
+func (s *TwoFactorService) IsEnabled(ctx echo.Context, userID uuid.UUID) (bool, error) {
+	twoFactor, err := s.twoFactorStore.GetByUserID(ctx, userID)
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return twoFactor.Enabled(), nil
+}
+
+// Verify totp code, or recovery code which is removed after used
+func (s *TwoFactorService) Verify(ctx echo.Context, userID uuid.UUID, code string) error {
+	twoFactor, err := s.twoFactorStore.GetByUserID(ctx, userID)
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return errInvalidTwoFactorCode()
+		}
+		return err
+	}
+	if !twoFactor.Enabled() {
+		return errInvalidTwoFactorCode()
+	}
+
+	if hash.ValidateTOTP(twoFactor.Secret, code, time.Now()) {
+		// claim the code atomically, a code is used once within its window
+		window := hash.TOTPPeriod * time.Duration(2*hash.TOTPSkew+1)
+		claimed, err := s.cache.SetCacheNX(ctx.Request().Context(), fmt.Sprintf(twoFactorUsedCacheKey, userID, code), "1", window)
+		if err != nil {
+			return xerror.E(err)
+		}
+		if !claimed {
+			return errInvalidTwoFactorCode()
+		}
+		return nil
+	}
+
+	var hashed []string
+	if err := json.Unmarshal(twoFactor.RecoveryCodes, &hashed); err != nil {
+		return xerror.E(err)
+	}
+	for i, h := range hashed {
+		if !hash.CompareBcrypt(h, code) {
+			continue
+		}
+		remaining, err := json.Marshal(append(hashed[:i:i], hashed[i+1:]...))
+		if err != nil {
+			return xerror.E(err)
+		}
+		return s.twoFactorStore.UpdateRecoveryCodes(ctx, twoFactor, remaining)
+	}
+	return errInvalidTwoFactorCode()
+}
+
+func errInvalidTwoFactorCode() *xerror.Xerror {
+	return xerror.E(xerror.ErrUnauthorized).SetErrorCode(xerror.ErrInvalidTwoFactorCode).
+		SetStatusCode(xerror.ErrCodeUnauthorized)
+}
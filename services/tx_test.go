@@ -0,0 +1,82 @@
+package services_test
+
+import (
+	"errors"
+	"go_base/domain"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (uts *UnitTestSuite) TestStore_WithTx() {
+	errRollback := errors.New("rollback")
+	newOrganization := func() *domain.Organization {
+		return &domain.Organization{Name: "Tx " + uuid.NewString()[:8]}
+	}
+	exists := func(org *domain.Organization) bool {
+		_, err := uts.server.Stores.Org.GetByID(uts.ctx, org.ID.String())
+		return err == nil
+	}
+
+	// the error of fn roll back every store called with its ctx
+	outer, inner := newOrganization(), newOrganization()
+	err := uts.server.Stores.Base.WithTx(uts.ctx, func(ctx echo.Context) error {
+		if err := uts.server.Stores.Org.Create(ctx, outer); err != nil {
+			return err
+		}
+		return uts.server.Stores.Org.WithTx(ctx, func(ctx echo.Context) error {
+			if err := uts.server.Stores.Org.Create(ctx, inner); err != nil {
+				return err
+			}
+			return errRollback
+		})
+	})
+	uts.ErrorIs(err, errRollback)
+	uts.False(exists(outer))
+	uts.False(exists(inner))
+
+	// a nested WithTx is a savepoint, its handled error keep the changes of the caller
+	outer, inner = newOrganization(), newOrganization()
+	err = uts.server.Stores.Base.WithTx(uts.ctx, func(ctx echo.Context) error {
+		if err := uts.server.Stores.Org.Create(ctx, outer); err != nil {
+			return err
+		}
+		_ = uts.server.Stores.Base.WithTx(ctx, func(ctx echo.Context) error {
+			if err := uts.server.Stores.Org.Create(ctx, inner); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		return nil
+	})
+	uts.NoError(err)
+	uts.True(exists(outer))
+	uts.False(exists(inner))
+}
+
+func (uts *UnitTestSuite) TestStore_Changelog() {
+	countLogs := func(org *domain.Organization) int64 {
+		var count int64
+		if err := uts.server.DB.Model(&domain.Logs[domain.Organization]{}).Where("model->>'id' = ?", org.ID.String()).Count(&count).Error; err != nil {
+			uts.T().Fatal(err)
+		}
+		return count
+	}
+
+	// the log is committed with the change, before the store return
+	org := &domain.Organization{Name: "Log " + uuid.NewString()[:8]}
+	if err := uts.server.Stores.Org.Create(uts.ctx, org); err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(int64(1), countLogs(org))
+
+	// the log of a rolled back change is rolled back too
+	rolledBack := &domain.Organization{Name: "Log " + uuid.NewString()[:8]}
+	_ = uts.server.Stores.Base.WithTx(uts.ctx, func(ctx echo.Context) error {
+		if err := uts.server.Stores.Org.Create(ctx, rolledBack); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	uts.Zero(countLogs(rolledBack))
+}
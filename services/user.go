@@ -6,12 +6,16 @@ import (
 	"go_base/database"
 	"go_base/domain"
 	"go_base/hash"
+	"go_base/logger"
 	"go_base/services/auth"
 	"go_base/storage"
+	"go_base/validate"
 	"go_base/xerror"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 	"gorm.io/gorm"
 )
 
@@ -21,15 +25,27 @@ type UserService struct {
 	userStore database.UserStore
 	cache     *storage.Cache
 	cfg       *auth.AuthConfig
+	lockout   *auth.Lockout
 }
 
 func NewUserService(store *database.Store, user *database.UserStore, services *domain.AllServices, cache *storage.Cache, cfg *auth.AuthConfig) *UserService {
-	return &UserService{store: store, services: services, userStore: *user, cache: cache, cfg: cfg}
+	s := &UserService{store: store, services: services, userStore: *user, cache: cache, cfg: cfg}
+	s.lockout = auth.NewLockout(cache, auth.LockoutKeys{
+		Strikes: domain.UserAuthCache,
+		Locked:  domain.UserLockedCache,
+		IP:      domain.UserIPCache,
+	}, cfg, s.notifyLockout)
+	return s
 }
 
 // GET /users/:id
 func (s *UserService) Get(ctx echo.Context, id string) (*domain.User, error) {
-	return s.userStore.GetByID(ctx, id)
+	user, err := s.userStore.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	redactFields(ctx, s.services, user)
+	return user, nil
 }
 
 // GET /users
@@ -59,6 +75,7 @@ func (s *UserService) Find(ctx echo.Context, pagination domain.Pagination[domain
 		"interest": metaCountInterest,
 		"tag":      metaCountTag,
 	}
+	redactFields(ctx, s.services, pg)
 
 	return pg, nil
 }
@@ -70,7 +87,7 @@ func (s *UserService) Create(ctx echo.Context, userCreate domain.UserCreate) (*d
 	if err != nil {
 		return nil, err
 	}
-	ran := hash.GenerateRandomString(s.cfg.LenTempPwd)
+	ran := s.cfg.PasswordPolicy().Generate(s.cfg.LenTempPwd)
 
 	user := domain.User{
 		Email:       domain.SensitiveString(userCreate.Email),
@@ -81,66 +98,131 @@ func (s *UserService) Create(ctx echo.Context, userCreate domain.UserCreate) (*d
 		TmpPassword: ran,
 	}
 
-	if err := s.userStore.Create(ctx, &user); err != nil {
+	// the soft deleted user of the email is replaced, it is only deleted when the new user is created
+	if err := s.store.WithTx(ctx, func(ctx echo.Context) error {
+		// savepoint, the failed insert must not abort the transaction
+		err := s.store.WithTx(ctx, func(ctx echo.Context) error {
+			return s.userStore.Create(ctx, &user)
+		})
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			if err := s.userStore.DeleteExistData(ctx, &user); err != nil {
-				return nil, err
-			} else {
-				if err := s.userStore.Create(ctx, &user); err != nil {
-					return nil, err
-				}
-				return nil, nil
+				return err
 			}
+			// BeforeCreate of the failed insert already hashed the password
+			user.Password = domain.Password(ran)
+			err = s.userStore.Create(ctx, &user)
+		}
+		if err != nil {
+			return err
 		}
+		return s.services.Password.Record(ctx, s.cfg.PasswordPolicy(), user.ID, user.Password)
+	}); err != nil {
 		return nil, err
 	}
 
-	// update role default
-
+	if err := s.sendVerification(ctx, &user); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
+// sendVerification email the temporary password and the verify link
+func (s *UserService) sendVerification(ctx echo.Context, user *domain.User) error {
+	claims, err := domain.ParseVerifyToken(user.VerifyToken, s.cfg.JWTSecret)
+	if err != nil {
+		return err
+	}
+	return s.services.Mail.SendVerification(ctx, user.Email.String(), domain.MailToken{
+		Name:        user.FirstName,
+		TmpPassword: user.TmpPassword,
+		Token:       user.VerifyToken,
+		Path:        domain.UserVerifyPath,
+		ExpireAt:    claims.ExpiresAt.Time,
+	})
+}
+
 // POST /users/login
 func (s *UserService) LoginWithEmailPassword(ctx echo.Context, login domain.UserLogin) (*domain.AuthResult, error) {
-	s.cache.DeleteStrikes(ctx.Request().Context(), fmt.Sprintf(domain.UserAuthCache, login.Email))
-	if i, e := s.cache.GetStrikes(ctx.Request().Context(), fmt.Sprintf(domain.UserAuthCache, login.Email)); e == nil {
-		if i >= s.cfg.AccountLockoutMaxAttempts {
-			s.userStore.WriteLog(ctx, &domain.User{Email: domain.SensitiveString(login.Email)}, string(xerror.ErrCodeTooManyLoginAttempts))
-			return nil, xerror.EForbidden().SetExtraInfo("reason", xerror.ErrCodeTooManyLoginAttempts)
-		}
+	user, err := s.Authenticate(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	return s.CompleteLogin(ctx, user, login.DeviceID)
+}
+
+// Authenticate check email and password, wrong credentials increase the lockout strike
+// and the expired password must be changed with /password/change before login
+func (s *UserService) Authenticate(ctx echo.Context, login domain.UserLogin) (*domain.User, error) {
+	user, err := s.verifyCredentials(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.PasswordPolicy().Expired(lo.FromPtrOr(user.PasswordChangedAt, user.CreatedAt)) {
+		return nil, xerror.EForbidden().SetErrorCode(xerror.ErrPasswordExpired)
+	}
+	return user, nil
+}
+
+func (s *UserService) verifyCredentials(ctx echo.Context, login domain.UserLogin) (*domain.User, error) {
+	if err := s.lockout.Check(ctx, login.Email.String()); err != nil {
+		s.userStore.WriteLog(ctx, &domain.User{Email: login.Email}, string(xerror.ErrCodeTooManyLoginAttempts))
+		return nil, err
 	}
 	user, err := s.userStore.GetByKey(ctx, "email", login.Email.String())
 	if err != nil {
-		return s.userIncrementStrike(ctx, login)
+		return nil, s.userIncrementStrike(ctx, login)
 	}
 	password := string(user.Password)
 	if !user.IsVerified {
-		return s.userIncrementStrike(ctx, login)
+		return nil, s.userIncrementStrike(ctx, login)
+	}
+	if ok := hash.ComparePassword(password, login.Password); !ok {
+		return nil, s.userIncrementStrike(ctx, login)
 	}
-	if ok := hash.CompareBcrypt(password, login.Password); !ok {
-		return s.userIncrementStrike(ctx, login)
+	if err := s.lockout.Reset(ctx, login.Email.String()); err != nil {
+		return nil, err
+	}
+	s.rehashPassword(ctx, user, login.Password)
+	return user, nil
+}
+
+// rehashPassword legacy hash (bcrypt) is replaced by the configured hasher after login, failure only log
+func (s *UserService) rehashPassword(ctx echo.Context, user *domain.User, password string) {
+	if !user.Password.NeedsRehash() {
+		return
 	}
-	// login success
+	if err := s.userStore.Update(ctx, &domain.User{
+		BaseModel: domain.BaseModel{ID: user.ID},
+		Password:  domain.Password(password).Hash(),
+	}, domain.RehashPasswordLog); err != nil {
+		logger.Ctx(ctx.Request().Context()).Warnw("rehash password failed", "error", err)
+	}
+}
+
+// CompleteLogin create the session of the device and issue tokens
+func (s *UserService) CompleteLogin(ctx echo.Context, user *domain.User, deviceID string) (*domain.AuthResult, error) {
 	if err := s.userStore.Update(ctx, &domain.User{
 		BaseModel: domain.BaseModel{ID: user.ID},
 		LastLogin: domain.TimeNowPtr(),
 	}, domain.LoginLog); err != nil {
 		return nil, err
 	}
-	if err := s.cache.DeleteStrikes(ctx.Request().Context(), fmt.Sprintf(domain.UserAuthCache, login.Email)); err != nil {
+	session, err := s.services.Session.Create(ctx, user.ID, deviceID)
+	if err != nil {
 		return nil, err
 	}
-	auth, err := auth.IssueAccessRefreshToken(ctx, user.ID, s.cfg, s.services.AuthUser.FindAuth, s.services.AuthUser.UpdateAuth, s.services.AuthUser.CreateAuth, s.cache.SetCache)
+	auth, err := auth.IssueAccessRefreshToken(ctx, user.ID, session.ID, s.cfg, s.services.TokenKey.User(), s.services.AuthUser.FindAuth, s.services.AuthUser.UpdateAuth, s.services.AuthUser.CreateAuth, s.cache.SetCache)
 	if err != nil {
 		return nil, err
 	}
+	auth.DeviceID = session.DeviceID
 	return auth, nil
 }
 
 // POST /users/unlock
 func (s *UserService) Unlock(ctx echo.Context, unlock domain.UserUnlock) error {
 
-	err := s.cache.DeleteStrikes(ctx.Request().Context(), fmt.Sprintf(domain.UserAuthCache, unlock.Email))
+	err := s.lockout.Reset(ctx, unlock.Email)
 	if err != nil {
 		return err
 	}
@@ -185,6 +267,12 @@ func (s *UserService) Update(ctx echo.Context, userUpdate domain.UserUpdate) (*d
 		return nil, xerror.EInvalidParameter(nil)
 	}
 	userUpdate.ID = uid
+	if err := checkFieldWrites(ctx, s.services, &userUpdate); err != nil {
+		return nil, err
+	}
+	if err := s.checkStaffScope(ctx, userUpdate.StaffID); err != nil {
+		return nil, err
+	}
 
 	if err := s.userStore.UpdateU(ctx, &userUpdate); err != nil {
 		return nil, err
@@ -192,6 +280,38 @@ func (s *UserService) Update(ctx echo.Context, userUpdate domain.UserUpdate) (*d
 	return &userUpdate, nil
 }
 
+/*
+checkStaffScope the staff granted on a scope can't give the user away
+
+	organization scope: only to a staff of the organization
+	own scope: only to the staff itself
+*/
+func (s *UserService) checkStaffScope(ctx echo.Context, staffID *string) error {
+	grant := domain.ScopeFromContext(ctx)
+	if grant == nil || staffID == nil {
+		return nil
+	}
+	current := domain.StaffFromContext(ctx)
+	if current == nil {
+		return xerror.EForbidden().SetDebugInfo("msg", "scope without staff")
+	}
+	switch grant.Scope {
+	case domain.PermissionScopeOwn:
+		if *staffID != current.ID.String() {
+			return xerror.EForbidden().SetDebugInfo("msg", "own scope")
+		}
+	case domain.PermissionScopeOrg:
+		staff, err := s.services.Staff.Get(ctx, *staffID)
+		if err != nil {
+			return err
+		}
+		if current.OrganizationID == nil || staff.OrganizationID == nil || *staff.OrganizationID != *current.OrganizationID {
+			return xerror.EForbidden().SetDebugInfo("msg", "organization scope")
+		}
+	}
+	return nil
+}
+
 // Get log me delete /users/log
 func (s *UserService) GetLogMe(ctx echo.Context) (*domain.Pagination[*domain.Logs[domain.User]], error) {
 	user := domain.UserFromContext(ctx)
@@ -213,10 +333,19 @@ func (s *UserService) GetLog(ctx echo.Context, user domain.UserGetLog) (*domain.
 	return result, nil
 }
 
+// GET /users/:id/history
+func (s *UserService) History(ctx echo.Context, id uuid.UUID) (*domain.Pagination[domain.LogHistory], error) {
+	logs, err := s.userStore.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return logHistory(ctx, s.services, logs)
+}
+
 // Mock IsVerified /users/verify
 func (s *UserService) Verify(ctx echo.Context, user domain.UserVerifyToken) (*domain.UserVerifyTokenResponse, error) {
 	if _user, err := s.userStore.GetByKey(ctx, string(domain.UserVerifyTokenType), user.Token); err == nil {
-		if err := domain.ParseVerifyToken(user.Token); err == nil {
+		if _, err := domain.ParseVerifyToken(user.Token, s.cfg.JWTSecret); err == nil {
 
 			if err := s.userStore.UpdateTokenVerify(ctx, _user.ID); err != nil {
 				return nil, err
@@ -243,7 +372,7 @@ func (s *UserService) GetToken(ctx echo.Context, user domain.UserGetToken) (*dom
 		if _user.IsVerified {
 			return nil, xerror.EInvalidInputOk()
 		}
-		if ok := hash.CompareBcrypt(string(_user.Password), user.TmpPassword); ok {
+		if ok := hash.ComparePassword(string(_user.Password), user.TmpPassword); ok {
 			return &domain.UserGetTokenResponse{
 				Token: _user.VerifyToken,
 			}, nil
@@ -256,14 +385,8 @@ func (s *UserService) GetToken(ctx echo.Context, user domain.UserGetToken) (*dom
 func (s *UserService) UpdatePassword(ctx echo.Context, user domain.UserUpdatePassword) error {
 	if _user, err := s.userStore.GetByKey(ctx, "email", user.Email.String()); err == nil {
 		if _user.IsVerified {
-			if ok := hash.CompareBcrypt(string(_user.Password), user.OldPassword); ok {
-				if err := s.userStore.Update(ctx, &domain.User{
-					BaseModel: domain.BaseModel{ID: _user.ID},
-					Password:  domain.Password(user.Password).Hash(),
-				}, domain.ChangePasswordLog); err != nil {
-					return err
-				}
-				return nil
+			if ok := hash.ComparePassword(string(_user.Password), user.OldPassword); ok {
+				return s.setPassword(ctx, _user, user.Password, domain.ChangePasswordLog)
 			}
 		}
 	}
@@ -271,13 +394,102 @@ func (s *UserService) UpdatePassword(ctx echo.Context, user domain.UserUpdatePas
 	return xerror.EInvalidInputOk()
 }
 
-func (s *UserService) userIncrementStrike(ctx echo.Context, login domain.UserLogin) (*domain.AuthResult, error) {
-	s.userStore.WriteLog(ctx, &domain.User{Email: domain.SensitiveString(login.Email)}, domain.LoginFail)
-	increaseStrike, e := s.cache.IncreaseStrike(ctx.Request().Context(), fmt.Sprintf(domain.UserAuthCache, login.Email))
-	if e != nil {
-		return nil, e
+// POST /users/password/forgot, always success so the email can't be enumerated
+func (s *UserService) ForgotPassword(ctx echo.Context, req domain.PasswordForgot) error {
+	user, err := s.userStore.GetByKey(ctx, "email", req.Email.String())
+	if err != nil {
+		if xerror.IsNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsVerified {
+		return nil
+	}
+	token, err := auth.IssuePasswordResetToken(ctx, s.cfg, s.cache, domain.UserPasswordResetCache, user.ID.String())
+	if err != nil {
+		return err
+	}
+	if err := s.sendPasswordReset(ctx, user, token); err != nil {
+		return err
+	}
+	return s.userStore.WriteLog(ctx, &domain.User{BaseModel: domain.BaseModel{ID: user.ID}, Email: user.Email}, domain.ForgotPasswordLog)
+}
+
+// POST /users/password/reset, the token can be used once and every session is revoked
+func (s *UserService) ResetPassword(ctx echo.Context, req domain.PasswordReset) error {
+	// check the policy before the token is consumed
+	if err := validate.Password(s.cfg.PasswordPolicy(), "password", req.Password); err != nil {
+		return err
+	}
+	userID, err := auth.ConsumePasswordResetToken(ctx, s.cfg, s.cache, domain.UserPasswordResetCache, req.Token)
+	if err != nil {
+		return err
+	}
+	user, err := s.userStore.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user, req.Password, domain.ResetPasswordLog); err != nil {
+		return err
+	}
+	if err := s.lockout.Reset(ctx, user.Email.String()); err != nil {
+		return err
+	}
+	return s.services.Session.RevokeAll(ctx, user.ID)
+}
+
+// POST /users/password/change, the expired password can't login so the credentials are sent instead of the token
+func (s *UserService) ChangePassword(ctx echo.Context, req domain.PasswordChange) error {
+	user, err := s.verifyCredentials(ctx, domain.UserLogin{Email: req.Email, Password: req.OldPassword})
+	if err != nil {
+		return err
 	}
-	return nil, xerror.EInvalidInput(nil).SetExtraInfo("counter", increaseStrike)
+	return s.setPassword(ctx, user, req.Password, domain.ChangePasswordLog)
+}
+
+// setPassword validate the new password with the policy and keep it in the history
+func (s *UserService) setPassword(ctx echo.Context, user *domain.User, password, typeLog string) error {
+	policy := s.cfg.PasswordPolicy()
+	if err := s.services.Password.Validate(ctx, policy, user.ID, user.Password, "password", password); err != nil {
+		return err
+	}
+	hashed := domain.Password(password).Hash()
+	if err := s.userStore.Update(ctx, &domain.User{
+		BaseModel:         domain.BaseModel{ID: user.ID},
+		Password:          hashed,
+		PasswordChangedAt: domain.TimeNowPtr(),
+	}, typeLog); err != nil {
+		return err
+	}
+	return s.services.Password.Record(ctx, policy, user.ID, hashed)
+}
+
+func (s *UserService) sendPasswordReset(ctx echo.Context, user *domain.User, token string) error {
+	return s.services.Mail.SendPasswordReset(ctx, user.Email.String(), domain.MailToken{
+		Name:     user.FirstName,
+		Token:    token,
+		Path:     domain.UserPasswordResetPath,
+		ExpireAt: time.Now().Add(s.cfg.VerifyTokenDuration),
+	})
+}
+
+func (s *UserService) userIncrementStrike(ctx echo.Context, login domain.UserLogin) error {
+	s.userStore.WriteLog(ctx, &domain.User{Email: login.Email}, domain.LoginFail)
+	return s.lockout.Fail(ctx, login.Email.String())
+}
+
+// notifyLockout mail the user of the email, unknown email is not notified
+func (s *UserService) notifyLockout(ctx echo.Context, email string, strikes int, until time.Time) error {
+	user, err := s.userStore.GetByKey(ctx, "email", email)
+	if err != nil {
+		return nil
+	}
+	return s.services.Mail.SendAccountLocked(ctx, user.Email.String(), domain.MailLockout{
+		Name:        user.FirstName,
+		Attempts:    strikes,
+		LockedUntil: until,
+	})
 }
 
 // GetMe /users/me
@@ -304,3 +516,28 @@ func (s *UserService) DeleteByIds(ctx echo.Context, ids domain.Ids) error {
 	}
 	return nil
 }
+
+// POST /users/:id/impersonate, the staff get a session of the user and every change is logged with both of them
+func (s *UserService) Impersonate(ctx echo.Context, id string) (*domain.ImpersonationToken, error) {
+	staff := domain.StaffFromContext(ctx)
+	if staff == nil {
+		return nil, xerror.EForbidden()
+	}
+	user, err := s.userStore.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.services.Session.Create(ctx, user.ID, fmt.Sprintf(domain.ImpersonationDeviceID, staff.ID))
+	if err != nil {
+		return nil, err
+	}
+	token, err := auth.IssueImpersonationToken(ctx, user.ID, session.ID, staff.ID, s.cfg, s.services.TokenKey.User(), s.cache.SetCache)
+	if err != nil {
+		return nil, err
+	}
+	token.DeviceID = session.DeviceID
+	if err := s.userStore.WriteLog(ctx, &domain.User{BaseModel: domain.BaseModel{ID: user.ID}, Email: user.Email}, domain.ImpersonateLog); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
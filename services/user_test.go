@@ -0,0 +1,125 @@
+package services_test
+
+import (
+	"fmt"
+	"go_base/domain"
+	"go_base/xerror"
+	"strings"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+func (uts *UnitTestSuite) TestUserService_Impersonate() {
+	user, err := uts.service.User.Create(uts.ctx, domain.UserCreate{
+		Email:     domain.SensitiveString(strings.ToLower(faker.Username()) + "@example.com"),
+		FirstName: faker.FirstName(),
+		LastName:  faker.LastName(),
+	})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+
+	// only a staff can impersonate
+	_, err = uts.service.User.Impersonate(uts.ctx, user.ID.String())
+	uts.Error(err)
+
+	staff := &domain.Staff{Email: domain.SensitiveString(faker.Email()), FirstName: "Support", IsVerified: true, Status: domain.StaffActive}
+	if err := uts.server.Stores.Staff.Create(uts.ctx, staff); err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.ctx.Set(domain.StaffCtx, staff)
+	defer uts.ctx.Set(domain.StaffCtx, nil)
+
+	token, err := uts.service.User.Impersonate(uts.ctx, user.ID.String())
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(fmt.Sprintf(domain.ImpersonationDeviceID, staff.ID), token.DeviceID)
+
+	var claims domain.AuthClaims
+	if _, err := uts.service.TokenKey.User().Parse(token.AccessToken, &claims); err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(user.ID.String(), claims.UserID)
+	uts.Equal(staff.ID.String(), claims.Actor.Subject)
+	uts.Equal(jwt.ClaimStrings{domain.AudienceUser}, claims.Audience)
+}
+
+func (uts *UnitTestSuite) TestUserService_OwnScope() {
+	owner := &domain.Staff{Email: domain.SensitiveString(faker.Email()), FirstName: "Sales", IsVerified: true, Status: domain.StaffActive}
+	other := &domain.Staff{Email: domain.SensitiveString(faker.Email()), FirstName: "Other", IsVerified: true, Status: domain.StaffActive}
+	for _, staff := range []*domain.Staff{owner, other} {
+		if err := uts.server.Stores.Staff.Create(uts.ctx, staff); err != nil {
+			uts.T().Fatal(err)
+		}
+	}
+	mine := &domain.User{Email: domain.SensitiveString(strings.ToLower(faker.Username()) + "@example.com"), FirstName: faker.FirstName(), StaffID: &owner.ID}
+	theirs := &domain.User{Email: domain.SensitiveString(strings.ToLower(faker.Username()) + "@example.com"), FirstName: faker.FirstName(), StaffID: &other.ID}
+	for _, user := range []*domain.User{mine, theirs} {
+		if err := uts.server.Stores.User.Create(uts.ctx, user); err != nil {
+			uts.T().Fatal(err)
+		}
+	}
+
+	uts.ctx.Set(domain.StaffCtx, owner)
+	uts.ctx.Set(string(domain.PermissionScopeKey), &domain.ScopeGrant{Resource: "user", Scope: domain.PermissionScopeOwn})
+	defer uts.ctx.Set(string(domain.PermissionScopeKey), nil)
+	defer uts.ctx.Set(domain.StaffCtx, nil)
+
+	users, err := uts.service.User.Find(uts.ctx, domain.Pagination[domain.User]{})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal(1, len(users.Items))
+	uts.Equal(mine.ID, users.Items[0].ID)
+
+	_, err = uts.service.User.Get(uts.ctx, theirs.ID.String())
+	uts.Error(err)
+
+	uts.ctx.SetParamNames("id")
+	uts.ctx.SetParamValues(theirs.ID.String())
+	_, err = uts.service.User.Update(uts.ctx, domain.UserUpdate{FirstName: lo.ToPtr("Taken")})
+	uts.True(xerror.IsNotFoundError(err))
+	uts.True(xerror.IsNotFoundError(uts.service.User.Delete(uts.ctx)))
+
+	// the user can't be given to another staff
+	uts.ctx.SetParamValues(mine.ID.String())
+	_, err = uts.service.User.Update(uts.ctx, domain.UserUpdate{StaffID: lo.ToPtr(other.ID.String())})
+	if uts.Error(err) {
+		uts.Equal(xerror.ErrCodeForbidden, err.(*xerror.Xerror).StatusCode)
+	}
+}
+
+func (uts *UnitTestSuite) TestUserService_CreateReplaceDeleted() {
+	email := domain.SensitiveString("replace-" + uuid.NewString()[:8] + "@example.com")
+	old, err := uts.service.User.Create(uts.ctx, domain.UserCreate{Email: email, FirstName: "Old", LastName: "LastName"})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	if err := uts.server.Stores.User.Delete(uts.ctx, old.ID); err != nil {
+		uts.T().Fatal(err)
+	}
+	exists := func(id uuid.UUID) bool {
+		var count int64
+		if err := uts.server.DB.Unscoped().Model(&domain.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			uts.T().Fatal(err)
+		}
+		return count == 1
+	}
+
+	// postgres reject the NUL of the name, the insert fail after the delete and the deleted user is kept
+	_, err = uts.service.User.Create(uts.ctx, domain.UserCreate{Email: email, FirstName: "New\x00", LastName: "LastName"})
+	uts.Error(err)
+	uts.True(exists(old.ID))
+
+	user, err := uts.service.User.Create(uts.ctx, domain.UserCreate{Email: email, FirstName: "New", LastName: "LastName"})
+	if err != nil {
+		uts.T().Fatal(err)
+	}
+	uts.Equal("New", user.FirstName)
+	uts.False(exists(old.ID))
+	uts.True(exists(user.ID))
+}
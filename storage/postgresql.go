@@ -54,6 +54,9 @@ func migration(db *gorm.DB) error {
 	if err := db.AutoMigrate(&domain.Role{}); err != nil {
 		return err
 	}
+	if err := db.AutoMigrate(&domain.Organization{}); err != nil {
+		return err
+	}
 	if err := db.AutoMigrate(&domain.Staff{}); err != nil {
 		return err
 	}
@@ -63,9 +66,21 @@ func migration(db *gorm.DB) error {
 	if err := db.AutoMigrate(&domain.Auth{}); err != nil {
 		return err
 	}
+	if err := db.AutoMigrate(&domain.Session{}); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&domain.TwoFactor{}); err != nil {
+		return err
+	}
 	if err := db.AutoMigrate(&domain.User{}); err != nil {
 		return err
 	}
+	if err := db.AutoMigrate(&domain.PasswordHistory{}); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&domain.ServiceAccount{}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -47,6 +47,32 @@ func (s *Cache) IncreaseStrike(ctx context.Context, key string) (int, error) {
 	return int(strikes), nil
 }
 
+// IncreaseStrikeWindow increase the counter, the key expire after window from the first strike
+func (s *Cache) IncreaseStrikeWindow(ctx context.Context, key string, window time.Duration) (int, error) {
+	var incr *redis.IntCmd
+	if _, err := s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.ExpireNX(ctx, key, window)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return int(incr.Val()), nil
+}
+
+// TTL remaining time of the key, ENotFound when the key does not exist
+func (s *Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.Client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 the key does not exist, -1 the key has no expiration
+	if ttl == -2 {
+		return 0, xerror.ENotFound()
+	}
+	return ttl, nil
+}
+
 func (s *Cache) SetCache(ctx context.Context, key string, value any, expiration time.Duration) error {
 	if err := s.Client.Set(ctx, key, value, expiration).Err(); err != nil {
 		return err
@@ -54,6 +80,15 @@ func (s *Cache) SetCache(ctx context.Context, key string, value any, expiration
 	return nil
 }
 
+// SetCacheNX set the key only when it does not exist, false when another caller already set it
+func (s *Cache) SetCacheNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	ok, err := s.Client.SetNX(ctx, key, value, expiration).Result()
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
 func (s *Cache) GetCache(ctx context.Context, key string) ([]byte, error) {
 	val, err := s.Client.Get(ctx, key).Result()
 	if err != nil {
@@ -99,6 +134,18 @@ func (s *Cache) GetStringValue(ctx context.Context, key string) (string, error)
 	return val, nil
 }
 
+// PopStringValue get and delete the key, only one caller can get the value
+func (s *Cache) PopStringValue(ctx context.Context, key string) (string, error) {
+	val, err := s.Client.GetDel(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", xerror.ENotFound()
+		}
+		return "", err
+	}
+	return val, nil
+}
+
 func (s *Cache) GetStrikes(ctx context.Context, key string) (int, error) {
 	ss, err := s.Client.Get(ctx, key).Result()
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"go_base/domain"
+	"go_base/domain/permission"
 	"go_base/logger"
 	"go_base/xerror"
 
@@ -57,6 +58,7 @@ func init() {
 	})
 	v.RegisterCustomTypeFunc(ValidPermissions, domain.PermissionTree{})
 
+	v.RegisterValidation("permission", ValidatePermissionName)
 	v.RegisterValidation("staff_status", ValidateStaffStatus)
 	v.RegisterValidation("phone", ValidatePhone)
 
@@ -95,6 +97,11 @@ func Struct(s any) error {
 		}
 	}
 
+	return Fields(ferrs)
+}
+
+// Fields invalid input error of the field errors, same as Struct
+func Fields(ferrs domain.FieldErrors) error {
 	xerr := xerror.EInvalidInput(ferrs).SetMessage("invalid input")
 	for _, data := range ferrs {
 		_ = xerr.SetExtraInfo(data.Field, data.Err)
@@ -103,6 +110,18 @@ func Struct(s any) error {
 	return xerr
 }
 
+// Password validates password against the policy, every violation is reported on field
+func Password(policy domain.PasswordPolicy, field, password string) error {
+	violations := policy.Violations(password)
+	if len(violations) == 0 {
+		return nil
+	}
+	return Fields(domain.FieldErrors{{
+		Field: field,
+		Err:   field + " " + strings.Join(violations, ", "),
+	}})
+}
+
 func isTime(fl validator.FieldLevel) bool {
 	if fl.Field().String() == "" {
 		return true
@@ -142,14 +161,13 @@ func ValidPermissions(field reflect.Value) interface{} {
 		if p["*"]["*"]["*"] == "*" {
 			return fmt.Errorf("invalid permission")
 		}
-		// last str is true or false
-		for _, v := range p {
-			for _, v1 := range v {
-				for _, v2 := range v1 {
-					if v2 != "true" && v2 != "false" {
-						return fmt.Errorf("invalid permission")
+		// every action must be registered in the catalog, the value is false or one of its scopes
+		for system, resources := range p {
+			for resource, actions := range resources {
+				for action, value := range actions {
+					if err := permission.Check(system, resource, action, value); err != nil {
+						return fmt.Errorf("invalid permission: %w", err)
 					}
-
 				}
 			}
 		}
@@ -158,6 +176,15 @@ func ValidPermissions(field reflect.Value) interface{} {
 	return nil
 }
 
+// ValidatePermissionName {system}.{resource}.{action}.{scope} registered in the permission catalog, false grant nothing
+func ValidatePermissionName(fl validator.FieldLevel) bool {
+	parts := strings.Split(fl.Field().String(), ".")
+	if len(parts) != 4 || parts[3] == permission.ScopeDeny {
+		return false
+	}
+	return permission.Check(parts[0], parts[1], parts[2], parts[3]) == nil
+}
+
 func ValidateStaffStatus(fl validator.FieldLevel) bool {
 	switch fl.Field().String() {
 	case string(domain.StaffActive), string(domain.StaffInactive), string(domain.StaffPending):
@@ -7,6 +7,25 @@ const (
 	ErrInvalidCredentials            = "invalid_credentials"
 	ErrAuthAdminLoginMustSetPassword = "auth_admin_login_must_set_password"
 	ErrAuthAdminLoginReachLimit      = "auth_admin_login_reach_limit"
+	ErrInvalidRefreshToken           = "invalid_refresh_token"
+	ErrRefreshTokenReused            = "refresh_token_reused"
+	ErrInvalidOTP                    = "invalid_otp"
+	ErrOTPVerifyReachLimit           = "otp_verify_reach_limit"
+	ErrOTPResendReachLimit           = "otp_resend_reach_limit"
+	ErrTwoFactorRequired             = "two_factor_required"
+	ErrTwoFactorEnrollRequired       = "two_factor_enrollment_required"
+	ErrInvalidTwoFactorCode          = "invalid_two_factor_code"
+	ErrInvalidPasswordResetToken     = "invalid_password_reset_token"
+	ErrPasswordExpired               = "password_expired"
+	ErrInvalidTokenAudience          = "invalid_token_audience"
+	ErrInvalidAPIKey                 = "invalid_api_key"
+	ErrInvalidOIDCState              = "invalid_oidc_state"
+	ErrOIDCLoginFailed               = "oidc_login_failed"
+	ErrOIDCEmailNotAllowed           = "oidc_email_not_allowed"
+	ErrOIDCAccountNotFound           = "oidc_account_not_found"
+	ErrImpersonationForbidden        = "impersonation_forbidden"
+	ErrLoginIPThrottled              = "login_ip_throttled"
+	ErrServiceAccountScopeNotGranted = "service_account_scope_not_granted"
 )
 
 const (
@@ -20,8 +39,12 @@ const (
 	ErrCodeNotImplemented       = "not_implemented"
 	ErrCodeInternal             = "internal"
 	ErrCodeTooManyLoginAttempts = "too_many_login_attempts"
+	ErrCodeTooManyRequests      = "too_many_requests"
 )
 
+// RetryAfterKey extra info in seconds, sent as Retry-After header
+const RetryAfterKey = "retry_after"
+
 const (
 	ErrLevelWarn  Level = "warn"
 	ErrLevelError Level = "error"
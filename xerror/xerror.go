@@ -197,6 +197,9 @@ func EInternal() *Xerror {
 func EForbidden() *Xerror {
 	return New().SetStatusCode(ErrCodeForbidden)
 }
+func ETooManyRequests() *Xerror {
+	return New().SetStatusCode(ErrCodeTooManyRequests)
+}
 func EUnAuthorized() *Xerror {
 	return New().SetStatusCode(ErrCodeUnauthorized)
 }